@@ -0,0 +1,146 @@
+// Filename: cmd/api/audit.go
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
+	"github.com/Duane-Arzu/test-1.git/internal/validator"
+)
+
+// The kinds of records in the audit log, named after their tables. Records
+// without an id of their own, like the books on a list or a user's reading
+// goal, are logged as an update of the record they belong to.
+const (
+	auditBooks          = "books"
+	auditChangeRequests = "change_requests"
+	auditImportJobs     = "import_jobs"
+	auditReadingLists   = "reading_lists"
+	auditProgress       = "reading_progress"
+	auditReviews        = "reviews"
+	auditSeries         = "series"
+	auditUsers          = "users"
+)
+
+var auditResources = []string{auditBooks, auditChangeRequests, auditImportJobs, auditReadingLists,
+	auditProgress, auditReviews, auditSeries, auditUsers}
+
+// listWithBooks is how a list is logged when it is created with books on it.
+type listWithBooks struct {
+	*data.ReadingList
+	Books []*data.BooksInList `json:"books,omitempty"`
+}
+
+// audit adds an event for a change to the log through m, so it is written in
+// the unit of work that makes the change and only kept if the change is.
+// before is nil for a record that is created, after for one that is deleted.
+func (a *applicationDependencies) audit(m data.Models, r *http.Request, resource string, id int64, action string, before, after any) error {
+	return a.auditOrigin(r).record(r.Context(), m, resource, id, action, before, after)
+}
+
+// auditEvent describes a change the request made, for the audit log.
+func (a *applicationDependencies) auditEvent(r *http.Request, resource string, id int64, action string, before, after any) (*data.AuditEvent, error) {
+	return a.auditOrigin(r).event(resource, id, action, before, after)
+}
+
+// auditOrigin is who made a change and through which request. Work the
+// request leaves to run in the background keeps it to log its own changes.
+type auditOrigin struct {
+	actorID   *int64 // nil for anonymous requests
+	requestID string
+	ip        string
+}
+
+func (a *applicationDependencies) auditOrigin(r *http.Request) auditOrigin {
+	origin := auditOrigin{
+		requestID: a.contextGetRequestID(r),
+		ip:        clientIP(r),
+	}
+	user := a.contextGetUser(r)
+	if !user.IsAnonymous() {
+		origin.actorID = &user.ID
+	}
+	return origin
+}
+
+// record adds an event for a change to the log through m, like audit.
+func (o auditOrigin) record(ctx context.Context, m data.Models, resource string, id int64, action string, before, after any) error {
+	event, err := o.event(resource, id, action, before, after)
+	if err != nil {
+		return err
+	}
+	return m.Audit.Insert(ctx, event)
+}
+
+func (o auditOrigin) event(resource string, id int64, action string, before, after any) (*data.AuditEvent, error) {
+	changes, err := data.AuditDiff(before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	return &data.AuditEvent{
+		ActorID:    o.actorID,
+		Resource:   resource,
+		ResourceID: id,
+		Action:     action,
+		Changes:    changes,
+		RequestID:  o.requestID,
+		IP:         o.ip,
+	}, nil
+}
+
+// clientIP is the address the request came from, without the port.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+func (a *applicationDependencies) listAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	var queryParameterData struct {
+		Resource   string
+		ResourceID int64
+		data.Filters
+	}
+
+	queryParameter := r.URL.Query()
+
+	v := validator.New()
+
+	queryParameterData.Resource = a.getSingleQueryParameter(queryParameter, "resource", "")
+	v.Check(queryParameterData.Resource == "" || validator.PermittedValue(queryParameterData.Resource, auditResources...),
+		"resource", "must be a known kind of record")
+	queryParameterData.ResourceID = int64(a.getSingleIntegerParameter(queryParameter, "id", 0, v))
+	v.Check(queryParameterData.ResourceID >= 0, "id", "must not be negative")
+	v.Check(queryParameterData.ResourceID == 0 || queryParameterData.Resource != "", "resource", "must be provided with an id")
+
+	queryParameterData.Filters.Page = a.getSingleIntegerParameter(queryParameter, "page", 1, v)
+	queryParameterData.Filters.PageSize = a.getSingleIntegerParameter(queryParameter, "page_size", 20, v)
+	queryParameterData.Filters.Sort = "-id"
+	queryParameterData.Filters.SortSafeList = []string{"-id"}
+
+	data.ValidateFilters(v, queryParameterData.Filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	events, metadata, err := a.auditModel.GetAll(r.Context(), queryParameterData.Resource, queryParameterData.ResourceID, queryParameterData.Filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"audit_events": events,
+		"@metadata":    metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -1,22 +1,38 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	// import the data package which contains the definition for Comment
 	"github.com/Duane-Arzu/test-1.git/internal/data"
 	"github.com/Duane-Arzu/test-1.git/internal/validator"
 )
 
-var incomingData struct {
-	Title           *string `json:"title"`
-	Authors         *string `json:"authors"`
-	ISBN            *string `json:"isbn"`
-	PublicationDate *string `json:"publication_date"` // Use string to parse and validate date later
-	Genre           *string `json:"genre"`
-	Description     *string `json:"description"`
+// refreshWeightedRatingsEvery brings the weighted rating of every book back to
+// the current mean, straight away and then at every interval until ctx is
+// cancelled. In between, only the books that are rated are brought up to date.
+func (a *applicationDependencies) refreshWeightedRatingsEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		count, err := a.bookModel.RefreshWeightedRatings(ctx)
+		if err != nil {
+			a.logger.Error("could not refresh weighted ratings", "error", err)
+		} else if count > 0 {
+			a.logger.Info("refreshed weighted ratings", "books", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 func (a *applicationDependencies) createBookHandler(w http.ResponseWriter, r *http.Request) {
@@ -29,6 +45,11 @@ func (a *applicationDependencies) createBookHandler(w http.ResponseWriter, r *ht
 		PublicationDate string `json:"publication_date"` // Use string to parse and validate date later
 		Genre           string `json:"genre"`
 		Description     string `json:"description"`
+		WorkID          int64  `json:"work_id"` // leave out to start a new work
+		Format          string `json:"format"`
+		Publisher       string `json:"publisher"`
+		Language        string `json:"language"`
+		PageCount       int    `json:"page_count"`
 	}
 
 	// Decode the request JSON
@@ -46,6 +67,11 @@ func (a *applicationDependencies) createBookHandler(w http.ResponseWriter, r *ht
 		PublicationDate: incomingData.PublicationDate,
 		Genre:           incomingData.Genre,
 		Description:     incomingData.Description,
+		WorkID:          incomingData.WorkID,
+		Format:          incomingData.Format,
+		Publisher:       incomingData.Publisher,
+		Language:        incomingData.Language,
+		PageCount:       incomingData.PageCount,
 	}
 
 	// Validate the book using a Validator instance
@@ -55,13 +81,24 @@ func (a *applicationDependencies) createBookHandler(w http.ResponseWriter, r *ht
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	// a.bookModel.BookExists(v, book.ISBN)
+
+	// a new edition of an existing work needs that work to exist
+	if !a.checkWorkExists(w, r, v, book.WorkID) {
+		return
+	}
+	// a.bookModel.BookExists(r.Context(), v, book.ISBN)
 	// if !v.IsEmpty() {
 	// 	a.failedValidationResponse(w, r, v.Errors)
 	// 	return
 	// }
 	// Insert the book into the database
-	err = a.bookModel.Insert(book)
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		err := m.Books.Insert(r.Context(), book)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditBooks, book.ID, data.AuditCreate, nil, book)
+	})
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -89,22 +126,46 @@ func (a *applicationDependencies) displayBookHandler(w http.ResponseWriter, r *h
 		return
 	}
 
-	book, err := a.bookModel.Get(id)
+	book, err := a.bookModel.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			a.notFoundResponse(w, r)
+			// ids of merged duplicates resolve to the book they were merged into
+			if !a.redirectMergedBook(w, r, id) {
+				a.notFoundResponse(w, r)
+			}
 		default:
 			a.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
+	// include=work returns the work the book is an edition of, with all its editions
+	include := a.getSingleQueryParameter(r.URL.Query(), "include", "")
+	var work *data.Work
+	if include == "work" && book.WorkID != 0 {
+		work, err = a.workModel.Get(r.Context(), book.WorkID)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	tag := bookETag(book, include, work)
+	if a.notModified(w, r, tag) {
+		return
+	}
+
 	// display the comment
 	data := envelope{
 		"Book": book,
 	}
-	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if work != nil {
+		data["work"] = work
+	}
+	headers := make(http.Header)
+	headers.Set("ETag", tag)
+	err = a.writeJSON(w, http.StatusOK, data, headers)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -112,6 +173,25 @@ func (a *applicationDependencies) displayBookHandler(w http.ResponseWriter, r *h
 
 }
 
+// checkWorkExists writes a validation error and returns false when a work id
+// was given for a work that does not exist.
+func (a *applicationDependencies) checkWorkExists(w http.ResponseWriter, r *http.Request, v *validator.Validator, workID int64) bool {
+	if workID == 0 {
+		return true
+	}
+	exists, err := a.workModel.Exists(r.Context(), workID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return false
+	}
+	if !exists {
+		v.AddError("work_id", "work does not exist")
+		a.failedValidationResponse(w, r, v.Errors)
+		return false
+	}
+	return true
+}
+
 func (a *applicationDependencies) updateBookHandler(w http.ResponseWriter, r *http.Request) {
 	// Get the ID from the URL
 	id, err := a.readIDParam(r, "bid")
@@ -121,7 +201,7 @@ func (a *applicationDependencies) updateBookHandler(w http.ResponseWriter, r *ht
 	}
 
 	// Retrieve the comment from the database
-	book, err := a.bookModel.Get(id)
+	book, err := a.bookModel.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, data.ErrRecordNotFound) {
 			a.notFoundResponse(w, r)
@@ -131,7 +211,27 @@ func (a *applicationDependencies) updateBookHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
+	// the client may only want to change the version it has seen
+	if ifVersionMatchFails(r, book.Version) {
+		a.preconditionFailedResponse(w, r)
+		return
+	}
+	original := *book
+
 	// Decode the incoming JSON
+	var incomingData struct {
+		Title           *string `json:"title"`
+		Authors         *string `json:"authors"`
+		ISBN            *string `json:"isbn"`
+		PublicationDate *string `json:"publication_date"` // Use string to parse and validate date later
+		Genre           *string `json:"genre"`
+		Description     *string `json:"description"`
+		WorkID          *int64  `json:"work_id"`
+		Format          *string `json:"format"`
+		Publisher       *string `json:"publisher"`
+		Language        *string `json:"language"`
+		PageCount       *int    `json:"page_count"`
+	}
 	err = a.readJSON(w, r, &incomingData)
 	if err != nil {
 		a.badRequestResponse(w, r, err)
@@ -157,6 +257,21 @@ func (a *applicationDependencies) updateBookHandler(w http.ResponseWriter, r *ht
 	if incomingData.Description != nil {
 		book.Description = *incomingData.Description
 	}
+	if incomingData.WorkID != nil {
+		book.WorkID = *incomingData.WorkID
+	}
+	if incomingData.Format != nil {
+		book.Format = *incomingData.Format
+	}
+	if incomingData.Publisher != nil {
+		book.Publisher = *incomingData.Publisher
+	}
+	if incomingData.Language != nil {
+		book.Language = *incomingData.Language
+	}
+	if incomingData.PageCount != nil {
+		book.PageCount = *incomingData.PageCount
+	}
 
 	// Validate the updated comment
 	v := validator.New()
@@ -165,11 +280,31 @@ func (a *applicationDependencies) updateBookHandler(w http.ResponseWriter, r *ht
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
+	if incomingData.WorkID != nil && !a.checkWorkExists(w, r, v, book.WorkID) {
+		return
+	}
 
 	// Perform the update in the database
-	err = a.bookModel.Update(book)
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		updated := *book
+		err := m.Books.Update(r.Context(), &updated)
+		if err != nil {
+			return err
+		}
+		err = a.audit(m, r, auditBooks, id, data.AuditUpdate, &original, &updated)
+		if err != nil {
+			return err
+		}
+		*book = updated
+		return nil
+	})
 	if err != nil {
-		a.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -177,7 +312,9 @@ func (a *applicationDependencies) updateBookHandler(w http.ResponseWriter, r *ht
 	data := envelope{
 		"Book": book,
 	}
-	err = a.writeJSON(w, http.StatusOK, data, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", bookETag(book, "", nil))
+	err = a.writeJSON(w, http.StatusOK, data, headers)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -191,11 +328,27 @@ func (a *applicationDependencies) deleteBookHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
-	err = a.bookModel.Delete(id)
+	// check If-Match against the book that is actually deleted
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		book, err := m.Books.Get(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		if ifVersionMatchFails(r, book.Version) {
+			return errPreconditionFailed
+		}
+		err = m.Books.Delete(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditBooks, id, data.AuditDelete, book, nil)
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			a.BIDnotFound(w, r, id) // Pass the ID to the custom message handler
+		case errors.Is(err, errPreconditionFailed):
+			a.preconditionFailedResponse(w, r)
 		default:
 			a.serverErrorResponse(w, r, err)
 		}
@@ -214,18 +367,27 @@ func (a *applicationDependencies) deleteBookHandler(w http.ResponseWriter, r *ht
 func (a *applicationDependencies) listBooksHandler(w http.ResponseWriter, r *http.Request) {
 	//to hold query parameters
 	var queryParameterData struct {
+		Tag string
 		data.Filters
 	}
 
 	//get query parameters from url
 	queryParameter := r.URL.Query()
 
+	// tags are personal so filtering by them needs a logged in user
+	queryParameterData.Tag = data.NormalizeTag(a.getSingleQueryParameter(queryParameter, "tag", ""))
+	user := a.contextGetUser(r)
+	if queryParameterData.Tag != "" && user.IsAnonymous() {
+		a.authenticationRequiredResponse(w, r)
+		return
+	}
+
 	v := validator.New()
 
 	queryParameterData.Filters.Page = a.getSingleIntegerParameter(queryParameter, "page", 1, v)
 	queryParameterData.Filters.PageSize = a.getSingleIntegerParameter(queryParameter, "page_size", 10, v)
 	queryParameterData.Filters.Sort = a.getSingleQueryParameter(queryParameter, "sort", "id")
-	queryParameterData.Filters.SortSafeList = []string{"id", "title", "author", "genre", "-id", "-title", "-author", "-genre"}
+	queryParameterData.Filters.SortSafeList = []string{"id", "title", "author", "genre", "weighted_rating", "-id", "-title", "-author", "-genre", "-weighted_rating"}
 
 	data.ValidateFilters(v, queryParameterData.Filters)
 	if !v.IsEmpty() {
@@ -233,7 +395,7 @@ func (a *applicationDependencies) listBooksHandler(w http.ResponseWriter, r *htt
 		return
 	}
 
-	books, metadata, err := a.bookModel.GetAll(queryParameterData.Filters)
+	books, metadata, err := a.bookModel.GetAll(r.Context(), queryParameterData.Tag, user.ID, queryParameterData.Filters)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -277,7 +439,7 @@ func (a *applicationDependencies) searchBookHandler(w http.ResponseWriter, r *ht
 	queryParameterData.Filters.Page = a.getSingleIntegerParameter(queryParameter, "page", 1, v)
 	queryParameterData.Filters.PageSize = a.getSingleIntegerParameter(queryParameter, "page_size", 10, v)
 	queryParameterData.Filters.Sort = a.getSingleQueryParameter(queryParameter, "sort", "id")
-	queryParameterData.Filters.SortSafeList = []string{"id", "title", "author", "genre", "-id", "-title", "-author", "-genre"}
+	queryParameterData.Filters.SortSafeList = []string{"id", "title", "author", "genre", "weighted_rating", "-id", "-title", "-author", "-genre", "-weighted_rating"}
 
 	data.ValidateFilters(v, queryParameterData.Filters)
 	if !v.IsEmpty() {
@@ -285,7 +447,7 @@ func (a *applicationDependencies) searchBookHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
-	books, metadata, err := a.bookModel.Search(queryParameterData.Title, queryParameterData.Author, queryParameterData.Genre, queryParameterData.Filters)
+	books, metadata, err := a.bookModel.Search(r.Context(), queryParameterData.Title, queryParameterData.Author, queryParameterData.Genre, queryParameterData.Filters)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
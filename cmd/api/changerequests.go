@@ -0,0 +1,375 @@
+// Filename: cmd/api/changerequests.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
+	"github.com/Duane-Arzu/test-1.git/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+func (a *applicationDependencies) proposeBookChangeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "bid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	book, err := a.bookModel.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.BIDnotFound(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var incomingData struct {
+		Title           *string `json:"title"`
+		Authors         *string `json:"authors"`
+		ISBN            *string `json:"isbn"`
+		PublicationDate *string `json:"publication_date"`
+		Genre           *string `json:"genre"`
+		Description     *string `json:"description"`
+		Format          *string `json:"format"`
+		Publisher       *string `json:"publisher"`
+		Language        *string `json:"language"`
+		PageCount       *int    `json:"page_count"`
+		Comment         string  `json:"comment"`
+	}
+	err = a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	// the proposal is made on a copy so it can be validated as a whole book
+	edited := *book
+	if incomingData.Title != nil {
+		edited.Title = *incomingData.Title
+	}
+	if incomingData.Authors != nil {
+		edited.Authors = *incomingData.Authors
+	}
+	if incomingData.ISBN != nil {
+		edited.ISBN = *incomingData.ISBN
+	}
+	if incomingData.PublicationDate != nil {
+		edited.PublicationDate = *incomingData.PublicationDate
+	}
+	if incomingData.Genre != nil {
+		edited.Genre = *incomingData.Genre
+	}
+	if incomingData.Description != nil {
+		edited.Description = *incomingData.Description
+	}
+	if incomingData.Format != nil {
+		edited.Format = *incomingData.Format
+	}
+	if incomingData.Publisher != nil {
+		edited.Publisher = *incomingData.Publisher
+	}
+	if incomingData.Language != nil {
+		edited.Language = *incomingData.Language
+	}
+	if incomingData.PageCount != nil {
+		edited.PageCount = *incomingData.PageCount
+	}
+
+	user := a.contextGetUser(r)
+	change := &data.ChangeRequest{
+		BookID:      book.ID,
+		ProposedBy:  user.ID,
+		BaseVersion: book.Version,
+		Changes:     data.DiffBooks(book, &edited),
+		Comment:     incomingData.Comment,
+	}
+
+	v := validator.New()
+	data.ValidateBook(v, &edited)
+	data.ValidateChangeRequest(v, change)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		err := m.ChangeRequests.Insert(r.Context(), change)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditChangeRequests, change.ID, data.AuditCreate, nil, change)
+	})
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/changes/%d", change.ID))
+
+	data := envelope{
+		"change_request": change,
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// listChangeRequestsHandler serves both the moderation queue and the changes
+// proposed for a single book, depending on whether the URL names a book.
+func (a *applicationDependencies) listChangeRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	var bookID int64
+	if httprouter.ParamsFromContext(r.Context()).ByName("bid") != "" {
+		id, err := a.readIDParam(r, "bid")
+		if err != nil {
+			a.notFoundResponse(w, r)
+			return
+		}
+		bookID = id
+	}
+
+	var queryParameterData struct {
+		Status string
+		data.Filters
+	}
+
+	queryParameter := r.URL.Query()
+
+	v := validator.New()
+
+	queryParameterData.Status = a.getSingleQueryParameter(queryParameter, "status", "")
+	v.Check(validator.PermittedValue(queryParameterData.Status, "", data.ChangePending, data.ChangeApproved, data.ChangeRejected),
+		"status", "must be pending, approved or rejected")
+
+	queryParameterData.Filters.Page = a.getSingleIntegerParameter(queryParameter, "page", 1, v)
+	queryParameterData.Filters.PageSize = a.getSingleIntegerParameter(queryParameter, "page_size", 20, v)
+	queryParameterData.Filters.Sort = "created_at"
+	queryParameterData.Filters.SortSafeList = []string{"created_at"}
+
+	data.ValidateFilters(v, queryParameterData.Filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	changes, metadata, err := a.changeRequestModel.GetAll(r.Context(), queryParameterData.Status, bookID, queryParameterData.Filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"change_requests": changes,
+		"@metadata":       metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// readChangeRequest fetches the change request named in the URL. It reports
+// whether it was found; if not, a response has been written.
+func (a *applicationDependencies) readChangeRequest(w http.ResponseWriter, r *http.Request) (*data.ChangeRequest, bool) {
+	id, err := a.readIDParam(r, "cid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	change, err := a.changeRequestModel.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	return change, true
+}
+
+// showChangeRequestHandler lets proposers follow their own changes; everyone
+// else needs to be a moderator.
+func (a *applicationDependencies) showChangeRequestHandler(w http.ResponseWriter, r *http.Request) {
+	change, ok := a.readChangeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	user := a.contextGetUser(r)
+	if change.ProposedBy != user.ID {
+		permissions, err := a.permissionModel.GetAllForUser(r.Context(), user.ID)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		if !permissions.Include(data.PermissionModerateBooks) {
+			a.notFoundResponse(w, r)
+			return
+		}
+	}
+
+	data := envelope{
+		"change_request": change,
+	}
+	err := a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) approveChangeRequestHandler(w http.ResponseWriter, r *http.Request) {
+	change, ok := a.readChangeRequest(w, r)
+	if !ok {
+		return
+	}
+	if change.Status != data.ChangePending {
+		a.errorResponseJSON(w, r, http.StatusConflict, fmt.Sprintf("the change request was already %s", change.Status))
+		return
+	}
+
+	book, err := a.bookModel.Get(r.Context(), change.BookID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = change.ApplyChanges(book)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrChangeConflict):
+			a.errorResponseJSON(w, r, http.StatusConflict, err.Error())
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// the rules may have changed since the proposal was made
+	v := validator.New()
+	data.ValidateBook(v, book)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	a.resolveChangeRequest(w, r, change, book, data.ChangeApproved, "")
+}
+
+func (a *applicationDependencies) rejectChangeRequestHandler(w http.ResponseWriter, r *http.Request) {
+	change, ok := a.readChangeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var incomingData struct {
+		Reason string `json:"reason"`
+	}
+	err := a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	data.ValidateChangeRejection(v, incomingData.Reason)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	a.resolveChangeRequest(w, r, change, nil, data.ChangeRejected, incomingData.Reason)
+}
+
+// resolveChangeRequest records the moderator's decision, lets the proposer
+// know by email and writes the response. An approved change comes with the
+// book it was applied to, which is saved along with the decision.
+func (a *applicationDependencies) resolveChangeRequest(w http.ResponseWriter, r *http.Request, change *data.ChangeRequest, book *data.Book, status, reason string) {
+	user := a.contextGetUser(r)
+
+	err := a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		if book != nil {
+			// the book as it is now, for the audit log
+			current, err := m.Books.Get(r.Context(), book.ID)
+			if err != nil {
+				return err
+			}
+			updated := *book
+			err = m.Books.Update(r.Context(), &updated)
+			if err != nil {
+				return err
+			}
+			err = a.audit(m, r, auditBooks, book.ID, data.AuditUpdate, current, &updated)
+			if err != nil {
+				return err
+			}
+		}
+
+		resolved := *change
+		resolved.Status = status
+		resolved.Reason = reason
+		resolved.ReviewedBy = &user.ID
+		err := m.ChangeRequests.Resolve(r.Context(), &resolved)
+		if err != nil {
+			return err
+		}
+		err = a.audit(m, r, auditChangeRequests, change.ID, data.AuditUpdate, change, &resolved)
+		if err != nil {
+			return err
+		}
+		*change = resolved
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// the proposer may have deleted their account since
+	if change.ProposedBy != 0 {
+		resolved := *change
+		ctx := context.WithoutCancel(r.Context())
+		a.background(func() {
+			proposer, err := a.userModel.GetByID(ctx, resolved.ProposedBy)
+			if err != nil {
+				a.logger.Error("could not find proposer", "change_request_id", resolved.ID, "error", err)
+				return
+			}
+			approved := resolved.Status == data.ChangeApproved
+			data := map[string]any{
+				"username":        proposer.Username,
+				"changeRequestID": resolved.ID,
+				"bookID":          resolved.BookID,
+				"approved":        approved,
+				"reason":          resolved.Reason,
+			}
+			err = a.mailer.Send(proposer.Email, "change_request_resolved.tmpl", data)
+			if err != nil {
+				a.logger.Error(err.Error())
+			}
+		})
+	}
+
+	data := envelope{
+		"change_request": change,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
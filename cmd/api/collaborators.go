@@ -0,0 +1,311 @@
+// Filename: cmd/api/collaborators.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
+	"github.com/Duane-Arzu/test-1.git/internal/validator"
+)
+
+func (a *applicationDependencies) moveReadingListBookHandler(w http.ResponseWriter, r *http.Request) {
+	listID, err := a.readIDParam(r, "lid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+	bookID, err := a.readIDParam(r, "bid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var incomingData struct {
+		Position *int `json:"position"`
+	}
+	err = a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(incomingData.Position != nil, "position", "must be provided")
+	v.Check(incomingData.Position == nil || *incomingData.Position >= 0, "position", "must not be negative")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, ok := a.readingListForUser(w, r, listID, listEditor)
+	if !ok {
+		return
+	}
+
+	var book *data.BooksInList
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		books, err := m.ReadingLists.GetBooks(r.Context(), listID)
+		if err != nil {
+			return err
+		}
+		before := findListBook(books, bookID)
+		if before == nil {
+			return data.ErrRecordNotFound
+		}
+		book, err = m.ReadingLists.MoveBook(r.Context(), listID, bookID, *incomingData.Position)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditReadingLists, listID, data.AuditUpdate, map[string]any{"book": before}, map[string]any{"book": book})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"book": book,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) listCollaboratorsHandler(w http.ResponseWriter, r *http.Request) {
+	listID, err := a.readIDParam(r, "lid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	_, ok := a.readingListForUser(w, r, listID, listViewer)
+	if !ok {
+		return
+	}
+
+	collaborators, err := a.readingListModel.GetCollaborators(r.Context(), listID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"collaborators": collaborators,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) addCollaboratorHandler(w http.ResponseWriter, r *http.Request) {
+	listID, err := a.readIDParam(r, "lid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var incomingData struct {
+		UserID  int64 `json:"user_id"`
+		CanEdit *bool `json:"can_edit"`
+	}
+	err = a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	list, ok := a.readingListForUser(w, r, listID, listOwner)
+	if !ok {
+		return
+	}
+
+	collaborator := &data.Collaborator{
+		ReadingListID: listID,
+		UserID:        incomingData.UserID,
+		CanEdit:       true,
+	}
+	if incomingData.CanEdit != nil {
+		collaborator.CanEdit = *incomingData.CanEdit
+	}
+
+	v := validator.New()
+	v.Check(collaborator.UserID > 0, "user_id", "must be a valid user ID")
+	v.Check(collaborator.UserID != int64(list.CreatedBy), "user_id", "must not be the owner of the list")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = a.userModel.GetByID(r.Context(), collaborator.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("user_id", "user does not exist")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		err := m.ReadingLists.AddCollaborator(r.Context(), collaborator)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditReadingLists, listID, data.AuditUpdate, nil, map[string]any{"collaborator": collaborator})
+	})
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"collaborator": collaborator,
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) removeCollaboratorHandler(w http.ResponseWriter, r *http.Request) {
+	listID, err := a.readIDParam(r, "lid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+	userID, err := a.readIDParam(r, "uid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	// collaborators may leave a list on their own, everyone else needs to be the owner
+	if userID != a.contextGetUser(r).ID {
+		_, ok := a.readingListForUser(w, r, listID, listOwner)
+		if !ok {
+			return
+		}
+	}
+
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		collaborators, err := m.ReadingLists.GetCollaborators(r.Context(), listID)
+		if err != nil {
+			return err
+		}
+		var removed *data.Collaborator
+		for _, collaborator := range collaborators {
+			if collaborator.UserID == userID {
+				removed = collaborator
+			}
+		}
+		if removed == nil {
+			return data.ErrRecordNotFound
+		}
+		err = m.ReadingLists.RemoveCollaborator(r.Context(), listID, userID)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditReadingLists, listID, data.AuditUpdate, map[string]any{"collaborator": removed}, nil)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"message": "Collaborator successfully removed",
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) cloneReadingListHandler(w http.ResponseWriter, r *http.Request) {
+	sourceID, err := a.readIDParam(r, "lid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	// the name and description of the copy can be overridden, the body is optional
+	var incomingData struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+	}
+	if r.ContentLength != 0 {
+		err = a.readJSON(w, r, &incomingData)
+		if err != nil {
+			a.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	source, ok := a.readingListForUser(w, r, sourceID, listViewer)
+	if !ok {
+		return
+	}
+
+	user := a.contextGetUser(r)
+	list := &data.ReadingList{
+		Name:        source.Name,
+		Description: source.Description,
+		CreatedBy:   int(user.ID),
+	}
+	if incomingData.Name != nil {
+		list.Name = *incomingData.Name
+	}
+	if incomingData.Description != nil {
+		list.Description = *incomingData.Description
+	}
+
+	v := validator.New()
+	data.ValidateReadingList(v, list)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		err := m.ReadingLists.Clone(r.Context(), sourceID, list)
+		if err != nil {
+			return err
+		}
+		books, err := m.ReadingLists.GetBooks(r.Context(), list.ID)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditReadingLists, list.ID, data.AuditCreate, nil, listWithBooks{list, books})
+	})
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/lists/%d", list.ID))
+
+	data := envelope{
+		"Reading List": list,
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
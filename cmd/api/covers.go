@@ -0,0 +1,247 @@
+// Filename: cmd/api/covers.go
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // registered for image.Decode
+	"image/jpeg"
+	_ "image/png" // registered for image.Decode
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
+	"github.com/Duane-Arzu/test-1.git/internal/storage"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	coverMaxBytes  = 5 << 20 // 5MB
+	coverMaxPixels = 40_000_000
+)
+
+// coverContentTypes are the image types covers can be uploaded as. They are
+// the formats the standard library can decode.
+var coverContentTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// errInvalidCover wraps the problems with an upload that are the client's fault.
+var errInvalidCover = errors.New("invalid cover image")
+
+// makeThumbnail scales the image down to the width, keeping its aspect
+// ratio. Every thumbnail pixel is the average of the source pixels it covers
+// and transparent areas end up white since the result is stored as JPEG. The
+// source is RGBA so reading a pixel does not go through the color interface.
+func makeThumbnail(src *image.RGBA, width int) image.Image {
+	bounds := src.Bounds()
+	if width > bounds.Dx() {
+		width = bounds.Dx()
+	}
+	height := max(1, bounds.Dy()*width/bounds.Dx())
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/height)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/width)
+
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := src.RGBAAt(sx, sy)
+					r += uint32(c.R)
+					g += uint32(c.G)
+					b += uint32(c.B)
+					a += uint32(c.A)
+					n++
+				}
+			}
+			// colors are premultiplied, so adding the missing alpha paints
+			// the pixel onto white
+			white := 255 - a/n
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r/n + white),
+				G: uint8(g/n + white),
+				B: uint8(b/n + white),
+				A: 255,
+			})
+		}
+	}
+	return dst
+}
+
+// storeCover checks an uploaded image, writes it and its thumbnails to storage
+// and points the book at them. The cover the book had before is removed.
+func (a *applicationDependencies) storeCover(r *http.Request, bookID int64, content []byte) error {
+	contentType := http.DetectContentType(content)
+	isAllowed := false
+	for _, allowed := range coverContentTypes {
+		isAllowed = isAllowed || contentType == allowed
+	}
+	if !isAllowed {
+		return fmt.Errorf("%w: must be a JPEG, PNG or GIF image, got %s", errInvalidCover, contentType)
+	}
+
+	// check the dimensions before decoding so a small file cannot claim a
+	// huge image and use up the memory
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidCover, err)
+	}
+	if config.Width < 1 || config.Height < 1 || config.Width*config.Height > coverMaxPixels {
+		return fmt.Errorf("%w: must not have more than %d pixels", errInvalidCover, coverMaxPixels)
+	}
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidCover, err)
+	}
+	// converted once for all the thumbnails
+	rgba, ok := img.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(img.Bounds())
+		draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+
+	// every upload gets its own folder so cached copies of the old cover
+	// are never served for the new one
+	cover := data.CoverImage(fmt.Sprintf("covers/%d/%d", bookID, time.Now().UnixNano()))
+
+	err = a.storage.Put(string(cover)+"/"+data.CoverOriginal, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	for _, size := range data.CoverSizes {
+		var thumbnail bytes.Buffer
+		err = jpeg.Encode(&thumbnail, makeThumbnail(rgba, size.Width), &jpeg.Options{Quality: 85})
+		if err != nil {
+			return err
+		}
+		err = a.storage.Put(string(cover)+"/"+size.Name+".jpg", &thumbnail)
+		if err != nil {
+			return err
+		}
+	}
+
+	var previous data.CoverImage
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		var err error
+		previous, err = m.Books.SetCover(r.Context(), bookID, cover)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditBooks, bookID, data.AuditUpdate, map[string]any{"cover": previous}, map[string]any{"cover": cover})
+	})
+	if err != nil {
+		a.removeCover(cover)
+		return err
+	}
+	if previous != "" {
+		a.removeCover(previous)
+	}
+	return nil
+}
+
+// removeCover deletes a cover that is no longer used. Failing to do so only
+// leaves files behind, so it is logged rather than returned.
+func (a *applicationDependencies) removeCover(cover data.CoverImage) {
+	err := a.storage.DeletePrefix(string(cover))
+	if err != nil {
+		a.logger.Error("could not remove cover", "cover", cover, "error", err)
+	}
+}
+
+func (a *applicationDependencies) uploadCoverHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "bid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	err = a.extendDeadlines(w, time.Minute)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, coverMaxBytes)
+
+	// the image can be the whole body or the 'file' field of a form
+	var file io.Reader = r.Body
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		formFile, _, err := r.FormFile("file")
+		if err != nil {
+			a.badRequestResponse(w, r, errors.New("the image must be uploaded in the 'file' field"))
+			return
+		}
+		defer formFile.Close()
+		file = formFile
+	}
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			a.errorResponseJSON(w, r, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("the image must not be larger than %d bytes", maxBytesError.Limit))
+			return
+		}
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = a.storeCover(r, id, content)
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidCover):
+			a.errorResponseJSON(w, r, http.StatusUnprocessableEntity, err.Error())
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.BIDnotFound(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	book, err := a.bookModel.Get(r.Context(), id)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"Book": book,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// showCoverHandler serves the stored cover images. A new upload gets a new
+// key, so whatever is stored under a key never changes and can be cached.
+func (a *applicationDependencies) showCoverHandler(w http.ResponseWriter, r *http.Request) {
+	key := "covers" + httprouter.ParamsFromContext(r.Context()).ByName("filepath")
+
+	file, err := a.storage.Open(key)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound), errors.Is(err, storage.ErrInvalidKey):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	// ServeContent sniffs the content type from the file itself
+	http.ServeContent(w, r, "", time.Time{}, file)
+}
@@ -0,0 +1,58 @@
+// Filename: cmd/api/database.go
+package main
+
+import (
+	"database/sql"
+	"net/http"
+)
+
+// poolStats is how the statistics of a connection pool are shown.
+type poolStats struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+	MaxIdleClosed      int64  `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64  `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+	Fallbacks          *int64 `json:"fallbacks,omitempty"` // replica reads that went to the primary
+}
+
+func newPoolStats(s sql.DBStats) *poolStats {
+	return &poolStats{
+		MaxOpenConnections: s.MaxOpenConnections,
+		OpenConnections:    s.OpenConnections,
+		InUse:              s.InUse,
+		Idle:               s.Idle,
+		WaitCount:          s.WaitCount,
+		WaitDuration:       s.WaitDuration.String(),
+		MaxIdleClosed:      s.MaxIdleClosed,
+		MaxIdleTimeClosed:  s.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  s.MaxLifetimeClosed,
+	}
+}
+
+func (a *applicationDependencies) databaseStatsHandler(w http.ResponseWriter, r *http.Request) {
+	// the memory storage has no connections to report on
+	if a.db == nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	stats := envelope{
+		"primary": newPoolStats(a.db.Stats()),
+	}
+	if a.replica != nil {
+		replicaStats := a.replica.Stats()
+		replica := newPoolStats(replicaStats.DBStats)
+		replica.Fallbacks = &replicaStats.Fallbacks
+		stats["replica"] = replica
+	}
+
+	err := a.writeJSON(w, http.StatusOK, envelope{"database": stats}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
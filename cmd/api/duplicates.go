@@ -0,0 +1,152 @@
+// Filename: cmd/api/duplicates.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
+	"github.com/Duane-Arzu/test-1.git/internal/validator"
+)
+
+func (a *applicationDependencies) listDuplicateBooksHandler(w http.ResponseWriter, r *http.Request) {
+	var queryParameterData struct {
+		MinSimilarity float64
+		data.Filters
+	}
+
+	queryParameter := r.URL.Query()
+
+	v := validator.New()
+
+	queryParameterData.MinSimilarity = 0.6
+	if value := queryParameter.Get("min_similarity"); value != "" {
+		similarity, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			v.AddError("min_similarity", "must be a number")
+		}
+		queryParameterData.MinSimilarity = similarity
+	}
+	v.Check(queryParameterData.MinSimilarity > 0 && queryParameterData.MinSimilarity <= 1, "min_similarity", "must be greater than 0 and at most 1")
+
+	queryParameterData.Filters.Page = a.getSingleIntegerParameter(queryParameter, "page", 1, v)
+	queryParameterData.Filters.PageSize = a.getSingleIntegerParameter(queryParameter, "page_size", 20, v)
+	queryParameterData.Filters.Sort = "similarity"
+	queryParameterData.Filters.SortSafeList = []string{"similarity"}
+
+	data.ValidateFilters(v, queryParameterData.Filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	candidates, metadata, err := a.bookModel.FindDuplicates(r.Context(), queryParameterData.MinSimilarity, queryParameterData.Filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"duplicates": candidates,
+		"@metadata":  metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) mergeBookHandler(w http.ResponseWriter, r *http.Request) {
+	// the book in the URL is the one that is kept
+	canonicalID, err := a.readIDParam(r, "bid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var incomingData struct {
+		DuplicateID int64 `json:"duplicate_id"`
+	}
+	err = a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(incomingData.DuplicateID > 0, "duplicate_id", "must be a positive integer")
+	v.Check(incomingData.DuplicateID != canonicalID, "duplicate_id", "must not be the book that is kept")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := a.contextGetUser(r)
+	var book *data.Book
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		// both books as they were, for the audit log
+		canonical, err := m.Books.Get(r.Context(), canonicalID)
+		if err != nil {
+			return err
+		}
+		duplicate, err := m.Books.Get(r.Context(), incomingData.DuplicateID)
+		if err != nil {
+			return err
+		}
+
+		err = m.Books.Merge(r.Context(), canonicalID, incomingData.DuplicateID, user.ID)
+		if err != nil {
+			return err
+		}
+		book, err = m.Books.Get(r.Context(), canonicalID)
+		if err != nil {
+			return err
+		}
+
+		err = a.audit(m, r, auditBooks, canonicalID, data.AuditUpdate, canonical, book)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditBooks, duplicate.ID, data.AuditDelete, duplicate, nil)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"Book":    book,
+		"message": fmt.Sprintf("Book with id = %d was merged into book with id = %d", incomingData.DuplicateID, canonicalID),
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// redirectMergedBook sends a 301 to the canonical book if the id belongs to a
+// book that was merged away. It reports whether a response was written.
+func (a *applicationDependencies) redirectMergedBook(w http.ResponseWriter, r *http.Request, id int64) bool {
+	newID, err := a.bookModel.GetRedirect(r.Context(), id)
+	if err != nil {
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			a.serverErrorResponse(w, r, err)
+			return true
+		}
+		return false
+	}
+
+	location := fmt.Sprintf("/api/v1/books/%d", newID)
+	if r.URL.RawQuery != "" {
+		location += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, location, http.StatusMovedPermanently)
+	return true
+}
@@ -0,0 +1,209 @@
+// Filename: cmd/api/epub.go
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
+	"github.com/Duane-Arzu/test-1.git/internal/epub"
+	"github.com/Duane-Arzu/test-1.git/internal/validator"
+)
+
+const epubMaxBytes = 50 << 20 // 50MB
+
+// bookFromEPUB pre-fills a book from the metadata of an EPUB.
+func bookFromEPUB(meta *epub.Metadata) *data.Book {
+	book := &data.Book{
+		Title:           meta.Title,
+		Authors:         strings.Join(meta.Creators, ", "),
+		ISBN:            data.NormalizeISBN(meta.ISBN()),
+		PublicationDate: epubPublicationDate(meta.Date),
+		Description:     meta.Description,
+		Format:          "ebook",
+		Publisher:       meta.Publisher,
+		Language:        meta.Language,
+	}
+	if len(book.ISBN) == 10 {
+		book.ISBN = data.ISBN10To13(book.ISBN)
+	}
+	if len(meta.Subjects) > 0 {
+		book.Genre = meta.Subjects[0]
+	}
+	return book
+}
+
+// epubPublicationDate turns the W3C dates used by EPUB into the "July 12, 2020"
+// form used in the catalog. Dates it does not understand are kept as they are.
+func epubPublicationDate(date string) string {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", time.DateOnly} {
+		t, err := time.Parse(layout, date)
+		if err == nil {
+			return t.Format("January 2, 2006")
+		}
+	}
+	return date
+}
+
+func (a *applicationDependencies) createBookFromEPUBHandler(w http.ResponseWriter, r *http.Request) {
+	err := a.extendDeadlines(w, time.Minute)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, epubMaxBytes)
+
+	var file io.ReaderAt
+	var size int64
+	// fields sent along with a form upload override what the EPUB says
+	overrides := make(map[string]string)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		formFile, header, err := r.FormFile("file")
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				a.badRequestResponse(w, r, fmt.Errorf("the EPUB must not be larger than %d bytes", maxBytesError.Limit))
+				return
+			}
+			a.badRequestResponse(w, r, errors.New("the EPUB must be uploaded in the 'file' field"))
+			return
+		}
+		defer formFile.Close()
+		file, size = formFile, header.Size
+		for key, values := range r.MultipartForm.Value {
+			if len(values) > 0 {
+				overrides[key] = strings.TrimSpace(values[0])
+			}
+		}
+	case "application/epub+zip":
+		content, err := io.ReadAll(r.Body)
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				a.badRequestResponse(w, r, fmt.Errorf("the EPUB must not be larger than %d bytes", maxBytesError.Limit))
+				return
+			}
+			a.badRequestResponse(w, r, err)
+			return
+		}
+		file, size = bytes.NewReader(content), int64(len(content))
+	default:
+		a.errorResponseJSON(w, r, http.StatusUnsupportedMediaType,
+			"the Content-Type must be application/epub+zip or multipart/form-data")
+		return
+	}
+
+	meta, err := epub.Read(file, size)
+	if err != nil {
+		if errors.Is(err, epub.ErrNotEPUB) {
+			a.badRequestResponse(w, r, err)
+			return
+		}
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	book := bookFromEPUB(meta)
+
+	v := validator.New()
+	for key, value := range overrides {
+		switch key {
+		case "title":
+			book.Title = value
+		case "authors":
+			book.Authors = value
+		case "isbn":
+			book.ISBN = data.NormalizeISBN(value)
+		case "publication_date":
+			book.PublicationDate = value
+		case "genre":
+			book.Genre = value
+		case "description":
+			book.Description = value
+		case "format":
+			book.Format = value
+		case "publisher":
+			book.Publisher = value
+		case "language":
+			book.Language = value
+		case "work_id":
+			book.WorkID, err = strconv.ParseInt(value, 10, 64)
+			v.Check(err == nil, "work_id", "must be an integer value")
+		case "page_count":
+			book.PageCount, err = strconv.Atoi(value)
+			v.Check(err == nil, "page_count", "must be an integer value")
+		}
+	}
+
+	// a dry run shows what would be created so the client can correct it
+	if r.URL.Query().Get("dry_run") == "true" {
+		data.ValidateBook(v, book)
+		data := envelope{
+			"Book":   book,
+			"epub":   meta,
+			"errors": v.Errors,
+		}
+		err = a.writeJSON(w, http.StatusOK, data, nil)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data.ValidateBook(v, book)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	if !a.checkWorkExists(w, r, v, book.WorkID) {
+		return
+	}
+
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		err := m.Books.Insert(r.Context(), book)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditBooks, book.ID, data.AuditCreate, nil, book)
+	})
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// the book is created either way, a cover that cannot be used is only logged
+	if meta.Cover != nil {
+		err = a.storeCover(r, book.ID, meta.Cover.Data)
+		if err != nil {
+			a.logError(r, err)
+		} else {
+			book, err = a.bookModel.Get(r.Context(), book.ID)
+			if err != nil {
+				a.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/books/%d", book.ID))
+
+	data := envelope{
+		"Book": book,
+		"epub": meta,
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
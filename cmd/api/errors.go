@@ -4,6 +4,8 @@ package main
 import (
 	"fmt"
 	"net/http"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
 )
 
 func (a *applicationDependencies) logError(r *http.Request, err error) {
@@ -31,12 +33,37 @@ func (a *applicationDependencies) serverErrorResponse(w http.ResponseWriter,
 	r *http.Request,
 	err error) {
 
+	if data.IsCanceled(err) {
+		a.queryCanceledResponse(w, r, err)
+		return
+	}
+
 	a.logError(r, err)
 
 	message := "the server encountered a problem and could not process your request"
 	a.errorResponseJSON(w, r, http.StatusInternalServerError, message)
 }
 
+// statusClientClosedRequest is the nginx convention for a request the client
+// gave up on before the response was ready.
+const statusClientClosedRequest = 499
+
+// queryCanceledResponse is used when a query was stopped by its context rather
+// than failing. If the request itself is done, the client left or the server
+// is shutting down and nobody reads the response; otherwise the query ran
+// past the query timeout.
+func (a *applicationDependencies) queryCanceledResponse(w http.ResponseWriter, r *http.Request, err error) {
+	if r.Context().Err() != nil {
+		a.logger.Warn("request canceled", "method", r.Method, "uri", r.URL.RequestURI(), "error", err)
+		a.errorResponseJSON(w, r, statusClientClosedRequest, "the request was canceled")
+		return
+	}
+
+	a.logError(r, err)
+	message := "the database took too long to respond, please try again"
+	a.errorResponseJSON(w, r, http.StatusServiceUnavailable, message)
+}
+
 func (a *applicationDependencies) notFoundResponse(w http.ResponseWriter,
 	r *http.Request) {
 
@@ -88,6 +115,11 @@ func (a *applicationDependencies) editConflictResponse(w http.ResponseWriter, r
 	a.errorResponseJSON(w, r, http.StatusConflict, message)
 }
 
+func (a *applicationDependencies) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the record has changed since you last fetched it, please fetch it again"
+	a.errorResponseJSON(w, r, http.StatusPreconditionFailed, message)
+}
+
 func (a *applicationDependencies) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
 	message := "invalid authentication credentials"
 	a.errorResponseJSON(w, r, http.StatusUnauthorized, message)
@@ -110,3 +142,8 @@ func (a *applicationDependencies) inactiveAccountResponse(w http.ResponseWriter,
 	message := "your user account must be activated to access this resource"
 	a.errorResponseJSON(w, r, http.StatusForbidden, message)
 }
+
+func (a *applicationDependencies) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	a.errorResponseJSON(w, r, http.StatusForbidden, message)
+}
@@ -0,0 +1,101 @@
+// Filename: cmd/api/etags.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
+)
+
+// errPreconditionFailed is returned from a unit of work that found the
+// record no longer matches the request's If-Match header.
+var errPreconditionFailed = errors.New("precondition failed")
+
+// etag is the entity tag of a record at the given version.
+func etag[V int | int16 | int32 | int64](version V) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// readingListETag covers the list and the books on it, since adding, moving
+// or removing a book changes what a client sees without touching the list's
+// own version.
+func readingListETag(list *data.ReadingList, books []*data.BooksInList) string {
+	h := fnv.New32a()
+	for _, book := range books {
+		fmt.Fprintf(h, "%d:%d:%s:%d;", book.BookID, book.Position, book.Status, book.Version)
+	}
+	return fmt.Sprintf(`"%d-%08x"`, list.Version, h.Sum32())
+}
+
+// bookETag covers everything shown of a book: its ratings and series, and
+// the work shown with ?include=work, all change without a new version of the
+// book itself. The version comes first, for ifVersionMatchFails.
+func bookETag(book *data.Book, include string, work *data.Work) string {
+	h := fnv.New32a()
+	enc := json.NewEncoder(h)
+	enc.Encode(book)
+	fmt.Fprintf(h, "include=%s;", include)
+	enc.Encode(work)
+	return fmt.Sprintf(`"%d-%08x"`, book.Version, h.Sum32())
+}
+
+// matchesETag reports whether an If-Match or If-None-Match header lists tag.
+// If-None-Match compares weakly, so a W/ prefix is ignored there.
+func matchesETag(header, tag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchFails reports whether the request only applies to another version
+// of the record than the one tagged tag.
+func ifMatchFails(r *http.Request, tag string) bool {
+	header := r.Header.Get("If-Match")
+	return header != "" && !matchesETag(header, tag, false)
+}
+
+// ifVersionMatchFails is ifMatchFails for tags that start with the version,
+// like bookETag's. A change only depends on the version of the record itself,
+// so a tag of any representation of that version will do.
+func ifVersionMatchFails[V int | int16 | int32 | int64](r *http.Request, version V) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.Trim(strings.TrimSpace(candidate), `"`)
+		if candidate == "*" {
+			return false
+		}
+		candidate, _, _ = strings.Cut(candidate, "-")
+		if n, err := strconv.ParseInt(candidate, 10, 64); err == nil && n == int64(version) {
+			return false
+		}
+	}
+	return true
+}
+
+// notModified answers a conditional GET with 304 when the client already has
+// the version tagged tag, and reports whether it did.
+func (a *applicationDependencies) notModified(w http.ResponseWriter, r *http.Request, tag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !matchesETag(header, tag, true) {
+		return false
+	}
+	w.Header().Set("ETag", tag)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,399 @@
+// Filename: cmd/api/export.go
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
+	"github.com/Duane-Arzu/test-1.git/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// accounts with more records than this get their export emailed instead
+	// of built while the client waits
+	exportInlineLimit = 1000
+	exportTokenTTL    = 48 * time.Hour
+)
+
+var exportFormats = []string{"json", "csv", "goodreads"}
+
+// writeExportArchive writes the export as a ZIP archive in the given format.
+func writeExportArchive(w io.Writer, export *data.UserExport, format string) error {
+	archive := zip.NewWriter(w)
+
+	var err error
+	switch format {
+	case "json":
+		err = writeExportJSON(archive, export)
+	case "csv":
+		err = writeExportCSV(archive, export)
+	case "goodreads":
+		err = writeExportGoodreads(archive, export)
+	default:
+		err = fmt.Errorf("unknown export format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func writeExportJSON(archive *zip.Writer, export *data.UserExport) error {
+	files := []struct {
+		name    string
+		content any
+	}{
+		{"profile.json", export.Profile},
+		{"reading_lists.json", export.Lists},
+		{"reviews.json", export.Reviews},
+		{"progress.json", export.Progress},
+		{"goals.json", export.Goals},
+	}
+	for _, file := range files {
+		js, err := json.MarshalIndent(file.content, "", "\t")
+		if err != nil {
+			return err
+		}
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(append(js, '\n'))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCSVFile adds a CSV file with the header and rows to the archive.
+func writeCSVFile(archive *zip.Writer, name string, header []string, rows [][]string) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(f)
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+	err = writer.WriteAll(rows)
+	if err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+func formatExportTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func writeExportCSV(archive *zip.Writer, export *data.UserExport) error {
+	profile := export.Profile
+	err := writeCSVFile(archive, "profile.csv",
+		[]string{"id", "username", "email", "activated", "created_at"},
+		[][]string{{strconv.FormatInt(profile.ID, 10), profile.Username, profile.Email,
+			strconv.FormatBool(profile.Activated), formatExportTime(&profile.CreatedAt)}})
+	if err != nil {
+		return err
+	}
+
+	var rows [][]string
+	for _, list := range export.Lists {
+		listColumns := []string{strconv.FormatInt(list.ID, 10), list.Name, list.Description, strconv.FormatBool(list.IsPublic)}
+		if len(list.Items) == 0 {
+			rows = append(rows, append(listColumns, "", "", "", "", "", "", "", ""))
+			continue
+		}
+		for _, item := range list.Items {
+			rows = append(rows, append(slices.Clone(listColumns),
+				strconv.FormatInt(item.BookID, 10), item.Title, item.Authors, item.ISBN, item.Status,
+				strconv.Itoa(item.Position), formatExportTime(&item.AddedAt), formatExportTime(item.CompletedAt)))
+		}
+	}
+	err = writeCSVFile(archive, "reading_lists.csv",
+		[]string{"list_id", "list_name", "list_description", "public",
+			"book_id", "title", "authors", "isbn", "status", "position", "added_at", "completed_at"}, rows)
+	if err != nil {
+		return err
+	}
+
+	rows = nil
+	for _, review := range export.Reviews {
+		rows = append(rows, []string{strconv.FormatInt(review.ID, 10), strconv.FormatInt(review.BookID, 10),
+			review.Title, review.Authors, review.ISBN, strconv.FormatInt(review.Rating, 10), review.Review,
+			formatExportTime(&review.ReviewedAt)})
+	}
+	err = writeCSVFile(archive, "reviews.csv",
+		[]string{"id", "book_id", "title", "authors", "isbn", "rating", "review", "reviewed_at"}, rows)
+	if err != nil {
+		return err
+	}
+
+	rows = nil
+	for _, progress := range export.Progress {
+		rows = append(rows, []string{strconv.FormatInt(progress.ID, 10), strconv.FormatInt(progress.BookID, 10),
+			strconv.Itoa(progress.PagesRead), progress.LoggedOn.Format(time.DateOnly)})
+	}
+	err = writeCSVFile(archive, "progress.csv", []string{"id", "book_id", "pages_read", "logged_on"}, rows)
+	if err != nil {
+		return err
+	}
+
+	rows = nil
+	for _, goal := range export.Goals {
+		rows = append(rows, []string{strconv.Itoa(goal.Year), strconv.Itoa(goal.TargetBooks), strconv.Itoa(goal.TargetPages)})
+	}
+	return writeCSVFile(archive, "goals.csv", []string{"year", "target_books", "target_pages"}, rows)
+}
+
+// writeExportGoodreads writes the library in the layout of a Goodreads
+// export so it can be imported there (or back here through the Goodreads
+// import). Every book becomes one row with its lists as shelves.
+func writeExportGoodreads(archive *zip.Writer, export *data.UserExport) error {
+	type libraryBook struct {
+		id           int64
+		title        string
+		authors      string
+		isbn         string
+		rating       int64
+		review       string
+		dateRead     *time.Time
+		dateAdded    *time.Time
+		shelves      []string
+		furthestRead int // 0 to read, 1 currently reading, 2 completed
+	}
+	progressOf := map[string]int{"to read": 0, "currently reading": 1, "completed": 2}
+	statusOf := []string{"to read", "currently reading", "completed"}
+
+	library := make(map[int64]*libraryBook)
+	get := func(id int64, title, authors, isbn string) *libraryBook {
+		book, ok := library[id]
+		if !ok {
+			book = &libraryBook{id: id, title: title, authors: authors, isbn: isbn}
+			library[id] = book
+		}
+		return book
+	}
+
+	for _, list := range export.Lists {
+		for _, item := range list.Items {
+			book := get(item.BookID, item.Title, item.Authors, item.ISBN)
+			// the Goodreads status shelves are written from the status, not
+			// repeated as custom shelves
+			if !validator.PermittedValue(list.Name, "read", "currently-reading", "to-read") {
+				book.shelves = append(book.shelves, list.Name)
+			}
+			if progressOf[item.Status] > book.furthestRead {
+				book.furthestRead = progressOf[item.Status]
+			}
+			if book.dateAdded == nil || item.AddedAt.Before(*book.dateAdded) {
+				added := item.AddedAt
+				book.dateAdded = &added
+			}
+			if item.CompletedAt != nil && (book.dateRead == nil || item.CompletedAt.After(*book.dateRead)) {
+				book.dateRead = item.CompletedAt
+			}
+		}
+	}
+	for _, review := range export.Reviews {
+		book := get(review.BookID, review.Title, review.Authors, review.ISBN)
+		book.rating = review.Rating
+		book.review = review.Review
+		// a reviewed book that is on no list has been read
+		if len(book.shelves) == 0 && book.dateAdded == nil {
+			book.furthestRead = 2
+			reviewed := review.ReviewedAt
+			book.dateAdded = &reviewed
+		}
+	}
+
+	books := make([]*libraryBook, 0, len(library))
+	for _, book := range library {
+		books = append(books, book)
+	}
+	sort.Slice(books, func(i, j int) bool { return books[i].id < books[j].id })
+
+	goodreadsDate := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format("2006/01/02")
+	}
+
+	var rows [][]string
+	for _, book := range books {
+		isbn, isbn13 := "", ""
+		if len(book.isbn) == 13 {
+			isbn13 = book.isbn
+		} else {
+			isbn = book.isbn
+		}
+		exclusiveShelf := data.GoodreadsShelfForStatus(statusOf[book.furthestRead])
+		shelves := append([]string{exclusiveShelf}, book.shelves...)
+		rows = append(rows, []string{
+			strconv.FormatInt(book.id, 10),
+			book.title,
+			book.authors,
+			`="` + isbn + `"`,
+			`="` + isbn13 + `"`,
+			strconv.FormatInt(book.rating, 10),
+			goodreadsDate(book.dateRead),
+			goodreadsDate(book.dateAdded),
+			strings.Join(shelves, ", "),
+			exclusiveShelf,
+			strings.ReplaceAll(book.review, "\n", "<br/>"),
+		})
+	}
+
+	return writeCSVFile(archive, "goodreads_library_export.csv",
+		[]string{"Book Id", "Title", "Author", "ISBN", "ISBN13", "My Rating", "Date Read", "Date Added",
+			"Bookshelves", "Exclusive Shelf", "My Review"}, rows)
+}
+
+// writeExportDownload sends an archive to the client as a file download.
+func writeExportDownload(w http.ResponseWriter, archive []byte, format string, generatedAt time.Time) error {
+	filename := fmt.Sprintf("bookclub-export-%s-%s.zip", format, generatedAt.Format("20060102"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(archive)
+	return err
+}
+
+func (a *applicationDependencies) exportUserDataHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readUserIDParam(r, "uid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	// people can only export their own data
+	user := a.contextGetUser(r)
+	if id != user.ID {
+		a.notPermittedResponse(w, r)
+		return
+	}
+
+	format := a.getSingleQueryParameter(r.URL.Query(), "format", "json")
+	v := validator.New()
+	v.Check(validator.PermittedValue(format, exportFormats...), "format", "must be one of "+strings.Join(exportFormats, ", "))
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	size, err := a.exportModel.Size(r.Context(), user.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if size <= exportInlineLimit {
+		export, err := a.exportModel.GetForUser(r.Context(), user.ID)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		var archive bytes.Buffer
+		err = writeExportArchive(&archive, export, format)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		err = writeExportDownload(w, archive.Bytes(), format, export.GeneratedAt)
+		if err != nil {
+			a.logError(r, err)
+		}
+		return
+	}
+
+	// large accounts get the archive built in the background and a link by
+	// email, which goes on after the request is done
+	ctx := context.WithoutCancel(r.Context())
+	a.background(func() {
+		export, err := a.exportModel.GetForUser(ctx, user.ID)
+		if err != nil {
+			a.logger.Error("could not collect export", "user_id", user.ID, "error", err)
+			return
+		}
+		var archive bytes.Buffer
+		err = writeExportArchive(&archive, export, format)
+		if err != nil {
+			a.logger.Error("could not build export", "user_id", user.ID, "error", err)
+			return
+		}
+		token, err := a.exportModel.SaveArchive(ctx, user.ID, format, archive.Bytes(), exportTokenTTL)
+		if err != nil {
+			a.logger.Error("could not save export", "user_id", user.ID, "error", err)
+			return
+		}
+
+		data := map[string]any{
+			"username":     user.Username,
+			"format":       format,
+			"downloadPath": "/api/v1/exports/" + token.Plaintext,
+			"expiry":       token.Expiry.Format(time.RFC1123),
+		}
+		err = a.mailer.Send(user.Email, "user_export.tmpl", data)
+		if err != nil {
+			a.logger.Error(err.Error())
+		}
+	})
+
+	data := envelope{
+		"message": fmt.Sprintf("your export is being prepared, a download link will be sent to %s", user.Email),
+	}
+	err = a.writeJSON(w, http.StatusAccepted, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// downloadExportHandler serves an emailed export. The token in the URL is
+// all that is needed, like a share link.
+func (a *applicationDependencies) downloadExportHandler(w http.ResponseWriter, r *http.Request) {
+	token := httprouter.ParamsFromContext(r.Context()).ByName("token")
+
+	v := validator.New()
+	data.ValidateTokenPlaintext(v, token)
+	if !v.IsEmpty() {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	archive, err := a.exportModel.GetArchive(r.Context(), token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = writeExportDownload(w, archive.Archive, archive.Format, archive.CreatedAt)
+	if err != nil {
+		a.logError(r, err)
+	}
+}
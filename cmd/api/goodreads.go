@@ -0,0 +1,332 @@
+// Filename: cmd/api/goodreads.go
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
+)
+
+const (
+	goodreadsMaxBytes = 10 << 20 // 10MB, a library of several thousand books
+	// progress is saved every so many rows so polling clients see it move
+	goodreadsProgressEvery = 25
+)
+
+// goodreadsRequiredColumns are the columns of a Goodreads library export the
+// import cannot do without.
+var goodreadsRequiredColumns = []string{"Title", "Author", "ISBN", "ISBN13", "My Rating", "Exclusive Shelf"}
+
+// readGoodreadsExport parses a Goodreads library export. The whole file is
+// read up front because the import runs after the request has finished.
+func readGoodreadsExport(r io.Reader) ([]*data.GoodreadsEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("the file is empty")
+		}
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	for _, name := range goodreadsRequiredColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("the file is not a Goodreads library export, the %q column is missing", name)
+		}
+	}
+
+	var entries []*data.GoodreadsEntry
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		date := func(name string) *time.Time {
+			t, err := time.Parse("2006/01/02", field(name))
+			if err != nil {
+				return nil
+			}
+			return &t
+		}
+
+		entry := &data.GoodreadsEntry{
+			Title:          field("Title"),
+			Author:         field("Author"),
+			ISBN:           data.NormalizeISBN(field("ISBN")), // exported as ="0441172717"
+			ISBN13:         data.NormalizeISBN(field("ISBN13")),
+			Publisher:      field("Publisher"),
+			Binding:        field("Binding"),
+			YearPublished:  field("Original Publication Year"),
+			DateRead:       date("Date Read"),
+			DateAdded:      date("Date Added"),
+			ExclusiveShelf: field("Exclusive Shelf"),
+			Review:         strings.ReplaceAll(field("My Review"), "<br/>", "\n"),
+		}
+		if entry.YearPublished == "" {
+			entry.YearPublished = field("Year Published")
+		}
+		entry.MyRating, _ = strconv.Atoi(field("My Rating"))
+		entry.Pages, _ = strconv.Atoi(field("Number of Pages"))
+
+		if entry.ExclusiveShelf == "" {
+			entry.ExclusiveShelf = "to-read"
+		}
+		entry.Shelves = []string{entry.ExclusiveShelf}
+		for _, shelf := range strings.Split(field("Bookshelves"), ",") {
+			shelf = strings.TrimSpace(shelf)
+			if shelf != "" && shelf != entry.ExclusiveShelf {
+				entry.Shelves = append(entry.Shelves, shelf)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (a *applicationDependencies) createGoodreadsImportHandler(w http.ResponseWriter, r *http.Request) {
+	err := a.extendDeadlines(w, time.Minute)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, goodreadsMaxBytes)
+
+	// the export can be uploaded as a form file or as the raw body
+	var file io.Reader = r.Body
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		formFile, _, err := r.FormFile("file")
+		if err != nil {
+			a.badRequestResponse(w, r, errors.New("the export must be uploaded in the 'file' field"))
+			return
+		}
+		defer formFile.Close()
+		file = formFile
+	case "text/csv":
+	default:
+		a.errorResponseJSON(w, r, http.StatusUnsupportedMediaType,
+			"the Content-Type must be text/csv or multipart/form-data")
+		return
+	}
+
+	entries, err := readGoodreadsExport(file)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			a.badRequestResponse(w, r, fmt.Errorf("the export must not be larger than %d bytes", maxBytesError.Limit))
+			return
+		}
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := a.contextGetUser(r)
+	job := &data.ImportJob{
+		UserID:    user.ID,
+		Source:    "goodreads",
+		TotalRows: len(entries),
+	}
+	// what the job goes on to import shows up on the job itself
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		err := m.ImportJobs.Insert(r.Context(), job)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditImportJobs, job.ID, data.AuditCreate, nil, job)
+	})
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// the worker gets its own copy, the one below is written to the response,
+	// and keeps going after the request is done, logging what it imports as
+	// the user's changes made through this request
+	running := *job
+	ctx := context.WithoutCancel(r.Context())
+	origin := a.auditOrigin(r)
+	origin.actorID = &running.UserID
+	a.background(func() {
+		a.runGoodreadsImport(ctx, origin, &running, entries)
+	})
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/imports/%d", job.ID))
+
+	data := envelope{
+		"import": job,
+	}
+	err = a.writeJSON(w, http.StatusAccepted, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// runGoodreadsImport works through the entries of an import job, saving the
+// progress as it goes. Entries that fail are recorded on the job and skipped.
+// Each entry is imported in a unit of work of its own, along with the audit
+// events of what it adds.
+func (a *applicationDependencies) runGoodreadsImport(ctx context.Context, origin auditOrigin, job *data.ImportJob, entries []*data.GoodreadsEntry) {
+	save := func() {
+		err := a.importJobModel.UpdateProgress(ctx, job)
+		if err != nil {
+			a.logger.Error("could not save import progress", "import_id", job.ID, "error", err)
+		}
+	}
+	finish := func(status string) {
+		now := time.Now()
+		job.Status = status
+		job.FinishedAt = &now
+		save()
+	}
+	// a panic would otherwise leave the job running forever
+	defer func() {
+		if job.Status == data.ImportJobRunning {
+			finish(data.ImportJobFailed)
+		}
+	}()
+
+	job.Status = data.ImportJobRunning
+	save()
+
+	lists := make(map[string]int64)
+	for i, entry := range entries {
+		row := i + 1
+
+		var result *data.GoodreadsResult
+		ensured := make(map[string]int64)
+		err := a.transactor.WithinTx(ctx, func(m data.Models) error {
+			var listIDs []int64
+			for _, shelf := range entry.Shelves {
+				id, ok := lists[shelf]
+				if !ok {
+					list, created, err := m.ImportJobs.EnsureGoodreadsList(ctx, job.UserID, shelf)
+					if err != nil {
+						return err
+					}
+					if created {
+						err = origin.record(ctx, m, auditReadingLists, list.ID, data.AuditCreate, nil, list)
+						if err != nil {
+							return err
+						}
+					}
+					id = list.ID
+					ensured[shelf] = id
+				}
+				listIDs = append(listIDs, id)
+			}
+
+			var err error
+			result, err = m.ImportJobs.ImportGoodreadsEntry(ctx, job.UserID, entry, listIDs)
+			if err != nil {
+				return err
+			}
+			if result.Book != nil {
+				err = origin.record(ctx, m, auditBooks, result.Book.ID, data.AuditCreate, nil, result.Book)
+				if err != nil {
+					return err
+				}
+			}
+			for _, added := range result.Entries {
+				err = origin.record(ctx, m, auditReadingLists, added.ReadingListID, data.AuditUpdate, nil, map[string]any{"book": added})
+				if err != nil {
+					return err
+				}
+			}
+			if result.Review != nil {
+				return origin.record(ctx, m, auditReviews, result.Review.ReviewID, data.AuditCreate, nil, result.Review)
+			}
+			return nil
+		})
+
+		var bookErr *data.GoodreadsBookError
+		switch {
+		case err == nil:
+			// lists created for an entry that failed were rolled back with it
+			maps.Copy(lists, ensured)
+			if result.Book != nil {
+				job.BooksCreated++
+			} else {
+				job.BooksMatched++
+			}
+			job.ListEntries += len(result.Entries)
+			if result.Review != nil {
+				job.Reviews++
+			}
+		case errors.Is(err, data.ErrGoodreadsNoISBN), errors.As(err, &bookErr):
+			job.AddError(row, fmt.Sprintf("%q: %s", entry.Title, err))
+		default:
+			a.logger.Error("could not import goodreads row", "import_id", job.ID, "row", row, "error", err)
+			job.AddError(row, fmt.Sprintf("%q could not be imported", entry.Title))
+		}
+
+		job.ProcessedRows = row
+		if row%goodreadsProgressEvery == 0 {
+			save()
+		}
+	}
+
+	finish(data.ImportJobCompleted)
+}
+
+func (a *applicationDependencies) showImportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "id")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	job, err := a.importJobModel.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// other users' imports are not revealed to exist
+	user := a.contextGetUser(r)
+	if job.UserID != user.ID {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	data := envelope{
+		"import": job,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Duane-Arzu/test-1.git/internal/validator"
 
@@ -170,3 +171,32 @@ func (a *applicationDependencies) background(fn func()) {
 		fn() // Run the actual function
 	}()
 }
+
+// readUserIDParam reads a user id from the URL. The value "me" is resolved to
+// the id of the authenticated user making the request.
+func (a *applicationDependencies) readUserIDParam(r *http.Request, sid string) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	if params.ByName(sid) == "me" {
+		user := a.contextGetUser(r)
+		if user.IsAnonymous() {
+			return 0, errors.New("invalid ID parameter")
+		}
+		return user.ID, nil
+	}
+	return a.readIDParam(r, sid)
+}
+
+// extendDeadlines gives a request that uploads a file more time than the
+// server timeouts allow. Response writers that cannot change their
+// deadlines (such as the ones used in tests) are left alone.
+func (a *applicationDependencies) extendDeadlines(w http.ResponseWriter, d time.Duration) error {
+	controller := http.NewResponseController(w)
+	deadline := time.Now().Add(d)
+	for _, setDeadline := range []func(time.Time) error{controller.SetReadDeadline, controller.SetWriteDeadline} {
+		err := setDeadline(deadline)
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+	}
+	return nil
+}
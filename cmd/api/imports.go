@@ -0,0 +1,397 @@
+// Filename: cmd/api/imports.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
+	"github.com/Duane-Arzu/test-1.git/internal/validator"
+)
+
+const (
+	importBatchSize = 100
+	importMaxBytes  = 50 << 20 // 50MB
+	importTimeout   = 5 * time.Minute
+)
+
+// bookRowReader hands out the rows of an upload one at a time. Problems with
+// a single row are returned in the map so the rest of the file can still be
+// imported; a non-nil error means the upload itself cannot be read further.
+type bookRowReader interface {
+	Next() (*data.Book, map[string]string, error)
+}
+
+// importBookRow is a row as it appears in a JSON Lines upload.
+type importBookRow struct {
+	Title           string `json:"title"`
+	Authors         string `json:"authors"`
+	ISBN            string `json:"isbn"`
+	PublicationDate string `json:"publication_date"`
+	Genre           string `json:"genre"`
+	Description     string `json:"description"`
+	WorkID          int64  `json:"work_id"`
+	Format          string `json:"format"`
+	Publisher       string `json:"publisher"`
+	Language        string `json:"language"`
+	PageCount       int    `json:"page_count"`
+}
+
+func (row importBookRow) book() *data.Book {
+	return &data.Book{
+		Title:           row.Title,
+		Authors:         row.Authors,
+		ISBN:            data.NormalizeISBN(row.ISBN),
+		PublicationDate: row.PublicationDate,
+		Genre:           row.Genre,
+		Description:     row.Description,
+		WorkID:          row.WorkID,
+		Format:          row.Format,
+		Publisher:       row.Publisher,
+		Language:        row.Language,
+		PageCount:       row.PageCount,
+	}
+}
+
+type jsonlBookReader struct {
+	scanner *bufio.Scanner
+}
+
+func newJSONLBookReader(r io.Reader) *jsonlBookReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20) // a single line may be up to 1MB
+	return &jsonlBookReader{scanner: scanner}
+}
+
+func (j *jsonlBookReader) Next() (*data.Book, map[string]string, error) {
+	for j.scanner.Scan() {
+		line := bytes.TrimSpace(j.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var row importBookRow
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&row)
+		if err != nil {
+			return nil, map[string]string{"row": "is not a valid JSON object for a book: " + err.Error()}, nil
+		}
+		return row.book(), nil, nil
+	}
+
+	err := j.scanner.Err()
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, nil, io.EOF
+}
+
+type csvBookReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+var importCSVColumns = []string{"title", "authors", "isbn", "publication_date", "genre", "description",
+	"work_id", "format", "publisher", "language", "page_count"}
+
+func newCSVBookReader(r io.Reader) (*csvBookReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // short rows are reported per row below
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("the CSV file must have a header row")
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !validator.PermittedValue(name, importCSVColumns...) {
+			return nil, fmt.Errorf("the CSV header contains unknown column %q", name)
+		}
+		columns[name] = i
+	}
+	for _, required := range []string{"title", "isbn"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("the CSV header must contain a %q column", required)
+		}
+	}
+
+	return &csvBookReader{reader: reader, columns: columns}, nil
+}
+
+func (c *csvBookReader) Next() (*data.Book, map[string]string, error) {
+	record, err := c.reader.Read()
+	if err != nil {
+		var parseError *csv.ParseError
+		if errors.As(err, &parseError) {
+			return nil, map[string]string{"row": parseError.Err.Error()}, nil
+		}
+		return nil, nil, err
+	}
+
+	field := func(name string) string {
+		i, ok := c.columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rowErrors := make(map[string]string)
+	var row importBookRow
+	row.Title = field("title")
+	row.Authors = field("authors")
+	row.ISBN = field("isbn")
+	row.PublicationDate = field("publication_date")
+	row.Genre = field("genre")
+	row.Description = field("description")
+	row.Format = field("format")
+	row.Publisher = field("publisher")
+	row.Language = field("language")
+	if value := field("work_id"); value != "" {
+		row.WorkID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			rowErrors["work_id"] = "must be an integer value"
+		}
+	}
+	if value := field("page_count"); value != "" {
+		row.PageCount, err = strconv.Atoi(value)
+		if err != nil {
+			rowErrors["page_count"] = "must be an integer value"
+		}
+	}
+	if len(rowErrors) > 0 {
+		return nil, rowErrors, nil
+	}
+	return row.book(), nil, nil
+}
+
+// pendingImportRow is a valid row waiting for its batch to be inserted.
+type pendingImportRow struct {
+	result *data.ImportRowResult
+	book   *data.Book
+}
+
+func (a *applicationDependencies) importBooksHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	mode := a.getSingleQueryParameter(r.URL.Query(), "mode", "best_effort")
+	v.Check(validator.PermittedValue(mode, "best_effort", "atomic"), "mode", "must be 'best_effort' or 'atomic'")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Uploads are streamed row by row so they are not held to the readJSON
+	// size limit, but they get a limit and a deadline of their own
+	r.Body = http.MaxBytesReader(w, r.Body, importMaxBytes)
+	err := a.extendDeadlines(w, importTimeout)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var rows bookRowReader
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		reader, err := newCSVBookReader(r.Body)
+		if err != nil {
+			a.badRequestResponse(w, r, err)
+			return
+		}
+		rows = reader
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		rows = newJSONLBookReader(r.Body)
+	default:
+		a.errorResponseJSON(w, r, http.StatusUnsupportedMediaType,
+			"the Content-Type must be text/csv or application/x-ndjson")
+		return
+	}
+
+	atomic := mode == "atomic"
+	bookImport, err := a.bookModel.NewImport(r.Context(), atomic)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	defer bookImport.Rollback()
+
+	report := &data.ImportReport{Mode: mode, Rows: []*data.ImportRowResult{}}
+	seen := make(map[string]bool)
+	works := make(map[int64]bool) // whether each work id given so far exists
+	var batch []*pendingImportRow
+
+	// flush inserts the waiting rows, skipping the ones already in the catalog
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		isbns := make([]string, len(batch))
+		for i, pending := range batch {
+			isbns[i] = pending.book.ISBN
+		}
+		existing, err := bookImport.ExistingISBNs(isbns)
+		if err != nil {
+			return err
+		}
+
+		var toInsert []*pendingImportRow
+		var books []*data.Book
+		for _, pending := range batch {
+			if existing[pending.book.ISBN] {
+				pending.result.Status = data.ImportDuplicate
+				continue
+			}
+			toInsert = append(toInsert, pending)
+			books = append(books, pending.book)
+		}
+
+		err = bookImport.InsertBatch(books, func(book *data.Book) (*data.AuditEvent, error) {
+			return a.auditEvent(r, auditBooks, book.ID, data.AuditCreate, nil, book)
+		})
+		for _, pending := range toInsert {
+			switch {
+			case err == nil:
+				pending.result.Status = data.ImportCreated
+				pending.result.BookID = pending.book.ID
+			case atomic:
+				// the whole import is rolled back below
+			default:
+				pending.result.Status = data.ImportFailed
+				pending.result.Errors = map[string]string{"row": "the batch containing this row could not be saved"}
+			}
+		}
+		if err != nil {
+			if atomic {
+				return err
+			}
+			a.logError(r, err)
+		}
+
+		for _, pending := range batch {
+			report.Add(pending.result)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for rowNumber := 1; ; rowNumber++ {
+		book, rowErrors, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				a.badRequestResponse(w, r, fmt.Errorf("the upload must not be larger than %d bytes", maxBytesError.Limit))
+				return
+			}
+			a.badRequestResponse(w, r, err)
+			return
+		}
+
+		result := &data.ImportRowResult{Row: rowNumber}
+		if rowErrors == nil {
+			result.ISBN = book.ISBN
+			rowValidator := validator.New()
+			data.ValidateBook(rowValidator, book)
+			// a missing work would fail the insert of the whole batch, so it
+			// is caught here like checkWorkExists does for a single book
+			if book.WorkID != 0 && rowValidator.Errors["work_id"] == "" {
+				exists, ok := works[book.WorkID]
+				if !ok {
+					exists, err = a.workModel.Exists(r.Context(), book.WorkID)
+					if err != nil {
+						a.serverErrorResponse(w, r, err)
+						return
+					}
+					works[book.WorkID] = exists
+				}
+				rowValidator.Check(exists, "work_id", "work does not exist")
+			}
+			rowErrors = rowValidator.Errors
+		}
+		if len(rowErrors) > 0 {
+			result.Status = data.ImportInvalid
+			result.Errors = rowErrors
+			report.Add(result)
+			continue
+		}
+
+		// the same book twice in one file only gets created once
+		if seen[book.ISBN] {
+			result.Status = data.ImportDuplicate
+			report.Add(result)
+			continue
+		}
+		seen[book.ISBN] = true
+
+		// once an atomic import has an invalid row nothing will be kept, so
+		// there is no point inserting more rows; they are still validated
+		if atomic && report.Invalid > 0 {
+			result.Status = data.ImportCreated
+			report.Add(result)
+			continue
+		}
+
+		batch = append(batch, &pendingImportRow{result: result, book: book})
+		if len(batch) >= importBatchSize {
+			err = flush()
+			if err != nil {
+				a.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+	}
+
+	err = flush()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	if atomic && report.Invalid > 0 {
+		// nothing is imported, report every would-be book as not created
+		for _, row := range report.Rows {
+			if row.Status == data.ImportCreated {
+				row.Status = data.ImportFailed
+				row.BookID = 0
+				row.Errors = map[string]string{"row": "not imported because other rows are invalid"}
+				report.Created--
+				report.Failed++
+			}
+		}
+		status = http.StatusUnprocessableEntity
+	} else {
+		err = bookImport.Commit()
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	data := envelope{
+		"import": report,
+	}
+	err = a.writeJSON(w, status, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
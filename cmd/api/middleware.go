@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"net/http"
@@ -31,6 +32,32 @@ func (a *applicationDependencies) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
+// requestID tags each request with an id, echoed in the X-Request-ID response
+// header and kept in the audit log. An id the client or a proxy already gave
+// the request is kept if it looks sane.
+func (a *applicationDependencies) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = rand.Text()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, a.contextSetRequestID(r, id))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.", c)) {
+			return false
+		}
+	}
+	return true
+}
+
 func (a *applicationDependencies) rateLimit(next http.Handler) http.Handler {
 
 	type client struct {
@@ -114,7 +141,7 @@ func (a *applicationDependencies) authenticate(next http.Handler) http.Handler {
 		}
 
 		// Get the user info associated with this authentication token
-		user, err := a.userModel.GetForToken(data.ScopeAuthentication, token)
+		user, err := a.userModel.GetForToken(r.Context(), data.ScopeAuthentication, token)
 		if err != nil {
 			switch {
 			case errors.Is(err, data.ErrRecordNotFound):
@@ -131,6 +158,15 @@ func (a *applicationDependencies) authenticate(next http.Handler) http.Handler {
 	})
 }
 
+// readOnly lets the handler of a request that only shows data read from
+// the replica, if there is one. Handlers that read a record to change it
+// must not be wrapped, so they never see a stale version of it.
+func (a *applicationDependencies) readOnly(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(data.AllowReplicaReads(r.Context())))
+	})
+}
+
 func (a *applicationDependencies) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -161,3 +197,25 @@ func (a *applicationDependencies) requireActivatedUser(next http.HandlerFunc) ht
 	// Chain the activated user check after ensuring the user is authenticated
 	return a.requireAuthenticatedUser(fn)
 }
+
+func (a *applicationDependencies) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		user := a.contextGetUser(r)
+
+		permissions, err := a.permissionModel.GetAllForUser(r.Context(), user.ID)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !permissions.Include(code) {
+			// Send 403 Forbidden for users missing the permission
+			a.notPermittedResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+
+	// The permission check only makes sense for activated users
+	return a.requireActivatedUser(fn)
+}
@@ -0,0 +1,74 @@
+// Filename: cmd/api/migrate.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Duane-Arzu/test-1.git/internal/migrate"
+	"github.com/Duane-Arzu/test-1.git/migrations"
+)
+
+// migrationTimeout bounds a whole migration run, including the wait for
+// another instance that holds the lock.
+const migrationTimeout = 10 * time.Minute
+
+// runMigrations carries out the -migrate mode: up applies every pending
+// migration, down reverts the latest one and status lists them.
+func runMigrations(db *sql.DB, logger *slog.Logger, mode string) error {
+	migrator, err := migrate.New(db, migrations.Files)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
+
+	switch mode {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			logger.Info("applied migration", "version", m.Version, "name", m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			logger.Info("database schema is up to date")
+		}
+	case "down":
+		reverted, err := migrator.Down(ctx)
+		if err != nil {
+			if errors.Is(err, migrate.ErrNoChange) {
+				logger.Info("no migration to revert")
+				return nil
+			}
+			return err
+		}
+		logger.Info("reverted migration", "version", reverted.Version, "name", reverted.Name)
+	case "status":
+		statuses, current, dirty, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			fmt.Fprintf(tw, "%06d\t%s\t%t\n", s.Version, s.Name, s.Applied)
+		}
+		err = tw.Flush()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\ncurrent version: %d, dirty: %t\n", current, dirty)
+	default:
+		return fmt.Errorf("unknown -migrate mode %q, use up, down or status", mode)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -10,19 +11,19 @@ import (
 	"github.com/Duane-Arzu/test-1.git/internal/validator"
 )
 
-// Create a local struct to hold incoming data
-var incomingListData struct {
-	Name        *string `json:"name"`
-	Description *string `json:"description"`
-	CreatedBy   *int    `json:"created_by"`
-}
-
 func (a *applicationDependencies) createReadingListHandler(w http.ResponseWriter, r *http.Request) {
 	// Create a struct to hold incoming data with the correct field names and JSON tags
 	var incomingListData struct {
 		Name        string `json:"name"`        // Maps to 'name' in JSON
 		Description string `json:"description"` // Maps to 'description' in JSON
-		CreatedBy   int    `json:"created_by"`  // Maps to 'created_by' in JSON
+		// accepted so older clients keep working, but a list always belongs
+		// to the user creating it
+		IgnoredOwner json.RawMessage `json:"created_by"`
+		Public       bool            `json:"public"` // Maps to 'is_public' in JSON
+		Books        []struct {
+			BookID int64  `json:"book_id"`
+			Status string `json:"status"`
+		} `json:"books"` // Books the list starts out with, in order
 	}
 
 	// Perform the decoding of the incoming JSON
@@ -35,21 +36,75 @@ func (a *applicationDependencies) createReadingListHandler(w http.ResponseWriter
 	list := &data.ReadingList{
 		Name:        incomingListData.Name,
 		Description: incomingListData.Description,
-		CreatedBy:   incomingListData.CreatedBy,
+		CreatedBy:   int(a.contextGetUser(r).ID),
+		IsPublic:    incomingListData.Public,
 	}
 
 	// Initialize a Validator instance
 	v := validator.New()
 	data.ValidateReadingList(v, list)
+	for _, book := range incomingListData.Books {
+		data.ValidateReadingStatus(v, book.Status)
+	}
 	if !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	// Insert the reading list into the database
-	err = a.readingListModel.Insert(list)
+	// Insert the reading list and its books together, so a bad book
+	// does not leave a half filled list behind
+	var books []*data.BooksInList
+	var missingBookID int64
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		created := *list
+		err := m.ReadingLists.Insert(r.Context(), &created)
+		if err != nil {
+			return err
+		}
+
+		added := make([]*data.BooksInList, 0, len(incomingListData.Books))
+		for _, incoming := range incomingListData.Books {
+			exists, err := m.Books.BookExists(r.Context(), incoming.BookID)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				missingBookID = incoming.BookID
+				return data.ErrRecordNotFound
+			}
+
+			book := &data.BooksInList{
+				ReadingListID: created.ID,
+				BookID:        incoming.BookID,
+				Status:        incoming.Status,
+			}
+			err = m.ReadingLists.AddBookToList(r.Context(), book)
+			if err != nil {
+				return err
+			}
+			added = append(added, book)
+		}
+
+		// the books the list starts out with are part of what was created
+		err = a.audit(m, r, auditReadingLists, created.ID, data.AuditCreate, nil, listWithBooks{&created, added})
+		if err != nil {
+			return err
+		}
+
+		*list = created
+		books = added
+		return nil
+	})
 	if err != nil {
-		a.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.BIDnotFound(w, r, missingBookID)
+		case errors.Is(err, data.ErrDuplicateBookInList):
+			v.AddError("books", data.ErrDuplicateBookInList.Error())
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -60,6 +115,9 @@ func (a *applicationDependencies) createReadingListHandler(w http.ResponseWriter
 	data := envelope{
 		"Reading List": list,
 	}
+	if len(books) > 0 {
+		data["books"] = books
+	}
 	err = a.writeJSON(w, http.StatusCreated, data, headers)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
@@ -75,17 +133,30 @@ func (a *applicationDependencies) updateReadingListHandler(w http.ResponseWriter
 		return
 	}
 
-	// Retrieve the reading list from the database
-	list, err := a.readingListModel.Get(id)
+	// Retrieve the reading list and make sure the user may change it
+	list, ok := a.readingListForUser(w, r, id, listEditor)
+	if !ok {
+		return
+	}
+
+	// the client may only want to change the version it has seen
+	books, err := a.readingListModel.GetBooks(r.Context(), id)
 	if err != nil {
-		if errors.Is(err, data.ErrRecordNotFound) {
-			a.notFoundResponse(w, r)
-		} else {
-			a.serverErrorResponse(w, r, err)
-		}
+		a.serverErrorResponse(w, r, err)
 		return
 	}
+	if ifMatchFails(r, readingListETag(list, books)) {
+		a.preconditionFailedResponse(w, r)
+		return
+	}
+	original := *list
 
+	// Create a local struct to hold incoming data
+	var incomingListData struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		Public      *bool   `json:"public"`
+	}
 	err = a.readJSON(w, r, &incomingListData)
 	if err != nil {
 		a.badRequestResponse(w, r, err)
@@ -99,8 +170,13 @@ func (a *applicationDependencies) updateReadingListHandler(w http.ResponseWriter
 	if incomingListData.Description != nil {
 		list.Description = *incomingListData.Description
 	}
-	if incomingListData.CreatedBy != nil {
-		list.CreatedBy = *incomingListData.CreatedBy
+	if incomingListData.Public != nil {
+		// who gets to see the list is up to its owner, not the collaborators
+		if int64(list.CreatedBy) != a.contextGetUser(r).ID {
+			a.notPermittedResponse(w, r)
+			return
+		}
+		list.IsPublic = *incomingListData.Public
 	}
 
 	// Validate the updated reading list
@@ -112,9 +188,26 @@ func (a *applicationDependencies) updateReadingListHandler(w http.ResponseWriter
 	}
 
 	// Perform the update in the database
-	err = a.readingListModel.Update(list)
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		updated := *list
+		err := m.ReadingLists.Update(r.Context(), &updated)
+		if err != nil {
+			return err
+		}
+		err = a.audit(m, r, auditReadingLists, id, data.AuditUpdate, &original, &updated)
+		if err != nil {
+			return err
+		}
+		*list = updated
+		return nil
+	})
 	if err != nil {
-		a.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -122,7 +215,9 @@ func (a *applicationDependencies) updateReadingListHandler(w http.ResponseWriter
 	data := envelope{
 		"Reading List": list,
 	}
-	err = a.writeJSON(w, http.StatusOK, data, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", readingListETag(list, books))
+	err = a.writeJSON(w, http.StatusOK, data, headers)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -136,22 +231,30 @@ func (a *applicationDependencies) displayReadingListHandler(w http.ResponseWrite
 		return
 	}
 
-	list, err := a.readingListModel.Get(id)
+	list, ok := a.readingListForUser(w, r, id, listViewer)
+	if !ok {
+		return
+	}
+
+	books, err := a.readingListModel.GetBooks(r.Context(), id)
 	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			a.notFoundResponse(w, r)
-		default:
-			a.serverErrorResponse(w, r, err)
-		}
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	tag := readingListETag(list, books)
+	if a.notModified(w, r, tag) {
 		return
 	}
 
-	// display the comment
+	// display the list together with its books in order
 	data := envelope{
 		"Reading List": list,
+		"books":        books,
 	}
-	err = a.writeJSON(w, http.StatusOK, data, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", tag)
+	err = a.writeJSON(w, http.StatusOK, data, headers)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -166,11 +269,37 @@ func (a *applicationDependencies) deleteReadingListHandler(w http.ResponseWriter
 		return
 	}
 
-	err = a.readingListModel.Delete(id)
+	// only the owner may delete a list
+	_, ok := a.readingListForUser(w, r, id, listOwner)
+	if !ok {
+		return
+	}
+
+	// check If-Match against the list that is actually deleted
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		list, err := m.ReadingLists.Get(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		books, err := m.ReadingLists.GetBooks(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		if ifMatchFails(r, readingListETag(list, books)) {
+			return errPreconditionFailed
+		}
+		err = m.ReadingLists.Delete(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditReadingLists, id, data.AuditDelete, list, nil)
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			a.BIDnotFound(w, r, id) // Pass the ID to the custom message handler
+		case errors.Is(err, errPreconditionFailed):
+			a.preconditionFailedResponse(w, r)
 		default:
 			a.serverErrorResponse(w, r, err)
 		}
@@ -210,8 +339,7 @@ func (a *applicationDependencies) ReadinglistHandler(w http.ResponseWriter, r *h
 	queryParametersData.Filters.Sort = a.getSingleQueryParameter(
 		queryParameters, "sort", "id")
 
-	queryParametersData.Filters.SortSafeList = []string{"id", "name", "status",
-		"-id", "-name", "-status"}
+	queryParametersData.Filters.SortSafeList = []string{"id", "name", "-id", "-name"}
 
 	// Check if our filters are valid
 	data.ValidateFilters(v, queryParametersData.Filters)
@@ -221,6 +349,7 @@ func (a *applicationDependencies) ReadinglistHandler(w http.ResponseWriter, r *h
 	}
 
 	lists, metadata, err := a.readingListModel.GetAll(
+		r.Context(),
 		queryParametersData.Name,
 		queryParametersData.Filters,
 	)
@@ -273,15 +402,14 @@ func (a *applicationDependencies) addReadingListBookHandler(w http.ResponseWrite
 		return
 	}
 
-	//check if reading list exist
-	err = a.readingListModel.ReadingListExist(id)
-	if err != nil {
-		a.notFoundResponse(w, r)
+	//check if reading list exist and the user may change it
+	_, ok := a.readingListForUser(w, r, id, listEditor)
+	if !ok {
 		return
 	}
 
 	// Check if the book exists in the database
-	exists, err := a.bookModel.BookExists(incomingData.BookID)
+	exists, err := a.bookModel.BookExists(r.Context(), incomingData.BookID)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -292,7 +420,13 @@ func (a *applicationDependencies) addReadingListBookHandler(w http.ResponseWrite
 	}
 
 	//procede to insert to DB
-	err = a.readingListModel.AddBookToList(bookInList)
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		err := m.ReadingLists.AddBookToList(r.Context(), bookInList)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditReadingLists, id, data.AuditUpdate, nil, map[string]any{"book": bookInList})
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateBookInList):
@@ -311,6 +445,18 @@ func (a *applicationDependencies) addReadingListBookHandler(w http.ResponseWrite
 		"Added_Book": bookInList,
 	}
 
+	// point the reader to what comes next when they finish a book in a series
+	if bookInList.Status == "completed" {
+		next, err := a.seriesModel.NextInSeries(r.Context(), bookInList.BookID, a.contextGetUser(r).ID)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		if len(next) > 0 {
+			data["next_in_series"] = next
+		}
+	}
+
 	err = a.writeJSON(w, http.StatusOK, data, nil)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
@@ -338,7 +484,7 @@ func (a *applicationDependencies) RemoveReadingListBookHandler(w http.ResponseWr
 	}
 
 	// Check if the book exists in the database
-	exists, err := a.bookModel.BookExists(int64(incomingData.BookID))
+	exists, err := a.bookModel.BookExists(r.Context(), int64(incomingData.BookID))
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -348,15 +494,28 @@ func (a *applicationDependencies) RemoveReadingListBookHandler(w http.ResponseWr
 		return
 	}
 
-	//check if reading list exists
-	err = a.readingListModel.ReadingListExist(list_id)
-	if err != nil {
-		a.notFoundResponse(w, r)
+	//check if reading list exists and the user may change it
+	_, ok := a.readingListForUser(w, r, list_id, listEditor)
+	if !ok {
 		return
 	}
 
 	//procede to delete book from reading list
-	err = a.readingListModel.RemoveBookFromList(incomingData.BookID, int(list_id))
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		books, err := m.ReadingLists.GetBooks(r.Context(), list_id)
+		if err != nil {
+			return err
+		}
+		removed := findListBook(books, int64(incomingData.BookID))
+		if removed == nil {
+			return data.ErrRecordNotFound
+		}
+		err = m.ReadingLists.RemoveBookFromList(r.Context(), int(list_id), incomingData.BookID)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditReadingLists, list_id, data.AuditUpdate, map[string]any{"book": removed}, nil)
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -377,3 +536,160 @@ func (a *applicationDependencies) RemoveReadingListBookHandler(w http.ResponseWr
 	}
 
 }
+
+// transferReadingListBookHandler moves a book from one list to the end of
+// another, keeping its reading status
+func (a *applicationDependencies) transferReadingListBookHandler(w http.ResponseWriter, r *http.Request) {
+	listID, err := a.readIDParam(r, "lid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+	bookID, err := a.readIDParam(r, "bid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var incomingData struct {
+		ListID int64 `json:"list_id"`
+	}
+	err = a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(incomingData.ListID > 0, "list_id", "must be provided")
+	v.Check(incomingData.ListID != listID, "list_id", "must be a different list")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// the user has to be able to change both lists
+	_, ok := a.readingListForUser(w, r, listID, listEditor)
+	if !ok {
+		return
+	}
+	_, ok = a.readingListForUser(w, r, incomingData.ListID, listEditor)
+	if !ok {
+		return
+	}
+
+	var moved *data.BooksInList
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		books, err := m.ReadingLists.GetBooks(r.Context(), listID)
+		if err != nil {
+			return err
+		}
+		removed := findListBook(books, bookID)
+		if removed == nil {
+			return data.ErrRecordNotFound
+		}
+
+		err = m.ReadingLists.RemoveBookFromList(r.Context(), int(listID), int(bookID))
+		if err != nil {
+			return err
+		}
+
+		book := &data.BooksInList{
+			ReadingListID: incomingData.ListID,
+			BookID:        bookID,
+			Status:        removed.Status,
+		}
+		err = m.ReadingLists.AddBookToList(r.Context(), book)
+		if err != nil {
+			return err
+		}
+
+		err = a.audit(m, r, auditReadingLists, listID, data.AuditUpdate, map[string]any{"book": removed}, nil)
+		if err != nil {
+			return err
+		}
+		err = a.audit(m, r, auditReadingLists, incomingData.ListID, data.AuditUpdate, nil, map[string]any{"book": book})
+		if err != nil {
+			return err
+		}
+		moved = book
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateBookInList):
+			v.AddError("book", data.ErrDuplicateBookInList.Error())
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"book": moved,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// findListBook returns the entry of a book among the books on a list, or nil
+// if the book is not on it.
+func findListBook(books []*data.BooksInList, bookID int64) *data.BooksInList {
+	for _, book := range books {
+		if book.BookID == bookID {
+			return book
+		}
+	}
+	return nil
+}
+
+// access levels for readingListForUser
+const (
+	listViewer = iota
+	listEditor
+	listOwner
+)
+
+// readingListForUser fetches a list and checks that the authenticated user has
+// the requested level of access to it. If not, the error response has already
+// been written and false is returned.
+func (a *applicationDependencies) readingListForUser(w http.ResponseWriter, r *http.Request, id int64, level int) (*data.ReadingList, bool) {
+	list, err := a.readingListModel.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.LIDnotFound(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	user := a.contextGetUser(r)
+	if int64(list.CreatedBy) == user.ID {
+		return list, true
+	}
+
+	var allowed bool
+	switch level {
+	case listViewer:
+		allowed, err = a.readingListModel.CanView(r.Context(), id, user.ID)
+	case listEditor:
+		allowed, err = a.readingListModel.CanEdit(r.Context(), id, user.ID)
+	}
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+	if !allowed {
+		a.notPermittedResponse(w, r)
+		return nil, false
+	}
+
+	return list, true
+}
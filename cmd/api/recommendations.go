@@ -0,0 +1,120 @@
+// Filename: cmd/api/recommendations.go
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
+	"github.com/Duane-Arzu/test-1.git/internal/validator"
+)
+
+// refreshSimilaritiesEvery recomputes the book similarities straight away and
+// then at every interval until ctx is cancelled.
+func (a *applicationDependencies) refreshSimilaritiesEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		count, err := a.recommendationModel.RefreshSimilarities(ctx)
+		if err != nil {
+			a.logger.Error("could not refresh book similarities", "error", err)
+		} else {
+			a.logger.Info("refreshed book similarities", "pairs", count, "duration", time.Since(start).String())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// readRecommendationLimit reads the number of books to return from the query string.
+func (a *applicationDependencies) readRecommendationLimit(r *http.Request, v *validator.Validator) int {
+	limit := a.getSingleIntegerParameter(r.URL.Query(), "limit", 10, v)
+	v.Check(limit > 0 && limit <= 50, "limit", "must be between 1 and 50")
+	return limit
+}
+
+func (a *applicationDependencies) listSimilarBooksHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "bid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	limit := a.readRecommendationLimit(r, v)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = a.bookModel.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			if !a.redirectMergedBook(w, r, id) {
+				a.BIDnotFound(w, r, id)
+			}
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	books, err := a.recommendationModel.Similar(r.Context(), id, limit)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"similar_books": books,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) listRecommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readUserIDParam(r, "uid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	// recommendations give away what someone reads
+	user := a.contextGetUser(r)
+	if id != user.ID {
+		a.notPermittedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	limit := a.readRecommendationLimit(r, v)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	books, err := a.recommendationModel.ForUser(r.Context(), user.ID, limit)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"recommendations": books,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
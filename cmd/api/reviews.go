@@ -12,12 +12,6 @@ import (
 	"github.com/Duane-Arzu/test-1.git/internal/validator"
 )
 
-// Struct to hold incoming review data
-var incomingReviewData struct {
-	Rating     *int64  `json:"rating"` // integer with a constraint (1-5)
-	ReviewText *string `json:"review"` // non-null text field
-}
-
 // Updated createReviewHandler with product existence check
 func (a *applicationDependencies) createReviewHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract the book_id from the URL path
@@ -60,7 +54,7 @@ func (a *applicationDependencies) createReviewHandler(w http.ResponseWriter, r *
 	}
 
 	// Check if the book exists in the database
-	exists, err := a.bookModel.BookExists(bookID)
+	exists, err := a.bookModel.BookExists(r.Context(), bookID)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -90,7 +84,13 @@ func (a *applicationDependencies) createReviewHandler(w http.ResponseWriter, r *
 	}
 
 	// Insert the review into the database
-	err = a.reviewModel.InsertReview(review)
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		err := m.Reviews.InsertReview(r.Context(), review)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditReviews, review.ReviewID, data.AuditCreate, nil, review)
+	})
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -119,7 +119,7 @@ func (a *applicationDependencies) displayReviewHandler(w http.ResponseWriter, r
 	}
 
 	// Check if the book exists in the database
-	exists, err := a.bookModel.BookExists(bid)
+	exists, err := a.bookModel.BookExists(r.Context(), bid)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -137,7 +137,7 @@ func (a *applicationDependencies) displayReviewHandler(w http.ResponseWriter, r
 	}
 
 	// Call GetReview() to retrieve the review with the specified id
-	review, err := a.reviewModel.GetReview(rid)
+	review, err := a.reviewModel.GetReview(r.Context(), rid)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -148,11 +148,18 @@ func (a *applicationDependencies) displayReviewHandler(w http.ResponseWriter, r
 		return
 	}
 
+	tag := etag(review.Version)
+	if a.notModified(w, r, tag) {
+		return
+	}
+
 	// Display the review
 	data := envelope{
 		"Review": review,
 	}
-	err = a.writeJSON(w, http.StatusOK, data, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", tag)
+	err = a.writeJSON(w, http.StatusOK, data, headers)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -167,19 +174,35 @@ func (a *applicationDependencies) bookReviewsHandler(w http.ResponseWriter, r *h
 		return
 	}
 
-	// Check if the book exists in the database
-	exists, err := a.bookModel.BookExists(bookID)
-	if err != nil {
-		a.serverErrorResponse(w, r, err)
+	// By default the reviews of every edition of the work are returned,
+	// scope=edition limits them to this one book
+	v := validator.New()
+	scope := a.getSingleQueryParameter(r.URL.Query(), "scope", "work")
+	v.Check(validator.PermittedValue(scope, "work", "edition"), "scope", "must be 'work' or 'edition'")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	if !exists {
-		a.BIDnotFound(w, r, bookID) // Respond with a 404 if the book is not found
+
+	// Check if the book exists in the database
+	book, err := a.bookModel.Get(r.Context(), bookID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.BIDnotFound(w, r, bookID) // Respond with a 404 if the book is not found
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
-	// Retrieve all reviews for the specified book
-	reviews, err := a.reviewModel.GetAllBookReviews(bookID)
+	// Retrieve all reviews for the specified book or its work
+	var reviews []*data.Review
+	if scope == "work" && book.WorkID != 0 {
+		reviews, err = a.reviewModel.GetAllWorkReviews(r.Context(), book.WorkID)
+	} else {
+		reviews, err = a.reviewModel.GetAllBookReviews(r.Context(), bookID)
+	}
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -205,7 +228,7 @@ func (a *applicationDependencies) updateReviewHandler(w http.ResponseWriter, r *
 	}
 
 	// Retrieve the review from the database
-	review, err := a.reviewModel.GetReview(id)
+	review, err := a.reviewModel.GetReview(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, data.ErrRecordNotFound) {
 			a.notFoundResponse(w, r)
@@ -215,11 +238,18 @@ func (a *applicationDependencies) updateReviewHandler(w http.ResponseWriter, r *
 		return
 	}
 
-	// // Define a struct to hold incoming JSON data
-	// var incomingReviewData struct {
-	// 	Rating     *int64  `json:"rating"`      // integer with a constraint (1-5)
-	// 	ReviewText *string `json:"review_text"` // non-null text field
-	// }
+	// the client may only want to change the version it has seen
+	if ifMatchFails(r, etag(review.Version)) {
+		a.preconditionFailedResponse(w, r)
+		return
+	}
+	original := *review
+
+	// Define a struct to hold incoming JSON data
+	var incomingReviewData struct {
+		Rating     *int64  `json:"rating"` // integer with a constraint (1-5)
+		ReviewText *string `json:"review"` // non-null text field
+	}
 
 	// Decode the incoming JSON into the struct
 	err = a.readJSON(w, r, &incomingReviewData)
@@ -244,9 +274,26 @@ func (a *applicationDependencies) updateReviewHandler(w http.ResponseWriter, r *
 	}
 
 	// Update the review in the database
-	err = a.reviewModel.UpdateReview(review)
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		updated := *review
+		err := m.Reviews.UpdateReview(r.Context(), &updated)
+		if err != nil {
+			return err
+		}
+		err = a.audit(m, r, auditReviews, id, data.AuditUpdate, &original, &updated)
+		if err != nil {
+			return err
+		}
+		*review = updated
+		return nil
+	})
 	if err != nil {
-		a.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -254,7 +301,9 @@ func (a *applicationDependencies) updateReviewHandler(w http.ResponseWriter, r *
 	data := envelope{
 		"review": review,
 	}
-	err = a.writeJSON(w, http.StatusOK, data, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", etag(review.Version))
+	err = a.writeJSON(w, http.StatusOK, data, headers)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
@@ -267,11 +316,27 @@ func (a *applicationDependencies) deleteReviewHandler(w http.ResponseWriter, r *
 		return
 	}
 
-	err = a.reviewModel.DeleteReview(id)
+	// check If-Match against the review that is actually deleted
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		review, err := m.Reviews.GetReview(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		if ifMatchFails(r, etag(review.Version)) {
+			return errPreconditionFailed
+		}
+		err = m.Reviews.DeleteReview(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditReviews, id, data.AuditDelete, review, nil)
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			a.RIDnotFound(w, r, id) // Pass the ID to the custom message handler
+		case errors.Is(err, errPreconditionFailed):
+			a.preconditionFailedResponse(w, r)
 		default:
 			a.serverErrorResponse(w, r, err)
 		}
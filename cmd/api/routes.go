@@ -4,6 +4,7 @@ package main
 import (
 	"net/http"
 
+	"github.com/Duane-Arzu/test-1.git/internal/data"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -17,28 +18,68 @@ func (a *applicationDependencies) routes() http.Handler {
 
 	// Section for Books
 	router.HandlerFunc(http.MethodGet, "/api/v1/healthcheck", a.healthcheckHandler)
-	router.HandlerFunc(http.MethodGet, "/api/v1/books/:bid", a.displayBookHandler)
-	router.HandlerFunc(http.MethodGet, "/api/v1/books", a.listBooksHandler)
-	router.HandlerFunc(http.MethodGet, "/api/v1/book/search", a.searchBookHandler)
+	router.HandlerFunc(http.MethodGet, "/api/v1/books/:bid", a.readOnly(a.displayBookHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/books", a.readOnly(a.listBooksHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/book/search", a.readOnly(a.searchBookHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/works/:wid", a.readOnly(a.displayWorkHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/book/duplicates", a.requirePermission(data.PermissionModerateBooks, a.listDuplicateBooksHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/book/import", a.requireActivatedUser(a.importBooksHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/book/from-epub", a.requireActivatedUser(a.createBookFromEPUBHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/books/:bid/cover", a.requireActivatedUser(a.uploadCoverHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/covers/*filepath", a.showCoverHandler)
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/imports/goodreads", a.requireActivatedUser(a.createGoodreadsImportHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/imports/:id", a.requireActivatedUser(a.showImportHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/users/:uid/export", a.requireActivatedUser(a.exportUserDataHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/exports/:token", a.downloadExportHandler)
+	router.HandlerFunc(http.MethodPost, "/api/v1/books/:bid/merge", a.requirePermission(data.PermissionModerateBooks, a.mergeBookHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/books/:bid/changes", a.requireActivatedUser(a.proposeBookChangeHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/books/:bid/changes", a.requirePermission(data.PermissionModerateBooks, a.readOnly(a.listChangeRequestsHandler)))
+	router.HandlerFunc(http.MethodGet, "/api/v1/changes", a.requirePermission(data.PermissionModerateBooks, a.readOnly(a.listChangeRequestsHandler)))
+	router.HandlerFunc(http.MethodGet, "/api/v1/changes/:cid", a.requireActivatedUser(a.readOnly(a.showChangeRequestHandler)))
+	router.HandlerFunc(http.MethodPost, "/api/v1/changes/:cid/approve", a.requirePermission(data.PermissionModerateBooks, a.approveChangeRequestHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/changes/:cid/reject", a.requirePermission(data.PermissionModerateBooks, a.rejectChangeRequestHandler))
 	router.HandlerFunc(http.MethodPost, "/api/v1/books", a.createBookHandler)
 	router.HandlerFunc(http.MethodPatch, "/api/v1/books/:bid", a.updateBookHandler)
 	router.HandlerFunc(http.MethodDelete, "/api/v1/books/:bid", a.deleteBookHandler)
+	router.HandlerFunc(http.MethodPost, "/api/v1/books/:bid/restore", a.requirePermission(data.PermissionModerateBooks, a.restoreBookHandler))
 
 	// Section for Reading Lists
-	router.HandlerFunc(http.MethodGet, "/api/v1/lists", a.requireActivatedUser(a.ReadinglistHandler))
-	router.HandlerFunc(http.MethodGet, "/api/v1/lists/:lid", a.requireActivatedUser(a.displayReadingListHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/lists", a.requireActivatedUser(a.readOnly(a.ReadinglistHandler)))
+	router.HandlerFunc(http.MethodGet, "/api/v1/lists/:lid", a.requireActivatedUser(a.readOnly(a.displayReadingListHandler)))
 	router.HandlerFunc(http.MethodPost, "/api/v1/lists", a.requireActivatedUser(a.createReadingListHandler))
 	router.HandlerFunc(http.MethodPatch, "/api/v1/lists/:lid", a.requireActivatedUser(a.updateReadingListHandler))
 	router.HandlerFunc(http.MethodDelete, "/api/v1/lists/:lid", a.requireActivatedUser(a.deleteReadingListHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/lists/:lid/restore", a.requireActivatedUser(a.restoreReadingListHandler))
 	router.HandlerFunc(http.MethodPost, "/api/v1/lists/:lid/books", a.requireActivatedUser(a.addReadingListBookHandler))
 	router.HandlerFunc(http.MethodDelete, "/api/v1/lists/:lid/books", a.requireActivatedUser(a.RemoveReadingListBookHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/lists/:lid/books/:bid/position", a.requireActivatedUser(a.moveReadingListBookHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/lists/:lid/books/:bid/transfer", a.requireActivatedUser(a.transferReadingListBookHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/lists/:lid/collaborators", a.requireActivatedUser(a.listCollaboratorsHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/lists/:lid/collaborators", a.requireActivatedUser(a.addCollaboratorHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/lists/:lid/collaborators/:uid", a.requireActivatedUser(a.removeCollaboratorHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/lists/:lid/clone", a.requireActivatedUser(a.cloneReadingListHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/lists/:lid/shares", a.requireActivatedUser(a.listShareLinksHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/lists/:lid/shares", a.requireActivatedUser(a.createShareLinkHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/lists/:lid/shares/:sid", a.requireActivatedUser(a.revokeShareLinkHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/shared/lists/:token", a.showSharedListHandler)
 
 	// Section for Reviews
 	router.HandlerFunc(http.MethodPost, "/api/v1/books/:bid/reviews", a.createReviewHandler)
-	router.HandlerFunc(http.MethodGet, "/api/v1/books/:bid/reviews", a.bookReviewsHandler)
-	router.HandlerFunc(http.MethodGet, "/api/v1/books/:bid/reviews/:rid", a.displayReviewHandler)
+	router.HandlerFunc(http.MethodGet, "/api/v1/books/:bid/reviews", a.readOnly(a.bookReviewsHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/books/:bid/reviews/:rid", a.readOnly(a.displayReviewHandler))
 	router.HandlerFunc(http.MethodPatch, "/api/v1/reviews/:rid", a.updateReviewHandler)
 	router.HandlerFunc(http.MethodDelete, "/api/v1/reviews/:rid", a.deleteReviewHandler)
+	router.HandlerFunc(http.MethodPost, "/api/v1/reviews/:rid/restore", a.requireActivatedUser(a.restoreReviewHandler))
+
+	// Trash Section
+	router.HandlerFunc(http.MethodGet, "/api/v1/trash", a.requireActivatedUser(a.listTrashHandler))
+
+	// Section for Series
+	router.HandlerFunc(http.MethodGet, "/api/v1/series/:sid", a.readOnly(a.displaySeriesHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/series", a.requirePermission(data.PermissionModerateBooks, a.createSeriesHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/series/:sid/books", a.requirePermission(data.PermissionModerateBooks, a.addSeriesBookHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/series/:sid/books/:bid", a.requirePermission(data.PermissionModerateBooks, a.removeSeriesBookHandler))
 
 	// Users Section
 	// =============
@@ -49,6 +90,28 @@ func (a *applicationDependencies) routes() http.Handler {
 	router.HandlerFunc(http.MethodPost, "/api/v1/tokens/authentication", a.createAuthenticationTokenHandler)
 	router.HandlerFunc(http.MethodPost, "/api/v1/users", a.registerUserHandler)
 
+	// Reading Stats Section
+	router.HandlerFunc(http.MethodGet, "/api/v1/users/:uid/stats", a.requireActivatedUser(a.userStatsHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/books/:bid/progress", a.requireActivatedUser(a.logProgressHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/goals", a.requireActivatedUser(a.showReadingGoalHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/goals", a.requireActivatedUser(a.setReadingGoalHandler))
+
+	// Tags Section
+	router.HandlerFunc(http.MethodGet, "/api/v1/tags", a.requireActivatedUser(a.listMyTagsHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/books/:bid/tags", a.bookTagsHandler)
+	router.HandlerFunc(http.MethodPost, "/api/v1/books/:bid/tags", a.requireActivatedUser(a.tagBookHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/books/:bid/tags/:tag", a.requireActivatedUser(a.untagBookHandler))
+
+	// Audit Section
+	router.HandlerFunc(http.MethodGet, "/api/v1/audit", a.requirePermission(data.PermissionReadAudit, a.readOnly(a.listAuditEventsHandler)))
+
+	// Database Section
+	router.HandlerFunc(http.MethodGet, "/api/v1/database/stats", a.requirePermission(data.PermissionDatabaseStats, a.databaseStatsHandler))
+
+	// Recommendations Section
+	router.HandlerFunc(http.MethodGet, "/api/v1/books/:bid/similar", a.listSimilarBooksHandler)
+	router.HandlerFunc(http.MethodGet, "/api/v1/users/:uid/recommendations", a.requireActivatedUser(a.listRecommendationsHandler))
+
 	// Serve index.html directly
 	router.HandlerFunc(http.MethodGet, "/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./ui/html/index.html")
@@ -68,7 +131,7 @@ func (a *applicationDependencies) routes() http.Handler {
 	fileServer := http.FileServer(http.Dir("./ui/static"))
 	router.Handler(http.MethodGet, "/static/*filepath", http.StripPrefix("/static", fileServer))
 
-	return a.recoverPanic(a.rateLimit(a.authenticate(router)))
+	return a.recoverPanic(a.requestID(a.rateLimit(a.authenticate(router))))
 	//----------------------------------------------------------------------------
 	// // Public routes
 	// router.HandlerFunc(http.MethodPost, "/api/v1/books", a.createBookHandler)
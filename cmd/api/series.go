@@ -0,0 +1,214 @@
+// Filename: cmd/api/series.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
+	"github.com/Duane-Arzu/test-1.git/internal/validator"
+)
+
+func (a *applicationDependencies) createSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	var incomingData struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	err := a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	series := &data.Series{
+		Name:        incomingData.Name,
+		Description: incomingData.Description,
+	}
+
+	v := validator.New()
+	data.ValidateSeries(v, series)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		err := m.Series.Insert(r.Context(), series)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditSeries, series.ID, data.AuditCreate, nil, series)
+	})
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/series/%d", series.ID))
+
+	data := envelope{
+		"series": series,
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) displaySeriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "sid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	series, err := a.seriesModel.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"series": series,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) addSeriesBookHandler(w http.ResponseWriter, r *http.Request) {
+	seriesID, err := a.readIDParam(r, "sid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var incomingData struct {
+		BookID   int64   `json:"book_id"`
+		Position float64 `json:"position"`
+	}
+	err = a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	book := &data.SeriesBook{
+		SeriesID: seriesID,
+		BookID:   incomingData.BookID,
+		Position: incomingData.Position,
+	}
+
+	v := validator.New()
+	data.ValidateSeriesBook(v, book)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = a.seriesModel.Get(r.Context(), seriesID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	exists, err := a.bookModel.BookExists(r.Context(), book.BookID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		a.BIDnotFound(w, r, book.BookID)
+		return
+	}
+
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		err := m.Series.AddBook(r.Context(), book)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditSeries, seriesID, data.AuditUpdate, nil, map[string]any{"book": book})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateSeriesPosition):
+			v.AddError("position", "another book already has this position in the series")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"book": book,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) removeSeriesBookHandler(w http.ResponseWriter, r *http.Request) {
+	seriesID, err := a.readIDParam(r, "sid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+	bookID, err := a.readIDParam(r, "bid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		series, err := m.Series.Get(r.Context(), seriesID)
+		if err != nil {
+			return err
+		}
+		var removed *data.SeriesBook
+		for _, book := range series.Books {
+			if book.BookID == bookID {
+				removed = book
+			}
+		}
+		if removed == nil {
+			return data.ErrRecordNotFound
+		}
+		err = m.Series.RemoveBook(r.Context(), seriesID, bookID)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditSeries, seriesID, data.AuditUpdate, map[string]any{"book": removed}, nil)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"message": "Book successfully removed from series",
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -14,6 +15,11 @@ import (
 )
 
 func (a *applicationDependencies) serve() error {
+	// Every request context derives from this one, so cancelling it stops the
+	// queries of requests that are still running
+	requests, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
 	// Configure the HTTP server with settings like port, timeouts, and error logging
 	apiServer := &http.Server{
 		Addr:         fmt.Sprintf(":%d", a.config.port),                      // Set server port
@@ -22,12 +28,32 @@ func (a *applicationDependencies) serve() error {
 		ReadTimeout:  5 * time.Second,                                        // Max time to read a request
 		WriteTimeout: 10 * time.Second,                                       // Max time to write a response
 		ErrorLog:     slog.NewLogLogger(a.logger.Handler(), slog.LevelError), // Log errors
+		BaseContext:  func(net.Listener) context.Context { return requests },
 	}
 
 	// Log that the server is starting
 	a.logger.Info("starting server", "address", apiServer.Addr,
 		"environment", a.config.environment)
 
+	// Periodic jobs run until the server shuts down
+	jobs, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	if a.config.recommendations.interval > 0 {
+		a.background(func() {
+			a.refreshSimilaritiesEvery(jobs, a.config.recommendations.interval)
+		})
+	}
+	if a.config.ratings.refreshInterval > 0 {
+		a.background(func() {
+			a.refreshWeightedRatingsEvery(jobs, a.config.ratings.refreshInterval)
+		})
+	}
+	if a.config.trash.purgeInterval > 0 {
+		a.background(func() {
+			a.purgeTrashEvery(jobs, a.config.trash.purgeInterval, a.config.trash.retention)
+		})
+	}
+
 	// Channel to track shutdown errors
 	shutdownError := make(chan error)
 
@@ -47,10 +73,13 @@ func (a *applicationDependencies) serve() error {
 		// Shut down the server gracefully
 		err := apiServer.Shutdown(ctx)
 		if err != nil {
+			// requests that outlived the grace period get their queries cancelled
+			cancelRequests()
 			shutdownError <- err // Send error to channel if shutdown fails
 		}
 
-		// Wait for all background tasks to finish
+		// Stop the periodic jobs and wait for all background tasks to finish
+		stopJobs()
 		a.logger.Info("completing background tasks", "address", apiServer.Addr)
 		a.wg.Wait()
 
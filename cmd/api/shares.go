@@ -0,0 +1,206 @@
+// Filename: cmd/api/shares.go
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
+	"github.com/Duane-Arzu/test-1.git/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+func (a *applicationDependencies) createShareLinkHandler(w http.ResponseWriter, r *http.Request) {
+	listID, err := a.readIDParam(r, "lid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	// links never expire unless the owner asks for it, the body is optional
+	var incomingData struct {
+		ExpiresInHours int `json:"expires_in_hours"`
+	}
+	if r.ContentLength != 0 {
+		err = a.readJSON(w, r, &incomingData)
+		if err != nil {
+			a.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	v := validator.New()
+	v.Check(incomingData.ExpiresInHours >= 0, "expires_in_hours", "must not be negative")
+	v.Check(incomingData.ExpiresInHours <= 24*365, "expires_in_hours", "must not be more than a year")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, ok := a.readingListForUser(w, r, listID, listOwner)
+	if !ok {
+		return
+	}
+
+	user := a.contextGetUser(r)
+	ttl := time.Duration(incomingData.ExpiresInHours) * time.Hour
+	var share *data.ShareLink
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		share, err = m.Shares.New(r.Context(), listID, user.ID, ttl)
+		if err != nil {
+			return err
+		}
+		// the token is as good as a password, so it stays out of the log
+		logged := *share
+		logged.Plaintext = ""
+		return a.audit(m, r, auditReadingLists, listID, data.AuditUpdate, nil, map[string]any{"share": &logged})
+	})
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", "/api/v1/shared/lists/"+share.Plaintext)
+
+	data := envelope{
+		"share": share,
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) listShareLinksHandler(w http.ResponseWriter, r *http.Request) {
+	listID, err := a.readIDParam(r, "lid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	_, ok := a.readingListForUser(w, r, listID, listOwner)
+	if !ok {
+		return
+	}
+
+	shares, err := a.shareModel.GetAllForList(r.Context(), listID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"shares": shares,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) revokeShareLinkHandler(w http.ResponseWriter, r *http.Request) {
+	listID, err := a.readIDParam(r, "lid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+	shareID, err := a.readIDParam(r, "sid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	_, ok := a.readingListForUser(w, r, listID, listOwner)
+	if !ok {
+		return
+	}
+
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		before, err := findShareLink(r.Context(), m.Shares, listID, shareID)
+		if err != nil {
+			return err
+		}
+		err = m.Shares.Revoke(r.Context(), shareID, listID)
+		if err != nil {
+			return err
+		}
+		after, err := findShareLink(r.Context(), m.Shares, listID, shareID)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditReadingLists, listID, data.AuditUpdate, map[string]any{"share": before}, map[string]any{"share": after})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"message": "Share link successfully revoked",
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// findShareLink returns one of the share links of a list.
+func findShareLink(ctx context.Context, shares data.ShareRepository, listID, shareID int64) (*data.ShareLink, error) {
+	links, err := shares.GetAllForList(ctx, listID)
+	if err != nil {
+		return nil, err
+	}
+	for _, share := range links {
+		if share.ID == shareID {
+			return share, nil
+		}
+	}
+	return nil, data.ErrRecordNotFound
+}
+
+// showSharedListHandler is public: anyone holding a valid token can read the list.
+func (a *applicationDependencies) showSharedListHandler(w http.ResponseWriter, r *http.Request) {
+	token := httprouter.ParamsFromContext(r.Context()).ByName("token")
+
+	// an invalid token looks exactly like a missing list
+	v := validator.New()
+	data.ValidateTokenPlaintext(v, token)
+	if !v.IsEmpty() {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	list, err := a.shareModel.GetListForToken(r.Context(), token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	books, err := a.readingListModel.GetBookDetails(r.Context(), list.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"Reading List": list,
+		"books":        books,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,209 @@
+// Filename: cmd/api/stats.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
+	"github.com/Duane-Arzu/test-1.git/internal/validator"
+)
+
+func (a *applicationDependencies) userStatsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readUserIDParam(r, "uid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	// stats give away what someone reads
+	if id != a.contextGetUser(r).ID {
+		a.notPermittedResponse(w, r)
+		return
+	}
+
+	// default to the current year if no year was asked for
+	v := validator.New()
+	year := a.getSingleIntegerParameter(r.URL.Query(), "year", time.Now().Year(), v)
+	data.ValidateStatsYear(v, year)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	stats, err := a.statsModel.GetForUser(r.Context(), id, year)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"stats": stats,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) logProgressHandler(w http.ResponseWriter, r *http.Request) {
+	bookID, err := a.readIDParam(r, "bid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var incomingData struct {
+		PagesRead int    `json:"pages_read"`
+		LoggedOn  string `json:"logged_on"` // optional, YYYY-MM-DD
+	}
+	err = a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	loggedOn := time.Now()
+	if incomingData.LoggedOn != "" {
+		loggedOn, err = time.Parse(time.DateOnly, incomingData.LoggedOn)
+		if err != nil {
+			v.AddError("logged_on", "must be a date in the format YYYY-MM-DD")
+		}
+	}
+
+	user := a.contextGetUser(r)
+	progress := &data.ReadingProgress{
+		UserID:    user.ID,
+		BookID:    bookID,
+		PagesRead: incomingData.PagesRead,
+		LoggedOn:  loggedOn,
+	}
+
+	data.ValidateReadingProgress(v, progress)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	exists, err := a.bookModel.BookExists(r.Context(), bookID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		a.BIDnotFound(w, r, bookID)
+		return
+	}
+
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		err := m.Stats.InsertProgress(r.Context(), progress)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditProgress, progress.ID, data.AuditCreate, nil, progress)
+	})
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/users/%d/stats", user.ID))
+
+	data := envelope{
+		"progress": progress,
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) setReadingGoalHandler(w http.ResponseWriter, r *http.Request) {
+	var incomingData struct {
+		Year        int `json:"year"`
+		TargetBooks int `json:"target_books"`
+		TargetPages int `json:"target_pages"`
+	}
+	err := a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := a.contextGetUser(r)
+	goal := &data.ReadingGoal{
+		UserID:      user.ID,
+		Year:        incomingData.Year,
+		TargetBooks: incomingData.TargetBooks,
+		TargetPages: incomingData.TargetPages,
+	}
+	if goal.Year == 0 {
+		goal.Year = time.Now().Year()
+	}
+
+	v := validator.New()
+	data.ValidateReadingGoal(v, goal)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		previous, err := m.Stats.GetGoal(r.Context(), user.ID, goal.Year)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			return err
+		}
+		err = m.Stats.UpsertGoal(r.Context(), goal)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditUsers, user.ID, data.AuditUpdate,
+			map[string]any{"reading_goal": previous}, map[string]any{"reading_goal": goal})
+	})
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"goal": goal,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) showReadingGoalHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	year := a.getSingleIntegerParameter(r.URL.Query(), "year", time.Now().Year(), v)
+	data.ValidateStatsYear(v, year)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// the goal progress is worked out together with the rest of the stats
+	user := a.contextGetUser(r)
+	stats, err := a.statsModel.GetForUser(r.Context(), user.ID, year)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if stats.Goal == nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	data := envelope{
+		"goal": stats.Goal,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
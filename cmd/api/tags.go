@@ -0,0 +1,208 @@
+// Filename: cmd/api/tags.go
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
+	"github.com/Duane-Arzu/test-1.git/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+func (a *applicationDependencies) tagBookHandler(w http.ResponseWriter, r *http.Request) {
+	bookID, err := a.readIDParam(r, "bid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var incomingData struct {
+		Tag    string `json:"tag"`
+		Public *bool  `json:"public"` // tags are public unless the user says otherwise
+	}
+	err = a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := a.contextGetUser(r)
+	tag := &data.Tag{
+		UserID: user.ID,
+		BookID: bookID,
+		Name:   data.NormalizeTag(incomingData.Tag),
+		Public: true,
+	}
+	if incomingData.Public != nil {
+		tag.Public = *incomingData.Public
+	}
+
+	v := validator.New()
+	data.ValidateTag(v, tag)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	exists, err := a.bookModel.BookExists(r.Context(), bookID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		a.BIDnotFound(w, r, bookID)
+		return
+	}
+
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		tags, err := m.Tags.GetForBook(r.Context(), tag.UserID, bookID)
+		if err != nil {
+			return err
+		}
+		previous := findTag(tags, tag.Name)
+		err = m.Tags.Insert(r.Context(), tag)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditBooks, bookID, data.AuditUpdate, map[string]any{"tag": previous}, map[string]any{"tag": tag})
+	})
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"tag": tag,
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) untagBookHandler(w http.ResponseWriter, r *http.Request) {
+	bookID, err := a.readIDParam(r, "bid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	params := httprouter.ParamsFromContext(r.Context())
+	name := data.NormalizeTag(params.ByName("tag"))
+
+	user := a.contextGetUser(r)
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		tags, err := m.Tags.GetForBook(r.Context(), user.ID, bookID)
+		if err != nil {
+			return err
+		}
+		removed := findTag(tags, name)
+		if removed == nil {
+			return data.ErrRecordNotFound
+		}
+		err = m.Tags.Delete(r.Context(), user.ID, bookID, name)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditBooks, bookID, data.AuditUpdate, map[string]any{"tag": removed}, nil)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"message": "Tag successfully removed",
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// findTag returns the tag of that name among tags, or nil.
+func findTag(tags []*data.Tag, name string) *data.Tag {
+	for _, tag := range tags {
+		if tag.Name == name {
+			return tag
+		}
+	}
+	return nil
+}
+
+func (a *applicationDependencies) listMyTagsHandler(w http.ResponseWriter, r *http.Request) {
+	user := a.contextGetUser(r)
+
+	tags, err := a.tagModel.GetAllForUser(r.Context(), user.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"tags": tags,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) bookTagsHandler(w http.ResponseWriter, r *http.Request) {
+	bookID, err := a.readIDParam(r, "bid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	limit := a.getSingleIntegerParameter(r.URL.Query(), "limit", 20, v)
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 100, "limit", "must not exceed 100")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	exists, err := a.bookModel.BookExists(r.Context(), bookID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		a.BIDnotFound(w, r, bookID)
+		return
+	}
+
+	popular, err := a.tagModel.GetPopularForBook(r.Context(), bookID, limit)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"popular_tags": popular,
+	}
+
+	// logged in users also get to see their own tags on the book
+	user := a.contextGetUser(r)
+	if !user.IsAnonymous() {
+		mine, err := a.tagModel.GetForBook(r.Context(), user.ID, bookID)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		data["my_tags"] = mine
+	}
+
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
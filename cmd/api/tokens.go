@@ -39,7 +39,7 @@ func (a *applicationDependencies) createAuthenticationTokenHandler(w http.Respon
 	}
 
 	// Check if the email exists in the database
-	user, err := a.userModel.GetByEmail(incomingData.Email)
+	user, err := a.userModel.GetByEmail(r.Context(), incomingData.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound): // No user found for the given email
@@ -64,8 +64,17 @@ func (a *applicationDependencies) createAuthenticationTokenHandler(w http.Respon
 		return
 	}
 
-	// Create a new authentication token for the user
-	token, err := a.tokenModel.New(user.ID, 24*time.Hour, data.ScopeAuthentication)
+	// Create a new authentication token for the user; the log gets its expiry
+	// but never the token itself
+	var token *data.Token
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		token, err = m.Tokens.New(r.Context(), user.ID, 24*time.Hour, data.ScopeAuthentication)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditUsers, user.ID, data.AuditUpdate, nil,
+			map[string]any{"authentication_token": map[string]any{"expiry": token.Expiry}})
+	})
 	if err != nil {
 		// Send a "server error" response if token creation fails
 		a.serverErrorResponse(w, r, err)
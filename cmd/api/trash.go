@@ -0,0 +1,178 @@
+// Filename: cmd/api/trash.go
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
+)
+
+// purgeTrashEvery removes what has been in the trash for longer than
+// retention, straight away and then at every interval until ctx is cancelled.
+func (a *applicationDependencies) purgeTrashEvery(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		count, err := a.trashModel.Purge(ctx, time.Now().Add(-retention))
+		if err != nil {
+			a.logger.Error("could not purge the trash", "error", err)
+		} else if count > 0 {
+			a.logger.Info("purged trash", "records", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *applicationDependencies) listTrashHandler(w http.ResponseWriter, r *http.Request) {
+	user := a.contextGetUser(r)
+
+	// books have no owner, only moderators get to see them
+	permissions, err := a.permissionModel.GetAllForUser(r.Context(), user.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	trash, err := a.trashModel.GetForUser(r.Context(), user.ID, permissions.Include(data.PermissionModerateBooks))
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"trash":     trash,
+		"retention": a.config.trash.retention.String(),
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) restoreBookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "bid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var book *data.Book
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		err := m.Trash.RestoreBook(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		book, err = m.Books.Get(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditBooks, id, data.AuditRestore, nil, book)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.BIDnotFound(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"Book": book,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) restoreReadingListHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "lid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	// only the owner may restore a list, like only they may delete it
+	user := a.contextGetUser(r)
+
+	var list *data.ReadingList
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		err := m.Trash.RestoreReadingList(r.Context(), id, user.ID)
+		if err != nil {
+			return err
+		}
+		list, err = m.ReadingLists.Get(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditReadingLists, id, data.AuditRestore, nil, list)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.LIDnotFound(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"Reading List": list,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) restoreReviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "rid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	user := a.contextGetUser(r)
+
+	var review *data.Review
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		err := m.Trash.RestoreReview(r.Context(), id, user.ID)
+		if err != nil {
+			return err
+		}
+		review, err = m.Reviews.GetReview(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditReviews, id, data.AuditRestore, nil, review)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.RIDnotFound(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"review": review,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
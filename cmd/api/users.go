@@ -48,8 +48,20 @@ func (a *applicationDependencies) registerUserHandler(w http.ResponseWriter,
 		return
 	}
 
-	// Insert the user into the database
-	err = a.userModel.Insert(user)
+	// Insert the user together with their activation token, so a failure
+	// cannot leave behind an account nobody is able to activate
+	var token *data.Token
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		err := m.Users.Insert(r.Context(), user)
+		if err != nil {
+			return err
+		}
+		token, err = m.Tokens.New(r.Context(), user.ID, 3*24*time.Hour, data.ScopeActivation)
+		if err != nil {
+			return err
+		}
+		return a.audit(m, r, auditUsers, user.ID, data.AuditCreate, nil, user)
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateEmail):
@@ -60,11 +72,6 @@ func (a *applicationDependencies) registerUserHandler(w http.ResponseWriter,
 		}
 		return
 	}
-	token, err := a.tokenModel.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
-	if err != nil {
-		a.serverErrorResponse(w, r, err)
-		return
-	}
 
 	data := envelope{
 		"user": user,
@@ -75,7 +82,7 @@ func (a *applicationDependencies) registerUserHandler(w http.ResponseWriter,
 			"userID":          user.ID,
 		}
 
-		err = a.mailer.Send(user.Email, "user_welcome.tmpl", data)
+		err := a.mailer.Send(user.Email, "user_welcome.tmpl", data)
 		if err != nil {
 			a.logger.Error(err.Error())
 		}
@@ -108,7 +115,7 @@ func (a *applicationDependencies) activateUserHandler(w http.ResponseWriter, r *
 	}
 
 	// Find the user associated with the token
-	user, err := a.userModel.GetForToken(data.ScopeActivation,
+	user, err := a.userModel.GetForToken(r.Context(), data.ScopeActivation,
 		incomingData.TokenPlaintext)
 	if err != nil {
 		switch {
@@ -120,9 +127,25 @@ func (a *applicationDependencies) activateUserHandler(w http.ResponseWriter, r *
 		}
 		return
 	}
-	// User provided the right token so activate them
-	user.Activated = true
-	err = a.userModel.Update(user)
+	// User provided the right token so activate them and use up the token
+	err = a.transactor.WithinTx(r.Context(), func(m data.Models) error {
+		activated := *user
+		activated.Activated = true
+		err := m.Users.Update(r.Context(), &activated)
+		if err != nil {
+			return err
+		}
+		err = m.Tokens.DeleteAllForUser(r.Context(), data.ScopeActivation, user.ID)
+		if err != nil {
+			return err
+		}
+		err = a.audit(m, r, auditUsers, user.ID, data.AuditUpdate, user, &activated)
+		if err != nil {
+			return err
+		}
+		*user = activated
+		return nil
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
@@ -132,11 +155,6 @@ func (a *applicationDependencies) activateUserHandler(w http.ResponseWriter, r *
 		}
 		return
 	}
-	err = a.tokenModel.DeleteAllForUser(data.ScopeActivation, user.ID)
-	if err != nil {
-		a.serverErrorResponse(w, r, err)
-		return
-	}
 
 	// Send a response
 	data := envelope{
@@ -157,7 +175,7 @@ func (a *applicationDependencies) listUserProfileHandler(w http.ResponseWriter,
 		return
 	}
 
-	user, err := a.userModel.GetByID(id)
+	user, err := a.userModel.GetByID(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -190,7 +208,7 @@ func (a *applicationDependencies) getUserReviewsHandler(w http.ResponseWriter, r
 	}
 
 	// Get the reviews for the user
-	reviews, err := a.userModel.GetUserReviews(id)
+	reviews, err := a.userModel.GetUserReviews(r.Context(), id)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -217,7 +235,7 @@ func (a *applicationDependencies) getUserListsHandler(w http.ResponseWriter, r *
 	}
 
 	// Get the reviews for the user
-	lists, err := a.userModel.GetUserLists(id)
+	lists, err := a.userModel.GetUserLists(r.Context(), id)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
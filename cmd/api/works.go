@@ -0,0 +1,36 @@
+// Filename: cmd/api/works.go
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Duane-Arzu/test-1.git/internal/data"
+)
+
+func (a *applicationDependencies) displayWorkHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "wid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	work, err := a.workModel.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"work": work,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
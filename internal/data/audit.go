@@ -0,0 +1,181 @@
+// Filename: internal/data/audit.go
+package data
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// PermissionReadAudit lets a user read the audit log.
+const PermissionReadAudit = "audit:read"
+
+// Audit actions.
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+)
+
+// AuditFieldChange is one field of an audited record with its value before
+// and after the change, as JSON. From is null for a field that did not exist
+// before, To for one that no longer exists after.
+type AuditFieldChange struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
+}
+
+// AuditEvent records a change made through the API. Events are only ever
+// added, never changed.
+type AuditEvent struct {
+	ID         int64              `json:"id"`
+	ActorID    *int64             `json:"actor_id"` // nil for anonymous requests
+	Resource   string             `json:"resource"`
+	ResourceID int64              `json:"resource_id"`
+	Action     string             `json:"action"`
+	Changes    []AuditFieldChange `json:"changes"`
+	RequestID  string             `json:"request_id"`
+	IP         string             `json:"ip"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+// AuditDiff lists the top-level JSON fields that differ between before and
+// after, in the order of their names. Either may be nil, for a record that is
+// created or deleted; both are encoded the way the API shows them, so fields
+// hidden from clients stay out of the log as well.
+func AuditDiff(before, after any) ([]AuditFieldChange, error) {
+	from, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(from)+len(to))
+	for field := range from {
+		fields = append(fields, field)
+	}
+	for field := range to {
+		if _, ok := from[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+
+	changes := []AuditFieldChange{}
+	for _, field := range fields {
+		if !bytes.Equal(from[field], to[field]) {
+			changes = append(changes, AuditFieldChange{Field: field, From: from[field], To: to[field]})
+		}
+	}
+	return changes, nil
+}
+
+// jsonFields encodes v and splits the JSON object into its fields.
+func jsonFields(v any) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if v == nil {
+		return fields, nil
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// a nil pointer encodes as null, which has no fields either
+	err = json.Unmarshal(encoded, &fields)
+	if err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		fields = map[string]json.RawMessage{}
+	}
+	return fields, nil
+}
+
+type AuditModel struct {
+	DB      DBTX
+	Reader  DBTX          // the read replica, for requests marked by AllowReplicaReads
+	Timeout time.Duration // how long a single query may run
+}
+
+// Insert adds an event to the log. Run it in the unit of work that makes the
+// change, so the change and its event are committed or rolled back together.
+func (m AuditModel) Insert(ctx context.Context, event *AuditEvent) error {
+	changes, err := json.Marshal(event.Changes)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO audit_events (actor_id, resource, resource_id, action, changes, request_id, ip)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	args := []any{event.ActorID, event.Resource, event.ResourceID, event.Action, changes, event.RequestID, event.IP}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&event.ID, &event.CreatedAt)
+}
+
+// GetAll returns the events for a kind of record, or for a single record
+// when resourceID is set, the most recent first. An empty resource matches
+// every kind.
+func (m AuditModel) GetAll(ctx context.Context, resource string, resourceID int64, filters Filters) ([]*AuditEvent, Metadata, error) {
+	query := `
+		SELECT COUNT(*) OVER(), id, actor_id, resource, resource_id, action, changes, request_id, ip, created_at
+		FROM audit_events
+		WHERE (resource = $1 OR $1 = '')
+		AND (resource_id = $2 OR $2 = 0)
+		ORDER BY id DESC
+		LIMIT $3 OFFSET $4
+	`
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := readFrom(ctx, m.DB, m.Reader).QueryContext(ctx, query, resource, resourceID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	events := []*AuditEvent{}
+	for rows.Next() {
+		var event AuditEvent
+		var changes []byte
+		err := rows.Scan(
+			&totalRecords,
+			&event.ID,
+			&event.ActorID,
+			&event.Resource,
+			&event.ResourceID,
+			&event.Action,
+			&changes,
+			&event.RequestID,
+			&event.IP,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		err = json.Unmarshal(changes, &event.Changes)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		events = append(events, &event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+	return events, metadata, nil
+}
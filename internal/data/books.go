@@ -25,17 +25,39 @@ type Book struct {
 	Description     string  `json:"description"`      // Optional field, use a pointer to handle NULL
 	AverageRating   float32 `json:"average_rating"`   // DECIMAL maps to float64
 	Version         int32   `json:"version"`          // Default field for versioning
+	// Edition details, every book row is one edition of a work
+	WorkID    int64  `json:"work_id"`
+	Format    string `json:"format"`
+	Publisher string `json:"publisher"`
+	Language  string `json:"language"`
+	PageCount int    `json:"page_count"`
+	// Written as the URLs of the cover and its thumbnails
+	Cover CoverImage `json:"cover,omitempty"`
+	// Kept up to date by the trigger on bookreviews
+	RatingCount        int                `json:"rating_count"`
+	RatingDistribution RatingDistribution `json:"rating_distribution"`
+	WeightedRating     float64            `json:"weighted_rating"`
+	// Series the book belongs to, only filled in when a single book is fetched
+	Series []*SeriesEntry `json:"series,omitempty"`
+	// Set while the book is in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 type BookModel struct {
-	DB *sql.DB
+	DB      DBTX
+	Reader  DBTX          // the read replica, for requests marked by AllowReplicaReads
+	Timeout time.Duration // how long a single query may run
 }
 
 // Example Exists method in bookModel
-func (m *BookModel) Exists(bookID int) (bool, error) {
+func (m *BookModel) Exists(ctx context.Context, bookID int) (bool, error) {
 	var exists bool
-	query := "SELECT EXISTS (SELECT 1 FROM books WHERE id = $1)"
-	err := m.DB.QueryRow(query, bookID).Scan(&exists)
+	query := "SELECT EXISTS (SELECT 1 FROM books WHERE id = $1 AND deleted_at IS NULL)"
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, bookID).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
@@ -79,18 +101,29 @@ func (m *BookModel) Exists(bookID int) (bool, error) {
 
 // }
 
-func (m *BookModel) Insert(book *Book) error {
+// Insert adds a book. A book without a WorkID is the first edition of a new
+// work, which is created in the same statement.
+func (m *BookModel) Insert(ctx context.Context, book *Book) error {
 	query := `
-		INSERT INTO books (title, authors, isbn, publication_date, genre, description)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, version
+		WITH new_work AS (
+			INSERT INTO works (title, authors, description)
+			SELECT $1, $2, $6
+			WHERE $7 = 0
+			RETURNING id
+		)
+		INSERT INTO books (title, authors, isbn, publication_date, genre, description,
+		                   work_id, format, publisher, language, page_count)
+		VALUES ($1, $2, $3, $4, $5, $6,
+		        COALESCE((SELECT id FROM new_work), NULLIF($7, 0)), $8, $9, $10, $11)
+		RETURNING id, work_id, weighted_rating, version
 	`
-	args := []any{book.Title, book.Authors, book.ISBN, book.PublicationDate, book.Genre, book.Description}
+	args := []any{book.Title, book.Authors, book.ISBN, book.PublicationDate, book.Genre, book.Description,
+		book.WorkID, book.Format, book.Publisher, book.Language, book.PageCount}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&book.ID, &book.Version)
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&book.ID, &book.WorkID, &book.WeightedRating, &book.Version)
 }
 
 func ValidateBook(v *validator.Validator, book *Book) {
@@ -104,8 +137,17 @@ func ValidateBook(v *validator.Validator, book *Book) {
 	v.Check(strings.TrimSpace(book.PublicationDate) != "", "publication_date", "must be provided")
 	v.Check(strings.TrimSpace(book.Genre) != "", "genre", "must be provided")
 	v.Check(len(book.Description) <= 500, "description", "must not be more than 500 bytes long")
+	v.Check(book.WorkID >= 0, "work_id", "must be a positive integer")
+	v.Check(book.Format == "" || validator.PermittedValue(book.Format, BookFormats...), "format", "must be one of "+strings.Join(BookFormats, ", "))
+	v.Check(len(book.Publisher) <= 255, "publisher", "must not be more than 255 bytes long")
+	v.Check(len(book.Language) <= 35, "language", "must not be more than 35 bytes long")
+	v.Check(book.PageCount >= 0, "page_count", "must not be negative")
+	v.Check(book.PageCount <= 50000, "page_count", "must not be more than 50000")
 }
 
+// BookFormats lists the edition formats a book can have.
+var BookFormats = []string{"hardcover", "paperback", "ebook", "audiobook"}
+
 // func (c BookModel) ISBNExists(v *validator.Validator, isbn int) bool {
 // 	query := `
 //         SELECT 1
@@ -131,25 +173,27 @@ func ValidateBook(v *validator.Validator, book *Book) {
 // }
 
 // Get a specific Comment from the comments table
-func (c BookModel) Get(id int64) (*Book, error) {
+func (c BookModel) Get(ctx context.Context, id int64) (*Book, error) {
 	// check if the id is valid
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 	// the SQL query to be executed against the database table
 	query := `
-		 SELECT  id, title, authors, isbn, publication_date, genre, description, average_rating, version
+		 SELECT  id, title, authors, isbn, publication_date, genre, description, average_rating, version,
+		         COALESCE(work_id, 0), format, publisher, language, page_count, cover_key,
+		         rating_count, rating_distribution, weighted_rating
 		 FROM books
-		 WHERE id = $1
+		 WHERE id = $1 AND deleted_at IS NULL
 	   `
 	// declare a variable of type Comment to store the returned comment
 	var book Book
 
-	// Set a 3-second context/timer
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	// Bound the query by the model timeout
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 	defer cancel()
 
-	err := c.DB.QueryRowContext(ctx, query, id).Scan(
+	err := readFrom(ctx, c.DB, c.Reader).QueryRowContext(ctx, query, id).Scan(
 		&book.ID,
 		&book.Title,
 		&book.Authors, // pq.Array handles TEXT[] types
@@ -159,6 +203,15 @@ func (c BookModel) Get(id int64) (*Book, error) {
 		&book.Description,
 		&book.AverageRating,
 		&book.Version,
+		&book.WorkID,
+		&book.Format,
+		&book.Publisher,
+		&book.Language,
+		&book.PageCount,
+		&book.Cover,
+		&book.RatingCount,
+		&book.RatingDistribution,
+		&book.WeightedRating,
 	)
 	// Cont'd on the next slide
 	// check for which type of error
@@ -170,39 +223,59 @@ func (c BookModel) Get(id int64) (*Book, error) {
 			return nil, err
 		}
 	}
+
+	// embed the series the book is part of
+	book.Series, err = SeriesModel{DB: readFrom(ctx, c.DB, c.Reader), Timeout: c.Timeout}.GetForBook(ctx, book.ID)
+	if err != nil {
+		return nil, err
+	}
 	return &book, nil
 }
 
-func (c BookModel) Update(book *Book) error {
+func (c BookModel) Update(ctx context.Context, book *Book) error {
 	// The SQL query to be executed against the database table
 	// Every time we make an update, we increment the version number
 	query := `
 			UPDATE books
-			SET  title = $1, authors = $2, isbn = $3, publication_date = $4, genre = $5, description = $6, version = version + 1
-			WHERE id = $7
+			SET  title = $1, authors = $2, isbn = $3, publication_date = $4, genre = $5, description = $6,
+			     work_id = COALESCE(NULLIF($7, 0), work_id), format = $8, publisher = $9, language = $10, page_count = $11,
+			     version = version + 1
+			WHERE id = $12 AND version = $13 AND deleted_at IS NULL
 			RETURNING version 
 			`
 
-	args := []any{book.Title, book.Authors, book.ISBN, book.PublicationDate, book.Genre, book.Description, book.ID}
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	args := []any{book.Title, book.Authors, book.ISBN, book.PublicationDate, book.Genre, book.Description,
+		book.WorkID, book.Format, book.Publisher, book.Language, book.PageCount, book.ID, book.Version}
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 	defer cancel()
 
-	return c.DB.QueryRowContext(ctx, query, args...).Scan(&book.Version)
+	// no row means the book was changed (or deleted) since it was read
+	err := c.DB.QueryRowContext(ctx, query, args...).Scan(&book.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
 
 }
 
-func (c BookModel) Delete(id int64) error {
+func (c BookModel) Delete(ctx context.Context, id int64) error {
 
 	// check if the id is valid
 	if id < 1 {
 		return ErrRecordNotFound
 	}
-	// the SQL query to be executed against the database table
+	// the book only goes to the trash, the purge job removes it for good
 	query := `
-        DELETE FROM books
-        WHERE id = $1
+        UPDATE books
+        SET deleted_at = NOW()
+        WHERE id = $1 AND deleted_at IS NULL
 		`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 	defer cancel()
 
 	// ExecContext does not return any rows unlike QueryRowContext.
@@ -227,20 +300,27 @@ func (c BookModel) Delete(id int64) error {
 
 }
 
-func (c BookModel) GetAll(filters Filters) ([]*Book, Metadata, error) {
+// GetAll returns a page of books. If tag is not empty only the books the
+// user with userID tagged with it are returned.
+func (c BookModel) GetAll(ctx context.Context, tag string, userID int64, filters Filters) ([]*Book, Metadata, error) {
 
 	// the SQL query to be executed against the database table
 	query := fmt.Sprintf(`
-	SELECT COUNT(*) OVER(), id, title, authors, isbn, publication_date, genre, description, average_rating , version
+	SELECT COUNT(*) OVER(), id, title, authors, isbn, publication_date, genre, description, average_rating , version,
+	       COALESCE(work_id, 0), format, publisher, language, page_count, cover_key,
+	       rating_count, rating_distribution, weighted_rating
 	FROM books
+	WHERE deleted_at IS NULL
+	AND ($1 = '' OR id IN (
+		SELECT book_id FROM user_book_tags WHERE user_id = $2 AND tag = $1))
 	ORDER BY %s %s, id ASC
-	LIMIT $1 OFFSET $2
+	LIMIT $3 OFFSET $4
 	`, filters.sortColumn(), filters.sortDirection())
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 	defer cancel()
 
-	rows, err := c.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	rows, err := readFrom(ctx, c.DB, c.Reader).QueryContext(ctx, query, tag, userID, filters.limit(), filters.offset())
 
 	if err != nil {
 		return nil, Metadata{}, err
@@ -266,6 +346,15 @@ func (c BookModel) GetAll(filters Filters) ([]*Book, Metadata, error) {
 			&book.Description,
 			&book.AverageRating,
 			&book.Version,
+			&book.WorkID,
+			&book.Format,
+			&book.Publisher,
+			&book.Language,
+			&book.PageCount,
+			&book.Cover,
+			&book.RatingCount,
+			&book.RatingDistribution,
+			&book.WeightedRating,
 		)
 		if err != nil {
 			return nil, Metadata{}, err
@@ -286,13 +375,16 @@ func (c BookModel) GetAll(filters Filters) ([]*Book, Metadata, error) {
 
 }
 
-func (c BookModel) Search(title string, author string, genre string, filters Filters) ([]*Book, Metadata, error) {
+func (c BookModel) Search(ctx context.Context, title string, author string, genre string, filters Filters) ([]*Book, Metadata, error) {
 
 	// the SQL query to be executed against the database table
 	query := fmt.Sprintf(`
-	SELECT COUNT(*) OVER(), id, title, authors, isbn, publication_date, genre, description, average_rating, version
+	SELECT COUNT(*) OVER(), id, title, authors, isbn, publication_date, genre, description, average_rating, version,
+	       COALESCE(work_id, 0), format, publisher, language, page_count, cover_key,
+	       rating_count, rating_distribution, weighted_rating
 	FROM books
-	WHERE (to_tsvector('simple', title) @@
+	WHERE deleted_at IS NULL
+	AND (to_tsvector('simple', title) @@
 		  plainto_tsquery('simple', $1) OR $1 = '') 
 	AND (to_tsvector('simple', authors) @@ 
 		 plainto_tsquery('simple', $2) OR $2 = '')
@@ -301,10 +393,10 @@ func (c BookModel) Search(title string, author string, genre string, filters Fil
 	ORDER BY %s %s, id ASC 
 	LIMIT $4 OFFSET $5`, filters.sortColumn(), filters.sortDirection())
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 	defer cancel()
 
-	rows, err := c.DB.QueryContext(ctx, query, title, author, genre, filters.limit(), filters.offset())
+	rows, err := readFrom(ctx, c.DB, c.Reader).QueryContext(ctx, query, title, author, genre, filters.limit(), filters.offset())
 
 	if err != nil {
 		return nil, Metadata{}, err
@@ -330,6 +422,15 @@ func (c BookModel) Search(title string, author string, genre string, filters Fil
 			&book.Description,
 			&book.AverageRating,
 			&book.Version,
+			&book.WorkID,
+			&book.Format,
+			&book.Publisher,
+			&book.Language,
+			&book.PageCount,
+			&book.Cover,
+			&book.RatingCount,
+			&book.RatingDistribution,
+			&book.WeightedRating,
 		)
 		if err != nil {
 			return nil, Metadata{}, err
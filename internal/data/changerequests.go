@@ -0,0 +1,289 @@
+// Filename: internal/data/changerequests.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Duane-Arzu/test-1.git/internal/validator"
+)
+
+// Change request statuses.
+const (
+	ChangePending  = "pending"
+	ChangeApproved = "approved"
+	ChangeRejected = "rejected"
+)
+
+// ErrChangeConflict is returned when a field a change request touches was
+// edited by someone else after the change was proposed.
+var ErrChangeConflict = errors.New("the book was changed since the edit was proposed")
+
+// BookFieldChange is one field of a proposed edit with its value before and
+// after. Numbers are kept as their decimal text so every field looks the same.
+type BookFieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// ChangeRequest is an edit to a book proposed by a member for a moderator to
+// approve or reject.
+type ChangeRequest struct {
+	ID          int64             `json:"id"`
+	BookID      int64             `json:"book_id"`
+	ProposedBy  int64             `json:"proposed_by"`
+	BaseVersion int32             `json:"base_version"`
+	Changes     []BookFieldChange `json:"changes"`
+	Comment     string            `json:"comment"`
+	Status      string            `json:"status"`
+	Reason      string            `json:"reason,omitempty"`
+	ReviewedBy  *int64            `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time        `json:"reviewed_at,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	Version     int32             `json:"version"`
+}
+
+// editableBookFields are the fields of a book members can propose changes
+// to, in the order they are reported. Which work a book belongs to is left
+// to moderators.
+var editableBookFields = []string{"title", "authors", "isbn", "publication_date", "genre", "description",
+	"format", "publisher", "language", "page_count"}
+
+// bookField returns a pointer to the named field of a book; exactly one of
+// the two is set.
+func bookField(book *Book, field string) (*string, *int) {
+	switch field {
+	case "title":
+		return &book.Title, nil
+	case "authors":
+		return &book.Authors, nil
+	case "isbn":
+		return &book.ISBN, nil
+	case "publication_date":
+		return &book.PublicationDate, nil
+	case "genre":
+		return &book.Genre, nil
+	case "description":
+		return &book.Description, nil
+	case "format":
+		return &book.Format, nil
+	case "publisher":
+		return &book.Publisher, nil
+	case "language":
+		return &book.Language, nil
+	case "page_count":
+		return nil, &book.PageCount
+	}
+	return nil, nil
+}
+
+func bookFieldValue(book *Book, field string) string {
+	s, n := bookField(book, field)
+	if n != nil {
+		return strconv.Itoa(*n)
+	}
+	return *s
+}
+
+// DiffBooks lists the fields that differ between the current book and the
+// edited copy.
+func DiffBooks(current, edited *Book) []BookFieldChange {
+	changes := []BookFieldChange{}
+	for _, field := range editableBookFields {
+		from, to := bookFieldValue(current, field), bookFieldValue(edited, field)
+		if from != to {
+			changes = append(changes, BookFieldChange{Field: field, From: from, To: to})
+		}
+	}
+	return changes
+}
+
+// ApplyChanges applies a change request to the current version of a book. A
+// book that moved on since the change was proposed can still take it, as long
+// as none of the fields the change touches were edited in between.
+func (cr *ChangeRequest) ApplyChanges(book *Book) error {
+	for _, change := range cr.Changes {
+		s, n := bookField(book, change.Field)
+		switch {
+		case s == nil && n == nil:
+			return fmt.Errorf("unknown field %q in change request %d", change.Field, cr.ID)
+		case book.Version != cr.BaseVersion && bookFieldValue(book, change.Field) != change.From:
+			return ErrChangeConflict
+		case n != nil:
+			value, err := strconv.Atoi(change.To)
+			if err != nil {
+				return fmt.Errorf("change request %d: %s: %w", cr.ID, change.Field, err)
+			}
+			*n = value
+		default:
+			*s = change.To
+		}
+	}
+	return nil
+}
+
+func ValidateChangeRequest(v *validator.Validator, cr *ChangeRequest) {
+	v.Check(len(cr.Changes) > 0, "changes", "must change at least one field")
+	v.Check(len(cr.Comment) <= 500, "comment", "must not be more than 500 bytes long")
+}
+
+func ValidateChangeRejection(v *validator.Validator, reason string) {
+	v.Check(strings.TrimSpace(reason) != "", "reason", "must be provided")
+	v.Check(len(reason) <= 500, "reason", "must not be more than 500 bytes long")
+}
+
+type ChangeRequestModel struct {
+	DB      DBTX
+	Reader  DBTX          // the read replica, for requests marked by AllowReplicaReads
+	Timeout time.Duration // how long a single query may run
+}
+
+func (m ChangeRequestModel) Insert(ctx context.Context, cr *ChangeRequest) error {
+	changes, err := json.Marshal(cr.Changes)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO book_change_requests (book_id, proposed_by, base_version, changes, comment)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, created_at, version
+	`
+	args := []any{cr.BookID, cr.ProposedBy, cr.BaseVersion, changes, cr.Comment}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&cr.ID, &cr.Status, &cr.CreatedAt, &cr.Version)
+}
+
+const changeRequestColumns = `
+	id, book_id, COALESCE(proposed_by, 0), base_version, changes, comment, status, reason,
+	reviewed_by, reviewed_at, created_at, version`
+
+func scanChangeRequest(row interface{ Scan(...any) error }, cr *ChangeRequest) error {
+	var changes []byte
+	err := row.Scan(
+		&cr.ID,
+		&cr.BookID,
+		&cr.ProposedBy,
+		&cr.BaseVersion,
+		&changes,
+		&cr.Comment,
+		&cr.Status,
+		&cr.Reason,
+		&cr.ReviewedBy,
+		&cr.ReviewedAt,
+		&cr.CreatedAt,
+		&cr.Version,
+	)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(changes, &cr.Changes)
+}
+
+func (m ChangeRequestModel) Get(ctx context.Context, id int64) (*ChangeRequest, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `SELECT ` + changeRequestColumns + ` FROM book_change_requests WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var cr ChangeRequest
+	err := scanChangeRequest(readFrom(ctx, m.DB, m.Reader).QueryRowContext(ctx, query, id), &cr)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &cr, nil
+}
+
+// GetAll lists change requests, oldest first so moderators work through the
+// queue in order. An empty status or a zero book id matches every request.
+func (m ChangeRequestModel) GetAll(ctx context.Context, status string, bookID int64, filters Filters) ([]*ChangeRequest, Metadata, error) {
+	query := `
+		SELECT COUNT(*) OVER(), ` + changeRequestColumns + `
+		FROM book_change_requests
+		WHERE (status = $1 OR $1 = '')
+		AND (book_id = $2 OR $2 = 0)
+		ORDER BY created_at ASC, id ASC
+		LIMIT $3 OFFSET $4
+	`
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := readFrom(ctx, m.DB, m.Reader).QueryContext(ctx, query, status, bookID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	requests := []*ChangeRequest{}
+	for rows.Next() {
+		var cr ChangeRequest
+		err := scanChangeRequest(scanWithCount{rows, &totalRecords}, &cr)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		requests = append(requests, &cr)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+	return requests, metadata, nil
+}
+
+// scanWithCount reads the COUNT(*) OVER() column in front of the columns
+// scanChangeRequest knows about.
+type scanWithCount struct {
+	rows  *sql.Rows
+	count *int
+}
+
+func (s scanWithCount) Scan(dest ...any) error {
+	return s.rows.Scan(append([]any{s.count}, dest...)...)
+}
+
+// Resolve records a moderator's decision. Only pending requests can be
+// resolved; ErrEditConflict means someone else got there first.
+func (m ChangeRequestModel) Resolve(ctx context.Context, cr *ChangeRequest) error {
+	query := `
+		UPDATE book_change_requests
+		SET status = $1, reason = $2, reviewed_by = $3, reviewed_at = NOW(), version = version + 1
+		WHERE id = $4 AND version = $5 AND status = 'pending'
+		RETURNING reviewed_at, version
+	`
+	args := []any{cr.Status, cr.Reason, cr.ReviewedBy, cr.ID, cr.Version}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&cr.ReviewedAt, &cr.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+// Filename: internal/data/covers.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+// CoverSize is a thumbnail width generated for every cover.
+type CoverSize struct {
+	Name  string
+	Width int
+}
+
+// CoverSizes are the thumbnails generated for every uploaded cover.
+var CoverSizes = []CoverSize{
+	{Name: "small", Width: 64},
+	{Name: "medium", Width: 200},
+	{Name: "large", Width: 400},
+}
+
+// CoverOriginal is the name the uploaded image is kept under next to its thumbnails.
+const CoverOriginal = "original"
+
+// CoverImage is the storage key of a book's cover, the folder holding the
+// original and the thumbnails. An empty key means the book has no cover.
+type CoverImage string
+
+// URL returns the path a version of the cover is served from.
+func (c CoverImage) URL(name string) string {
+	if name != CoverOriginal {
+		name += ".jpg"
+	}
+	return "/api/v1/" + string(c) + "/" + name
+}
+
+// MarshalJSON writes the cover as the URLs of the original and each thumbnail.
+func (c CoverImage) MarshalJSON() ([]byte, error) {
+	if c == "" {
+		return []byte("null"), nil
+	}
+	urls := map[string]string{CoverOriginal: c.URL(CoverOriginal)}
+	for _, size := range CoverSizes {
+		urls[size.Name] = c.URL(size.Name)
+	}
+	return json.Marshal(urls)
+}
+
+// SetCover points a book at a newly stored cover and returns the key of the
+// cover it replaced, if any.
+func (c BookModel) SetCover(ctx context.Context, bookID int64, cover CoverImage) (CoverImage, error) {
+	query := `
+		UPDATE books b
+		SET cover_key = $1, version = b.version + 1
+		FROM (SELECT id, cover_key FROM books WHERE id = $2 AND deleted_at IS NULL FOR UPDATE) old
+		WHERE b.id = old.id
+		RETURNING old.cover_key
+	`
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	var previous CoverImage
+	err := c.DB.QueryRowContext(ctx, query, cover, bookID).Scan(&previous)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+	return previous, nil
+}
@@ -0,0 +1,255 @@
+// Filename: internal/data/duplicates.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// DuplicateCandidate is a pair of books that are probably the same book.
+type DuplicateCandidate struct {
+	Book       BookSummary `json:"book"`
+	Duplicate  BookSummary `json:"duplicate"`
+	Reason     string      `json:"reason"` // "isbn" or "similar_title"
+	Similarity float64     `json:"similarity"`
+}
+
+// BookSummary is the part of a book a moderator needs to compare two books.
+type BookSummary struct {
+	ID      int64  `json:"id"`
+	Title   string `json:"title"`
+	Authors string `json:"authors"`
+	ISBN    string `json:"isbn"`
+}
+
+// FindDuplicates looks for pairs of books that share the same normalized
+// ISBN or whose title and authors are at least minSimilarity alike (0-1).
+func (c BookModel) FindDuplicates(ctx context.Context, minSimilarity float64, filters Filters) ([]*DuplicateCandidate, Metadata, error) {
+	query := `
+	WITH pairs AS (
+		SELECT a.id AS book_id, b.id AS duplicate_id, 'isbn' AS reason, 1.0::float8 AS similarity
+		FROM books a
+		INNER JOIN books b ON a.id < b.id AND b.deleted_at IS NULL
+		AND UPPER(REGEXP_REPLACE(a.isbn, '[^0-9Xx]', '', 'g')) = UPPER(REGEXP_REPLACE(b.isbn, '[^0-9Xx]', '', 'g'))
+		WHERE a.deleted_at IS NULL
+		UNION ALL
+		SELECT a.id, b.id, 'similar_title',
+		       similarity(a.title || ' ' || COALESCE(a.authors, ''), b.title || ' ' || COALESCE(b.authors, ''))::float8
+		FROM books a
+		INNER JOIN books b ON a.id < b.id AND b.deleted_at IS NULL
+		AND (a.title || ' ' || COALESCE(a.authors, '')) % (b.title || ' ' || COALESCE(b.authors, ''))
+		WHERE a.deleted_at IS NULL
+	), best AS (
+		-- a pair found by both rules is only reported once, preferring the ISBN match
+		SELECT DISTINCT ON (book_id, duplicate_id) book_id, duplicate_id, reason, similarity
+		FROM pairs
+		WHERE similarity >= $1
+		ORDER BY book_id, duplicate_id, similarity DESC
+	)
+	SELECT COUNT(*) OVER(), best.reason, best.similarity,
+	       a.id, a.title, COALESCE(a.authors, ''), a.isbn,
+	       b.id, b.title, COALESCE(b.authors, ''), b.isbn
+	FROM best
+	INNER JOIN books a ON a.id = best.book_id
+	INNER JOIN books b ON b.id = best.duplicate_id
+	ORDER BY best.similarity DESC, a.id ASC, b.id ASC
+	LIMIT $2 OFFSET $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, minSimilarity, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	candidates := []*DuplicateCandidate{}
+	for rows.Next() {
+		var candidate DuplicateCandidate
+		err := rows.Scan(&totalRecords,
+			&candidate.Reason,
+			&candidate.Similarity,
+			&candidate.Book.ID,
+			&candidate.Book.Title,
+			&candidate.Book.Authors,
+			&candidate.Book.ISBN,
+			&candidate.Duplicate.ID,
+			&candidate.Duplicate.Title,
+			&candidate.Duplicate.Authors,
+			&candidate.Duplicate.ISBN,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		candidates = append(candidates, &candidate)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+	return candidates, metadata, nil
+}
+
+// Merge folds the duplicate book into the canonical one: reviews, reading
+// list entries, tags, series places and progress logs move over, the
+// duplicate is deleted and a redirect is left behind for its id. Everything
+// happens in one transaction.
+func (c BookModel) Merge(ctx context.Context, canonicalID, duplicateID, mergedBy int64) error {
+	if canonicalID == duplicateID {
+		return errors.New("a book cannot be merged into itself")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, c.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// lock both books so nobody edits them halfway through the merge
+	var locked int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM (SELECT id FROM books WHERE id IN ($1, $2) AND deleted_at IS NULL ORDER BY id FOR UPDATE) b`,
+		canonicalID, duplicateID).Scan(&locked)
+	if err != nil {
+		return err
+	}
+	if locked != 2 {
+		return ErrRecordNotFound
+	}
+
+	var duplicateWork sql.NullInt64
+	err = tx.QueryRowContext(ctx, `SELECT work_id FROM books WHERE id = $1`, duplicateID).Scan(&duplicateWork)
+	if err != nil {
+		return err
+	}
+
+	statements := []string{
+		// reviews
+		`UPDATE bookreviews SET book_id = $1 WHERE book_id = $2`,
+		// reading list entries, unless the list already holds the canonical book
+		`UPDATE readinglist_books SET book_id = $1
+		 WHERE book_id = $2 AND NOT EXISTS (
+			SELECT 1 FROM readinglist_books x
+			WHERE x.readinglist_id = readinglist_books.readinglist_id AND x.book_id = $1)`,
+		// tags
+		`INSERT INTO user_book_tags (user_id, book_id, tag, is_public, created_at)
+		 SELECT user_id, $1, tag, is_public, created_at FROM user_book_tags WHERE book_id = $2
+		 ON CONFLICT DO NOTHING`,
+		// series places, unless the canonical book already has one in the series
+		`UPDATE series_books SET book_id = $1
+		 WHERE book_id = $2 AND NOT EXISTS (
+			SELECT 1 FROM series_books x WHERE x.series_id = series_books.series_id AND x.book_id = $1)`,
+		// progress logs
+		`UPDATE reading_progress SET book_id = $1 WHERE book_id = $2`,
+		// earlier redirects to the duplicate now point to the canonical book
+		`UPDATE book_redirects SET new_id = $1 WHERE new_id = $2`,
+	}
+	for _, statement := range statements {
+		_, err = tx.ExecContext(ctx, statement, canonicalID, duplicateID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// lists that held both books lose the duplicate entry, renumber them so
+	// their positions stay contiguous
+	var affectedLists []int64
+	rows, err := tx.QueryContext(ctx, `
+		DELETE FROM readinglist_books WHERE book_id = $1 RETURNING readinglist_id`, duplicateID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var listID int64
+		err = rows.Scan(&listID)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		affectedLists = append(affectedLists, listID)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for _, listID := range affectedLists {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE readinglist_books rb
+			SET position = numbered.position
+			FROM (
+				SELECT book_id, ROW_NUMBER() OVER (ORDER BY position) - 1 AS position
+				FROM readinglist_books WHERE readinglist_id = $1
+			) numbered
+			WHERE rb.readinglist_id = $1 AND rb.book_id = numbered.book_id`, listID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM books WHERE id = $1`, duplicateID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO book_redirects (old_id, new_id, merged_by) VALUES ($1, $2, $3)`,
+		duplicateID, canonicalID, mergedBy)
+	if err != nil {
+		return err
+	}
+
+	// recompute the rating now that the reviews of both books are together
+	_, err = tx.ExecContext(ctx, `
+		UPDATE books SET average_rating = COALESCE((
+			SELECT ROUND(CAST(AVG(rating) AS NUMERIC), 2) FROM bookreviews WHERE book_id = $1 AND deleted_at IS NULL
+		), 0), version = version + 1
+		WHERE id = $1`, canonicalID)
+	if err != nil {
+		return err
+	}
+
+	// the duplicate's work loses an edition, drop it if it has none left
+	if duplicateWork.Valid {
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM works WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM books WHERE work_id = $1)`,
+			duplicateWork.Int64)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			SELECT refresh_work_rating($1), refresh_work_rating((SELECT work_id FROM books WHERE id = $2))`,
+			duplicateWork.Int64, canonicalID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetRedirect returns the id a merged book now lives under.
+func (c BookModel) GetRedirect(ctx context.Context, oldID int64) (int64, error) {
+	query := `SELECT new_id FROM book_redirects WHERE old_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	var newID int64
+	err := c.DB.QueryRowContext(ctx, query, oldID).Scan(&newID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrRecordNotFound
+		}
+		return 0, err
+	}
+	return newID, nil
+}
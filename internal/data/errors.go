@@ -1,7 +1,10 @@
 package data
 
 import (
+	"context"
 	"errors"
+
+	"github.com/lib/pq"
 )
 
 var ErrRecordNotFound = errors.New("record not found")
@@ -10,3 +13,14 @@ var ErrDuplicateEmail = errors.New("duplicate email")
 var ErrEditConflict = errors.New("edit conflict")
 
 var ErrDuplicateBookInList = errors.New("duplicate book in reading list")
+
+// IsCanceled reports whether err comes from a query that was stopped by its
+// context, because the request went away or the query ran out of time.
+// PostgreSQL reports the statement it cancelled as query_canceled (57014).
+func IsCanceled(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "57014"
+}
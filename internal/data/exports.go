@@ -0,0 +1,328 @@
+// Filename: internal/data/exports.go
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// UserExport is everything a user has put into the site.
+type UserExport struct {
+	Profile     *User              `json:"profile"`
+	Lists       []*ExportedList    `json:"reading_lists"`
+	Reviews     []*ExportedReview  `json:"reviews"`
+	Progress    []*ReadingProgress `json:"progress"`
+	Goals       []*ReadingGoal     `json:"goals"`
+	GeneratedAt time.Time          `json:"generated_at"`
+}
+
+// ExportedList is a reading list of the user with the books on it.
+type ExportedList struct {
+	ReadingList
+	Items []*ExportedListItem `json:"items"`
+}
+
+// ExportedListItem is a book on a reading list.
+type ExportedListItem struct {
+	BookID      int64      `json:"book_id"`
+	Title       string     `json:"title"`
+	Authors     string     `json:"authors"`
+	ISBN        string     `json:"isbn"`
+	Status      string     `json:"status"`
+	Position    int        `json:"position"`
+	AddedAt     time.Time  `json:"added_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// ExportedReview is a review by the user with enough of the book to know
+// which one it was without the catalog.
+type ExportedReview struct {
+	ID         int64     `json:"id"`
+	BookID     int64     `json:"book_id"`
+	Title      string    `json:"title"`
+	Authors    string    `json:"authors"`
+	ISBN       string    `json:"isbn"`
+	Rating     int64     `json:"rating"`
+	Review     string    `json:"review"`
+	ReviewedAt time.Time `json:"reviewed_at"`
+}
+
+// ExportArchive is a generated export waiting to be downloaded.
+type ExportArchive struct {
+	UserID    int64
+	Format    string
+	Archive   []byte
+	CreatedAt time.Time
+}
+
+type ExportModel struct {
+	DB      DBTX
+	Timeout time.Duration // how long a single query may run
+}
+
+// Size counts the records an export of the user would contain. It is used to
+// decide whether the export can be built while the client waits.
+func (m ExportModel) Size(ctx context.Context, userID int64) (int, error) {
+	query := `
+		SELECT (SELECT COUNT(*) FROM readinglist_books rb
+		        INNER JOIN readinglists rl ON rl.id = rb.readinglist_id
+		        WHERE rl.created_by = $1 AND rl.deleted_at IS NULL)
+		     + (SELECT COUNT(*) FROM bookreviews WHERE user_id = $1 AND deleted_at IS NULL)
+		     + (SELECT COUNT(*) FROM reading_progress WHERE user_id = $1)
+	`
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var size int
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&size)
+	return size, err
+}
+
+// GetForUser collects the profile, reading lists, reviews, progress logs and
+// goals of a user.
+func (m ExportModel) GetForUser(ctx context.Context, userID int64) (*UserExport, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	export := &UserExport{
+		Profile:     &User{},
+		Lists:       []*ExportedList{},
+		Reviews:     []*ExportedReview{},
+		Progress:    []*ReadingProgress{},
+		Goals:       []*ReadingGoal{},
+		GeneratedAt: time.Now(),
+	}
+
+	err := m.DB.QueryRowContext(ctx, `
+		SELECT id, created_at, username, email, activated FROM users WHERE id = $1`, userID).Scan(
+		&export.Profile.ID,
+		&export.Profile.CreatedAt,
+		&export.Profile.Username,
+		&export.Profile.Email,
+		&export.Profile.Activated,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	// lists and their books come back in one query, ordered so the books of a
+	// list follow each other
+	rows, err := m.DB.QueryContext(ctx, `
+		SELECT rl.id, COALESCE(rl.name, ''), COALESCE(rl.description, ''), rl.is_public, rl.version,
+		       b.id, b.title, COALESCE(b.authors, ''), b.isbn, rb.status, rb.position, rb.added_at, rb.completed_at
+		FROM readinglists rl
+		LEFT JOIN readinglist_books rb ON rb.readinglist_id = rl.id
+		LEFT JOIN books b ON b.id = rb.book_id AND b.deleted_at IS NULL
+		WHERE rl.created_by = $1 AND rl.deleted_at IS NULL
+		ORDER BY rl.id, rb.position`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var current *ExportedList
+	for rows.Next() {
+		var list ExportedList
+		var bookID sql.NullInt64
+		var title, authors, isbn, status sql.NullString
+		var position sql.NullInt32
+		var addedAt sql.NullTime
+		var item ExportedListItem
+		err := rows.Scan(
+			&list.ID,
+			&list.Name,
+			&list.Description,
+			&list.IsPublic,
+			&list.Version,
+			&bookID,
+			&title,
+			&authors,
+			&isbn,
+			&status,
+			&position,
+			&addedAt,
+			&item.CompletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if current == nil || current.ID != list.ID {
+			list.CreatedBy = int(userID)
+			list.Items = []*ExportedListItem{}
+			current = &list
+			export.Lists = append(export.Lists, current)
+		}
+		if !bookID.Valid {
+			continue // an empty list
+		}
+		item.BookID = bookID.Int64
+		item.Title = title.String
+		item.Authors = authors.String
+		item.ISBN = isbn.String
+		item.Status = status.String
+		item.Position = int(position.Int32)
+		item.AddedAt = addedAt.Time
+		current.Items = append(current.Items, &item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = m.DB.QueryContext(ctx, `
+		SELECT r.id, r.book_id, b.title, COALESCE(b.authors, ''), b.isbn,
+		       r.rating, COALESCE(r.review, ''), r.review_date
+		FROM bookreviews r
+		INNER JOIN books b ON b.id = r.book_id AND b.deleted_at IS NULL
+		WHERE r.user_id = $1 AND r.deleted_at IS NULL
+		ORDER BY r.review_date, r.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var review ExportedReview
+		err := rows.Scan(
+			&review.ID,
+			&review.BookID,
+			&review.Title,
+			&review.Authors,
+			&review.ISBN,
+			&review.Rating,
+			&review.Review,
+			&review.ReviewedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		export.Reviews = append(export.Reviews, &review)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = m.DB.QueryContext(ctx, `
+		SELECT id, user_id, book_id, pages_read, logged_on, version
+		FROM reading_progress
+		WHERE user_id = $1
+		ORDER BY logged_on, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var progress ReadingProgress
+		err := rows.Scan(
+			&progress.ID,
+			&progress.UserID,
+			&progress.BookID,
+			&progress.PagesRead,
+			&progress.LoggedOn,
+			&progress.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		export.Progress = append(export.Progress, &progress)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = m.DB.QueryContext(ctx, `
+		SELECT user_id, year, target_books, target_pages, version
+		FROM reading_goals
+		WHERE user_id = $1
+		ORDER BY year`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var goal ReadingGoal
+		err := rows.Scan(
+			&goal.UserID,
+			&goal.Year,
+			&goal.TargetBooks,
+			&goal.TargetPages,
+			&goal.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		export.Goals = append(export.Goals, &goal)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// SaveArchive stores a generated archive and returns the token it can be
+// downloaded with. Archives whose token expired are cleared out on the way.
+func (m ExportModel) SaveArchive(ctx context.Context, userID int64, format string, archive []byte, ttl time.Duration) (*Token, error) {
+	token, err := generateToken(userID, ttl, ScopeExport)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, `DELETE FROM user_exports WHERE expiry < NOW()`)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = m.DB.ExecContext(ctx, `
+		INSERT INTO user_exports (hash, user_id, format, archive, expiry)
+		VALUES ($1, $2, $3, $4, $5)`,
+		token.Hash, userID, format, archive, token.Expiry)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// GetArchive returns the archive a download token points to, as long as the
+// token has not expired.
+func (m ExportModel) GetArchive(ctx context.Context, tokenPlaintext string) (*ExportArchive, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+		SELECT user_id, format, archive, created_at
+		FROM user_exports
+		WHERE hash = $1 AND expiry > $2
+	`
+	var archive ExportArchive
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, tokenHash[:], time.Now()).Scan(
+		&archive.UserID,
+		&archive.Format,
+		&archive.Archive,
+		&archive.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &archive, nil
+}
@@ -0,0 +1,322 @@
+// Filename: internal/data/goodreads.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Duane-Arzu/test-1.git/internal/validator"
+	"github.com/lib/pq"
+)
+
+// GoodreadsEntry is one row of a Goodreads library export.
+type GoodreadsEntry struct {
+	Title          string
+	Author         string
+	ISBN           string // normalized ISBN-10
+	ISBN13         string // normalized ISBN-13
+	MyRating       int
+	Publisher      string
+	Binding        string
+	Pages          int
+	YearPublished  string
+	DateRead       *time.Time
+	DateAdded      *time.Time
+	Shelves        []string // every shelf the book is on, the exclusive one included
+	ExclusiveShelf string
+	Review         string
+}
+
+// GoodreadsResult tells what importing a single entry changed.
+type GoodreadsResult struct {
+	BookID  int64
+	Book    *Book          // set when the book was added to the catalog
+	Entries []*BooksInList // the lists the book was put on
+	Review  *Review        // set when the rating was imported
+}
+
+// ErrGoodreadsNoISBN is returned for entries that match no book in the
+// catalog and carry no ISBN to add them with.
+var ErrGoodreadsNoISBN = errors.New("the book is not in the catalog and has no ISBN to add it with")
+
+// GoodreadsGenre is the genre of the books an import adds, as the export
+// does not carry one.
+const GoodreadsGenre = "unclassified"
+
+// GoodreadsBookError is returned for entries whose book is missing from the
+// catalog and would not pass ValidateBook, with what is wrong with it.
+type GoodreadsBookError struct {
+	Errors map[string]string
+}
+
+func (e *GoodreadsBookError) Error() string {
+	problems := make([]string, 0, len(e.Errors))
+	for _, key := range slices.Sorted(maps.Keys(e.Errors)) {
+		problems = append(problems, key+" "+e.Errors[key])
+	}
+	return "the book cannot be added to the catalog, its " + strings.Join(problems, ", ")
+}
+
+// goodreadsTitles are the titles an entry's book may have in the catalog.
+// Goodreads appends the series to the title, "Dune (Dune, #1)", so the last
+// of them is the title without it.
+func goodreadsTitles(title string) []string {
+	titles := []string{title}
+	if i := strings.LastIndex(title, " ("); i > 0 && strings.HasSuffix(title, ")") {
+		titles = append(titles, title[:i])
+	}
+	return titles
+}
+
+// goodreadsBook is the book an entry adds to the catalog, held to the same
+// rules as a book added through the API.
+func goodreadsBook(entry *GoodreadsEntry, isbn13 string) (*Book, error) {
+	titles := goodreadsTitles(entry.Title)
+	book := &Book{
+		Title:           titles[len(titles)-1],
+		Authors:         entry.Author,
+		ISBN:            isbn13,
+		PublicationDate: entry.YearPublished,
+		Genre:           GoodreadsGenre,
+		Format:          GoodreadsFormat(entry.Binding),
+		Publisher:       entry.Publisher,
+		PageCount:       entry.Pages,
+	}
+	v := validator.New()
+	if ValidateBook(v, book); !v.IsEmpty() {
+		return nil, &GoodreadsBookError{Errors: v.Errors}
+	}
+	return book, nil
+}
+
+// GoodreadsShelfStatus maps a Goodreads exclusive shelf onto a reading status.
+// Custom exclusive shelves count as books still to be read.
+func GoodreadsShelfStatus(shelf string) string {
+	switch shelf {
+	case "read":
+		return "completed"
+	case "currently-reading":
+		return "currently reading"
+	default:
+		return "to read"
+	}
+}
+
+// GoodreadsShelfForStatus is the reverse of GoodreadsShelfStatus.
+func GoodreadsShelfForStatus(status string) string {
+	switch status {
+	case "completed":
+		return "read"
+	case "currently reading":
+		return "currently-reading"
+	default:
+		return "to-read"
+	}
+}
+
+// GoodreadsFormat maps a Goodreads binding onto one of the BookFormats.
+func GoodreadsFormat(binding string) string {
+	binding = strings.ToLower(binding)
+	switch {
+	case strings.Contains(binding, "hardcover"):
+		return "hardcover"
+	case strings.Contains(binding, "paperback"):
+		return "paperback"
+	case strings.Contains(binding, "kindle"), strings.Contains(binding, "ebook"):
+		return "ebook"
+	case strings.Contains(binding, "audio"):
+		return "audiobook"
+	default:
+		return ""
+	}
+}
+
+// ISBN10To13 converts a normalized ISBN-10 to its ISBN-13 form.
+func ISBN10To13(isbn string) string {
+	if len(isbn) != 10 {
+		return ""
+	}
+	digits := "978" + isbn[:9]
+	sum := 0
+	for i, r := range digits {
+		if r < '0' || r > '9' {
+			return ""
+		}
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(r-'0') * weight
+	}
+	return digits + string(rune('0'+(10-sum%10)%10))
+}
+
+// EnsureGoodreadsList returns the user's reading list for a Goodreads shelf,
+// creating it the first time the shelf is seen, and whether it did.
+func (m ImportJobModel) EnsureGoodreadsList(ctx context.Context, userID int64, shelf string) (*ReadingList, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	list := &ReadingList{}
+	err := m.DB.QueryRowContext(ctx, `
+		SELECT id, name, description, created_by, is_public, version
+		FROM readinglists WHERE created_by = $1 AND name = $2 AND deleted_at IS NULL ORDER BY id LIMIT 1`,
+		userID, shelf).Scan(&list.ID, &list.Name, &list.Description, &list.CreatedBy, &list.IsPublic, &list.Version)
+	if err == nil {
+		return list, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	list = &ReadingList{
+		Name:        shelf,
+		Description: "Imported from the Goodreads shelf '" + shelf + "'",
+		CreatedBy:   int(userID),
+	}
+	err = m.DB.QueryRowContext(ctx, `
+		INSERT INTO readinglists (name, description, created_by)
+		VALUES ($1, $2, $3)
+		RETURNING id, version`,
+		list.Name, list.Description, userID).Scan(&list.ID, &list.Version)
+	if err != nil {
+		return nil, false, err
+	}
+	return list, true, nil
+}
+
+// ImportGoodreadsEntry matches the entry to a book (by ISBN, then by title
+// and author), adds the book if it is missing, puts it on the given lists and
+// imports the rating and review. Everything for one entry happens in one
+// transaction, or a savepoint of the caller's, so a failing entry leaves
+// nothing behind.
+func (m ImportJobModel) ImportGoodreadsEntry(ctx context.Context, userID int64, entry *GoodreadsEntry, listIDs []int64) (*GoodreadsResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &GoodreadsResult{}
+
+	isbn13 := entry.ISBN13
+	if isbn13 == "" {
+		isbn13 = ISBN10To13(entry.ISBN)
+	}
+
+	isbns := []string{}
+	for _, isbn := range []string{isbn13, entry.ISBN} {
+		if isbn != "" {
+			isbns = append(isbns, isbn)
+		}
+	}
+	if len(isbns) > 0 {
+		err = tx.QueryRowContext(ctx, `
+			SELECT id FROM books
+			WHERE UPPER(REGEXP_REPLACE(isbn, '[^0-9Xx]', '', 'g')) = ANY($1) AND deleted_at IS NULL
+			ORDER BY id LIMIT 1`,
+			pq.Array(isbns)).Scan(&result.BookID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	if result.BookID == 0 {
+		titles := goodreadsTitles(strings.ToLower(entry.Title))
+		// strpos rather than LIKE, so a % or _ in the author is not a wildcard
+		err = tx.QueryRowContext(ctx, `
+			SELECT id FROM books
+			WHERE LOWER(title) = ANY($1) AND deleted_at IS NULL
+			AND STRPOS(LOWER(COALESCE(authors, '')), LOWER($2)) > 0
+			ORDER BY id LIMIT 1`,
+			pq.Array(titles), entry.Author).Scan(&result.BookID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	if result.BookID == 0 {
+		if isbn13 == "" {
+			return nil, ErrGoodreadsNoISBN
+		}
+		result.Book, err = goodreadsBook(entry, isbn13)
+		if err != nil {
+			return nil, err
+		}
+		book := result.Book
+		err = tx.QueryRowContext(ctx, `
+			WITH new_work AS (
+				INSERT INTO works (title, authors, description)
+				VALUES ($1, $2, '')
+				RETURNING id
+			)
+			INSERT INTO books (title, authors, isbn, publication_date, genre, description,
+			                   work_id, format, publisher, page_count)
+			VALUES ($1, $2, $3, $4, $5, '', (SELECT id FROM new_work), $6, $7, $8)
+			RETURNING id, work_id, version`,
+			book.Title, book.Authors, book.ISBN, book.PublicationDate, book.Genre,
+			book.Format, book.Publisher, book.PageCount).Scan(&book.ID, &book.WorkID, &book.Version)
+		if err != nil {
+			return nil, err
+		}
+		result.BookID = book.ID
+	}
+
+	status := GoodreadsShelfStatus(entry.ExclusiveShelf)
+	for _, listID := range listIDs {
+		// locked like AddBookToList does, so the position is not taken twice
+		_, err := tx.ExecContext(ctx, `SELECT id FROM readinglists WHERE id = $1 FOR UPDATE`, listID)
+		if err != nil {
+			return nil, err
+		}
+		added := &BooksInList{ReadingListID: listID, BookID: result.BookID, Status: status}
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO readinglist_books (readinglist_id, book_id, status, position, added_at, completed_at)
+			VALUES ($1, $2, $3,
+				(SELECT COALESCE(MAX(position) + 1, 0) FROM readinglist_books WHERE readinglist_id = $1),
+				COALESCE($4, NOW()),
+				CASE WHEN $3 = 'completed' THEN COALESCE($5, $4, NOW()) END)
+			ON CONFLICT (readinglist_id, book_id) DO NOTHING
+			RETURNING position, version`,
+			listID, result.BookID, status, entry.DateAdded, entry.DateRead).Scan(&added.Position, &added.Version)
+		switch {
+		case err == nil:
+			result.Entries = append(result.Entries, added)
+		case !errors.Is(err, sql.ErrNoRows): // no row when the book is on the list already
+			return nil, err
+		}
+	}
+
+	// Goodreads uses 0 for "not rated"; a book the user already reviewed here
+	// keeps that review
+	if entry.MyRating >= 1 && entry.MyRating <= 5 {
+		review := &Review{BookID: result.BookID, UserID: userID, Rating: int64(entry.MyRating), ReviewText: entry.Review}
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO bookreviews (book_id, user_id, rating, review, review_date)
+			SELECT $1, $2, $3, $4, COALESCE($5, $6, NOW())
+			WHERE NOT EXISTS (SELECT 1 FROM bookreviews WHERE book_id = $1 AND user_id = $2 AND deleted_at IS NULL)
+			RETURNING id, review_date, version`,
+			review.BookID, review.UserID, review.Rating, review.ReviewText, entry.DateRead, entry.DateAdded).Scan(
+			&review.ReviewID, &review.ReviewDate, &review.Version)
+		switch {
+		case err == nil:
+			result.Review = review
+		case !errors.Is(err, sql.ErrNoRows):
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
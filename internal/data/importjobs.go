@@ -0,0 +1,154 @@
+// Filename: internal/data/importjobs.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Import job statuses.
+const (
+	ImportJobQueued    = "queued"
+	ImportJobRunning   = "running"
+	ImportJobCompleted = "completed"
+	ImportJobFailed    = "failed"
+)
+
+// maxImportJobErrors caps how many row errors are kept for a job so a file
+// full of bad rows does not grow the row without bound.
+const maxImportJobErrors = 100
+
+// ImportJobError explains why a row of an import was skipped.
+type ImportJobError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+// ImportJob is an import that runs in the background. Its counters are
+// updated as it goes so clients can poll it for progress.
+type ImportJob struct {
+	ID            int64            `json:"id"`
+	UserID        int64            `json:"user_id"`
+	Source        string           `json:"source"`
+	Status        string           `json:"status"`
+	TotalRows     int              `json:"total_rows"`
+	ProcessedRows int              `json:"processed_rows"`
+	BooksMatched  int              `json:"books_matched"`
+	BooksCreated  int              `json:"books_created"`
+	ListEntries   int              `json:"list_entries"`
+	Reviews       int              `json:"reviews"`
+	Errors        []ImportJobError `json:"errors"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+	FinishedAt    *time.Time       `json:"finished_at,omitempty"`
+	Version       int32            `json:"version"`
+}
+
+// AddError records a skipped row, keeping only the first few.
+func (j *ImportJob) AddError(row int, message string) {
+	if len(j.Errors) < maxImportJobErrors {
+		j.Errors = append(j.Errors, ImportJobError{Row: row, Message: message})
+	}
+}
+
+type ImportJobModel struct {
+	DB      DBTX
+	Timeout time.Duration // how long a single query may run
+}
+
+func (m ImportJobModel) Insert(ctx context.Context, job *ImportJob) error {
+	query := `
+		INSERT INTO import_jobs (user_id, source, status, total_rows)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at, version
+	`
+	if job.Status == "" {
+		job.Status = ImportJobQueued
+	}
+	if job.Errors == nil {
+		job.Errors = []ImportJobError{}
+	}
+	args := []any{job.UserID, job.Source, job.Status, job.TotalRows}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt, &job.Version)
+}
+
+func (m ImportJobModel) Get(ctx context.Context, id int64) (*ImportJob, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+		SELECT id, user_id, source, status, total_rows, processed_rows, books_matched, books_created,
+		       list_entries, reviews, errors, created_at, updated_at, finished_at, version
+		FROM import_jobs
+		WHERE id = $1
+	`
+	var job ImportJob
+	var errorsJSON []byte
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&job.ID,
+		&job.UserID,
+		&job.Source,
+		&job.Status,
+		&job.TotalRows,
+		&job.ProcessedRows,
+		&job.BooksMatched,
+		&job.BooksCreated,
+		&job.ListEntries,
+		&job.Reviews,
+		&errorsJSON,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&job.FinishedAt,
+		&job.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = json.Unmarshal(errorsJSON, &job.Errors)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// UpdateProgress saves the status, counters and errors of a running job.
+// Only the background worker writes to a job, so there is no version check.
+func (m ImportJobModel) UpdateProgress(ctx context.Context, job *ImportJob) error {
+	errorsJSON, err := json.Marshal(job.Errors)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE import_jobs
+		SET status = $1, processed_rows = $2, books_matched = $3, books_created = $4,
+		    list_entries = $5, reviews = $6, errors = $7, finished_at = $8,
+		    updated_at = NOW(), version = version + 1
+		WHERE id = $9
+		RETURNING updated_at, version
+	`
+	args := []any{job.Status, job.ProcessedRows, job.BooksMatched, job.BooksCreated,
+		job.ListEntries, job.Reviews, errorsJSON, job.FinishedAt, job.ID}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&job.UpdatedAt, &job.Version)
+}
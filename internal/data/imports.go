@@ -0,0 +1,207 @@
+// Filename: internal/data/imports.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Import row statuses reported back to the client.
+const (
+	ImportCreated   = "created"
+	ImportDuplicate = "skipped_duplicate"
+	ImportInvalid   = "invalid"
+	ImportFailed    = "failed"
+)
+
+// ImportRowResult is the outcome of importing a single row of an upload.
+type ImportRowResult struct {
+	Row    int               `json:"row"`
+	Status string            `json:"status"`
+	BookID int64             `json:"book_id,omitempty"`
+	ISBN   string            `json:"isbn,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// ImportReport sums up a bulk import.
+type ImportReport struct {
+	Mode       string             `json:"mode"`
+	Created    int                `json:"created"`
+	Duplicates int                `json:"skipped_duplicates"`
+	Invalid    int                `json:"invalid"`
+	Failed     int                `json:"failed"`
+	Rows       []*ImportRowResult `json:"rows"`
+}
+
+// Add records a row result and updates the totals.
+func (r *ImportReport) Add(result *ImportRowResult) {
+	switch result.Status {
+	case ImportCreated:
+		r.Created++
+	case ImportDuplicate:
+		r.Duplicates++
+	case ImportInvalid:
+		r.Invalid++
+	case ImportFailed:
+		r.Failed++
+	}
+	r.Rows = append(r.Rows, result)
+}
+
+// NormalizeISBN strips the hyphens and spaces people like to put in ISBNs.
+func NormalizeISBN(isbn string) string {
+	var b strings.Builder
+	for _, r := range isbn {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == 'x' || r == 'X':
+			b.WriteRune('X')
+		}
+	}
+	return b.String()
+}
+
+// BookImport inserts books in batches. In atomic mode every batch shares one
+// transaction that is only committed by Commit; otherwise each batch is
+// committed on its own so a failing batch does not undo the earlier ones.
+type BookImport struct {
+	db      DBTX
+	tx      txn
+	atomic  bool
+	timeout time.Duration
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewImport starts a bulk import. Imports are allowed to run for a few
+// minutes instead of the usual query timeout.
+func (c BookModel) NewImport(ctx context.Context, atomic bool) (BookImporter, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	imp := &BookImport{db: c.DB, atomic: atomic, timeout: c.Timeout, ctx: ctx, cancel: cancel}
+
+	if atomic {
+		tx, err := beginTx(ctx, c.DB)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		imp.tx = tx
+	}
+	return imp, nil
+}
+
+// ExistingISBNs returns which of the (normalized) ISBNs are already in the catalog.
+func (i *BookImport) ExistingISBNs(isbns []string) (map[string]bool, error) {
+	query := `
+		SELECT UPPER(REGEXP_REPLACE(isbn, '[^0-9Xx]', '', 'g'))
+		FROM books
+		WHERE UPPER(REGEXP_REPLACE(isbn, '[^0-9Xx]', '', 'g')) = ANY($1) AND deleted_at IS NULL
+	`
+	var rows *sql.Rows
+	var err error
+	if i.tx != nil {
+		rows, err = i.tx.QueryContext(i.ctx, query, pq.Array(isbns))
+	} else {
+		rows, err = i.db.QueryContext(i.ctx, query, pq.Array(isbns))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var isbn string
+		err := rows.Scan(&isbn)
+		if err != nil {
+			return nil, err
+		}
+		existing[isbn] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return existing, nil
+}
+
+// InsertBatch inserts the books and fills in their ids. Like Insert, books
+// without a WorkID start a new work.
+func (i *BookImport) InsertBatch(books []*Book, audit func(*Book) (*AuditEvent, error)) error {
+	tx := i.tx
+	if !i.atomic {
+		var err error
+		tx, err = beginTx(i.ctx, i.db)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	stmt, err := tx.PrepareContext(i.ctx, `
+		WITH new_work AS (
+			INSERT INTO works (title, authors, description)
+			SELECT $1, $2, $6
+			WHERE $7 = 0
+			RETURNING id
+		)
+		INSERT INTO books (title, authors, isbn, publication_date, genre, description,
+		                   work_id, format, publisher, language, page_count)
+		VALUES ($1, $2, $3, $4, $5, $6,
+		        COALESCE((SELECT id FROM new_work), NULLIF($7, 0)), $8, $9, $10, $11)
+		RETURNING id, work_id, version
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, book := range books {
+		args := []any{book.Title, book.Authors, book.ISBN, book.PublicationDate, book.Genre, book.Description,
+			book.WorkID, book.Format, book.Publisher, book.Language, book.PageCount}
+		err = stmt.QueryRowContext(i.ctx, args...).Scan(&book.ID, &book.WorkID, &book.Version)
+		if err != nil {
+			return err
+		}
+
+		if audit != nil {
+			event, err := audit(book)
+			if err != nil {
+				return err
+			}
+			err = AuditModel{DB: tx, Timeout: i.timeout}.Insert(i.ctx, event)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !i.atomic {
+		return tx.Commit()
+	}
+	return nil
+}
+
+// Commit finishes the import. For atomic imports this is when the books
+// become visible.
+func (i *BookImport) Commit() error {
+	defer i.cancel()
+	if i.tx != nil {
+		return i.tx.Commit()
+	}
+	return nil
+}
+
+// Rollback abandons an atomic import. It is safe to call after Commit.
+func (i *BookImport) Rollback() {
+	defer i.cancel()
+	if i.tx != nil {
+		i.tx.Rollback()
+	}
+}
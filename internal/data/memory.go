@@ -0,0 +1,227 @@
+// Filename: internal/data/memory.go
+package data
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// MemoryStore keeps every table in maps guarded by a single mutex. It backs
+// the in-memory repositories that are used with -storage=memory and in
+// tests, and behaves like the PostgreSQL models as far as the handlers can
+// tell: ids, versions, pagination, search and edit conflicts all work the
+// same way. Nothing survives a restart. The repositories take a context
+// like the PostgreSQL ones but never wait on anything, so they ignore it.
+type MemoryStore struct {
+	mu sync.Mutex
+	memoryTables
+}
+
+// memoryTables is everything a MemoryStore holds.
+type memoryTables struct {
+	ids map[string]int64
+
+	books         map[int64]*Book
+	works         map[int64]*Work
+	redirects     map[int64]int64
+	users         map[int64]*User
+	permissions   map[int64]Permissions
+	tokens        []*Token
+	reviews       map[int64]*Review
+	lists         map[int64]*ReadingList
+	listBooks     map[int64][]*memoryListEntry // ordered by position
+	collaborators map[int64][]*Collaborator
+	shares        map[int64]*ShareLink
+	tags          []*Tag
+	series        map[int64]*Series
+	seriesBooks   []*SeriesBook
+	progress      map[int64]*ReadingProgress
+	goals         map[memoryGoalKey]*ReadingGoal
+	importJobs    map[int64]*ImportJob
+	exports       map[string]*memoryExport
+	changes       map[int64]*ChangeRequest
+	similarities  map[int64][]*memorySimilarity
+	auditEvents   []*AuditEvent // in the order they were added
+
+	// Deleted rows wait here, with DeletedAt set, until they are restored or
+	// purged. Keeping them out of the tables above hides them everywhere else.
+	trashedBooks   map[int64]*Book
+	trashedLists   map[int64]*ReadingList
+	trashedReviews map[int64]*Review
+}
+
+// memoryListEntry is a row of readinglist_books.
+type memoryListEntry struct {
+	BooksInList
+	AddedAt     time.Time
+	CompletedAt *time.Time
+}
+
+type memoryGoalKey struct {
+	userID int64
+	year   int
+}
+
+type memoryExport struct {
+	ExportArchive
+	Expiry time.Time
+}
+
+type memorySimilarity struct {
+	SimilarBookID int64
+	Score         float64
+	SharedReaders int
+}
+
+// NewMemoryStore returns an empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{memoryTables: memoryTables{
+		ids:           make(map[string]int64),
+		books:         make(map[int64]*Book),
+		works:         make(map[int64]*Work),
+		redirects:     make(map[int64]int64),
+		users:         make(map[int64]*User),
+		permissions:   make(map[int64]Permissions),
+		reviews:       make(map[int64]*Review),
+		lists:         make(map[int64]*ReadingList),
+		listBooks:     make(map[int64][]*memoryListEntry),
+		collaborators: make(map[int64][]*Collaborator),
+		shares:        make(map[int64]*ShareLink),
+		series:        make(map[int64]*Series),
+		progress:      make(map[int64]*ReadingProgress),
+		goals:         make(map[memoryGoalKey]*ReadingGoal),
+		importJobs:    make(map[int64]*ImportJob),
+		exports:       make(map[string]*memoryExport),
+		changes:       make(map[int64]*ChangeRequest),
+		similarities:  make(map[int64][]*memorySimilarity),
+
+		trashedBooks:   make(map[int64]*Book),
+		trashedLists:   make(map[int64]*ReadingList),
+		trashedReviews: make(map[int64]*Review),
+	}}
+}
+
+// NewMemoryModels returns repositories that share a new, empty MemoryStore.
+func NewMemoryModels() Models {
+	return NewMemoryStore().Models()
+}
+
+// Models returns the repositories backed by the store.
+func (s *MemoryStore) Models() Models {
+	return Models{
+		Books:           memoryBooks{s},
+		Works:           memoryWorks{s},
+		ReadingLists:    memoryReadingLists{s},
+		Reviews:         memoryReviews{s},
+		Users:           memoryUsers{s},
+		Tokens:          memoryTokens{s},
+		Permissions:     memoryPermissions{s},
+		Stats:           memoryStats{s},
+		Tags:            memoryTags{s},
+		Shares:          memoryShares{s},
+		Series:          memorySeries{s},
+		ImportJobs:      memoryImportJobs{s},
+		Exports:         memoryExports{s},
+		ChangeRequests:  memoryChangeRequests{s},
+		Recommendations: memoryRecommendations{s},
+		Trash:           memoryTrash{s},
+		Audit:           memoryAudit{s},
+		Transactor:      memoryTransactor{s},
+	}
+}
+
+// nextID plays the part of a bigserial sequence. The caller holds the lock.
+func (s *MemoryStore) nextID(table string) int64 {
+	s.ids[table]++
+	return s.ids[table]
+}
+
+// sortedIDs returns the keys of a table in ascending order.
+func sortedIDs[T any](table map[int64]T) []int64 {
+	ids := make([]int64, 0, len(table))
+	for id := range table {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// memoryPage sorts the rows the way the filters ask for, ties broken by id
+// like the ORDER BY of the SQL queries, and cuts out the requested page.
+// column returns the value of a sort column for a row.
+func memoryPage[T any](rows []T, filters Filters, column func(T, string) any, id func(T) int64) ([]T, Metadata) {
+	name := filters.sortColumn()
+	descending := filters.sortDirection() == "DESC"
+
+	slices.SortStableFunc(rows, func(a, b T) int {
+		c := compareValues(column(a, name), column(b, name))
+		if descending {
+			c = -c
+		}
+		if c == 0 {
+			c = cmp.Compare(id(a), id(b))
+		}
+		return c
+	})
+
+	return memoryPageOf(rows, filters)
+}
+
+// memoryPageOf cuts the requested page out of rows that are already sorted.
+func memoryPageOf[T any](rows []T, filters Filters) ([]T, Metadata) {
+	total := len(rows)
+	start := min(filters.offset(), total)
+	end := min(start+filters.limit(), total)
+	return rows[start:end], calculateMetaData(total, filters.Page, filters.PageSize)
+}
+
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case int64:
+		return cmp.Compare(a, b.(int64))
+	case int:
+		return cmp.Compare(a, b.(int))
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case string:
+		return cmp.Compare(a, b.(string))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	panic(fmt.Sprintf("cannot sort by a %T", a))
+}
+
+// searchWords splits text into lowercase words the way the 'simple' text
+// search configuration does.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// matchesWords is the in-memory version of
+// to_tsvector('simple', text) @@ plainto_tsquery('simple', query): every
+// word of the query has to be a word of the text. An empty query matches
+// everything, as the queries check for $1 = ”.
+func matchesWords(text, query string) bool {
+	if query == "" {
+		return true
+	}
+	words := searchWords(text)
+	for _, word := range searchWords(query) {
+		if !slices.Contains(words, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// roundRating rounds to the two decimals of the DECIMAL(3, 2) rating columns.
+func roundRating(rating float64) float64 {
+	return float64(int64(rating*100+0.5)) / 100
+}
@@ -0,0 +1,51 @@
+// Filename: internal/data/memory_audit.go
+package data
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+type memoryAudit struct {
+	s *MemoryStore
+}
+
+func copyAuditEvent(event *AuditEvent) *AuditEvent {
+	c := *event
+	c.Changes = slices.Clone(event.Changes)
+	return &c
+}
+
+func (m memoryAudit) Insert(ctx context.Context, event *AuditEvent) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	m.s.insertAuditEvent(event)
+	return nil
+}
+
+// insertAuditEvent adds an event to the log. The caller holds the lock.
+func (s *MemoryStore) insertAuditEvent(event *AuditEvent) {
+	event.ID = s.nextID("audit_events")
+	event.CreatedAt = time.Now()
+	if event.Changes == nil {
+		event.Changes = []AuditFieldChange{}
+	}
+	s.auditEvents = append(s.auditEvents, copyAuditEvent(event))
+}
+
+func (m memoryAudit) GetAll(ctx context.Context, resource string, resourceID int64, filters Filters) ([]*AuditEvent, Metadata, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	// the events are kept in the order they were added, most recent last
+	events := []*AuditEvent{}
+	for _, event := range slices.Backward(m.s.auditEvents) {
+		if (resource == "" || event.Resource == resource) && (resourceID == 0 || event.ResourceID == resourceID) {
+			events = append(events, copyAuditEvent(event))
+		}
+	}
+	events, metadata := memoryPageOf(events, filters)
+	return events, metadata, nil
+}
@@ -0,0 +1,682 @@
+// Filename: internal/data/memory_books.go
+package data
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+type memoryBooks struct {
+	s *MemoryStore
+}
+
+// insertBook stores a new book, starting a new work for it when it has no
+// WorkID. The caller holds the lock.
+func (s *MemoryStore) insertBook(book *Book) error {
+	if book.WorkID == 0 {
+		work := &Work{ID: s.nextID("works"), Title: book.Title, Authors: book.Authors, Description: book.Description, Version: 1}
+		s.works[work.ID] = work
+		book.WorkID = work.ID
+	} else if _, ok := s.works[book.WorkID]; !ok {
+		return fmt.Errorf("work %d does not exist", book.WorkID)
+	}
+
+	book.ID = s.nextID("books")
+	book.Version = 1
+	book.AverageRating = 0
+	book.RatingCount = 0
+	book.RatingDistribution = RatingDistribution{}
+	book.Cover = ""
+	book.Series = nil
+
+	stored := *book
+	s.books[book.ID] = &stored
+	// a book without ratings starts at the mean
+	mean, _ := s.ratingTotals()
+	stored.WeightedRating = bayesianRating(0, 0, mean)
+	book.WeightedRating = stored.WeightedRating
+	return nil
+}
+
+// getBook returns a copy of a book. The caller holds the lock.
+func (s *MemoryStore) getBook(id int64) (*Book, bool) {
+	book, ok := s.books[id]
+	if !ok {
+		return nil, false
+	}
+	c := *book
+	c.Series = nil
+	return &c, true
+}
+
+// deleteBook removes a book and everything that references it, like the
+// ON DELETE CASCADE foreign keys do. The caller holds the lock.
+func (s *MemoryStore) deleteBook(id int64) {
+	delete(s.books, id)
+	delete(s.trashedBooks, id)
+
+	for reviewID, review := range s.reviews {
+		if review.BookID == id {
+			delete(s.reviews, reviewID)
+		}
+	}
+	for reviewID, review := range s.trashedReviews {
+		if review.BookID == id {
+			delete(s.trashedReviews, reviewID)
+		}
+	}
+	for listID, entries := range s.listBooks {
+		s.listBooks[listID] = slices.DeleteFunc(entries, func(e *memoryListEntry) bool { return e.BookID == id })
+	}
+	for progressID, progress := range s.progress {
+		if progress.BookID == id {
+			delete(s.progress, progressID)
+		}
+	}
+	s.tags = slices.DeleteFunc(s.tags, func(t *Tag) bool { return t.BookID == id })
+	s.seriesBooks = slices.DeleteFunc(s.seriesBooks, func(sb *SeriesBook) bool { return sb.BookID == id })
+	for oldID, newID := range s.redirects {
+		if newID == id {
+			delete(s.redirects, oldID)
+		}
+	}
+	for changeID, change := range s.changes {
+		if change.BookID == id {
+			delete(s.changes, changeID)
+		}
+	}
+	delete(s.similarities, id)
+	for bookID, similar := range s.similarities {
+		s.similarities[bookID] = slices.DeleteFunc(similar, func(sim *memorySimilarity) bool { return sim.SimilarBookID == id })
+	}
+}
+
+// refreshRatings recomputes what the rating triggers keep up to date: the
+// statistics of the book, its weighted rating at the current mean and the
+// average rating of its work. The caller holds the lock.
+func (s *MemoryStore) refreshRatings(bookID int64) {
+	book, ok := s.books[bookID]
+	if !ok {
+		return
+	}
+
+	var count int
+	var sum float64
+	var distribution RatingDistribution
+	for _, review := range s.reviews {
+		if review.BookID == bookID {
+			count++
+			sum += float64(review.Rating)
+			distribution[min(max(review.Rating, 1), 5)-1]++
+		}
+	}
+
+	book.RatingCount = count
+	book.RatingDistribution = distribution
+	book.AverageRating = 0
+	if count > 0 {
+		book.AverageRating = float32(roundRating(sum / float64(count)))
+	}
+	mean, _ := s.ratingTotals()
+	book.WeightedRating = bayesianRating(sum, count, mean)
+
+	if book.WorkID != 0 {
+		s.refreshWorkRating(book.WorkID)
+	}
+}
+
+// refreshWeightedRatings is refresh_weighted_ratings: it brings the weighted
+// rating of every book back to the current mean, and returns how many books
+// changed. The caller holds the lock.
+func (s *MemoryStore) refreshWeightedRatings() int64 {
+	mean, sums := s.ratingTotals()
+	var refreshed int64
+	for _, books := range []map[int64]*Book{s.books, s.trashedBooks} {
+		for _, book := range books {
+			weighted := bayesianRating(sums[book.ID], book.RatingCount, mean)
+			if weighted != book.WeightedRating {
+				book.WeightedRating = weighted
+				refreshed++
+			}
+		}
+	}
+	return refreshed
+}
+
+// ratingTotals returns the mean over all ratings, which the rating_totals
+// row keeps, along with the sum of each book's ratings. The caller holds the
+// lock.
+func (s *MemoryStore) ratingTotals() (float64, map[int64]float64) {
+	sums := make(map[int64]float64)
+	var sum float64
+	for _, review := range s.reviews {
+		sums[review.BookID] += float64(review.Rating)
+		sum += float64(review.Rating)
+	}
+	if len(s.reviews) == 0 {
+		return 0, sums
+	}
+	return sum / float64(len(s.reviews)), sums
+}
+
+// bayesianRating is the SQL function: the book's own ratings plus 5
+// imaginary ratings at the mean.
+func bayesianRating(sum float64, count int, mean float64) float64 {
+	if mean == 0 {
+		return 0
+	}
+	return (5*mean + sum) / float64(5+count)
+}
+
+// refreshWorkRating recomputes the average rating over the reviews of every
+// edition of a work. The caller holds the lock.
+func (s *MemoryStore) refreshWorkRating(workID int64) {
+	work, ok := s.works[workID]
+	if !ok {
+		return
+	}
+	var count int
+	var sum float64
+	for _, review := range s.reviews {
+		if book, ok := s.books[review.BookID]; ok && book.WorkID == workID {
+			count++
+			sum += float64(review.Rating)
+		}
+	}
+	work.AverageRating = 0
+	if count > 0 {
+		work.AverageRating = float32(roundRating(sum / float64(count)))
+	}
+}
+
+func (m memoryBooks) Insert(ctx context.Context, book *Book) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.s.insertBook(book)
+}
+
+func (m memoryBooks) Get(ctx context.Context, id int64) (*Book, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	book, ok := m.s.getBook(id)
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	book.Series = m.s.seriesForBook(id)
+	return book, nil
+}
+
+func (m memoryBooks) Update(ctx context.Context, book *Book) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.books[book.ID]
+	if !ok || stored.Version != book.Version {
+		return ErrEditConflict
+	}
+	if book.WorkID != 0 && book.WorkID != stored.WorkID {
+		if _, ok := m.s.works[book.WorkID]; !ok {
+			return fmt.Errorf("work %d does not exist", book.WorkID)
+		}
+		// the edition takes its reviews along to the other work, like the
+		// update_moved_edition_work_rating trigger
+		left := stored.WorkID
+		stored.WorkID = book.WorkID
+		m.s.refreshWorkRating(left)
+		m.s.refreshWorkRating(stored.WorkID)
+	}
+	stored.Title = book.Title
+	stored.Authors = book.Authors
+	stored.ISBN = book.ISBN
+	stored.PublicationDate = book.PublicationDate
+	stored.Genre = book.Genre
+	stored.Description = book.Description
+	stored.Format = book.Format
+	stored.Publisher = book.Publisher
+	stored.Language = book.Language
+	stored.PageCount = book.PageCount
+	stored.Version++
+
+	book.Version = stored.Version
+	return nil
+}
+
+func (m memoryBooks) Delete(ctx context.Context, id int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	book, ok := m.s.books[id]
+	if !ok {
+		return ErrRecordNotFound
+	}
+	// the book only goes to the trash, Purge removes it for good
+	now := time.Now()
+	book.DeletedAt = &now
+	delete(m.s.books, id)
+	m.s.trashedBooks[id] = book
+	m.s.refreshWorkRating(book.WorkID)
+	return nil
+}
+
+// bookColumn returns the value of a sort column of the books table.
+func bookColumn(book *Book, column string) any {
+	switch column {
+	case "id":
+		return book.ID
+	case "title":
+		return book.Title
+	case "author", "authors":
+		return book.Authors
+	case "genre":
+		return book.Genre
+	case "weighted_rating":
+		return book.WeightedRating
+	}
+	panic("unknown sort column for books: " + column)
+}
+
+func bookID(book *Book) int64 {
+	return book.ID
+}
+
+func (m memoryBooks) GetAll(ctx context.Context, tag string, userID int64, filters Filters) ([]*Book, Metadata, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	books := []*Book{}
+	for _, id := range sortedIDs(m.s.books) {
+		if tag != "" && !slices.ContainsFunc(m.s.tags, func(t *Tag) bool {
+			return t.UserID == userID && t.BookID == id && t.Name == tag
+		}) {
+			continue
+		}
+		book, _ := m.s.getBook(id)
+		books = append(books, book)
+	}
+
+	books, metadata := memoryPage(books, filters, bookColumn, bookID)
+	return books, metadata, nil
+}
+
+func (m memoryBooks) Search(ctx context.Context, title string, author string, genre string, filters Filters) ([]*Book, Metadata, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	books := []*Book{}
+	for _, id := range sortedIDs(m.s.books) {
+		book, _ := m.s.getBook(id)
+		if matchesWords(book.Title, title) && matchesWords(book.Authors, author) && matchesWords(book.Genre, genre) {
+			books = append(books, book)
+		}
+	}
+
+	books, metadata := memoryPage(books, filters, bookColumn, bookID)
+	return books, metadata, nil
+}
+
+func (m memoryBooks) BookExists(ctx context.Context, id int64) (bool, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	_, ok := m.s.books[id]
+	return ok, nil
+}
+
+func (m memoryBooks) SetCover(ctx context.Context, bookID int64, cover CoverImage) (CoverImage, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	book, ok := m.s.books[bookID]
+	if !ok {
+		return "", ErrRecordNotFound
+	}
+	previous := book.Cover
+	book.Cover = cover
+	book.Version++
+	return previous, nil
+}
+
+// trigrams returns the trigrams pg_trgm extracts from text: every word is
+// lowercased and padded with two spaces in front and one behind.
+func trigrams(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range searchWords(text) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+// trigramSimilarity is pg_trgm's similarity(): the share of trigrams the two
+// texts have in common.
+func trigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 && len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// trigramThreshold is the default pg_trgm.similarity_threshold the % operator uses.
+const trigramThreshold = 0.3
+
+func (m memoryBooks) FindDuplicates(ctx context.Context, minSimilarity float64, filters Filters) ([]*DuplicateCandidate, Metadata, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	summary := func(b *Book) BookSummary {
+		return BookSummary{ID: b.ID, Title: b.Title, Authors: b.Authors, ISBN: b.ISBN}
+	}
+
+	ids := sortedIDs(m.s.books)
+	candidates := []*DuplicateCandidate{}
+	for i, aID := range ids {
+		a := m.s.books[aID]
+		for _, bID := range ids[i+1:] {
+			b := m.s.books[bID]
+
+			// a pair found by both rules is only reported once, preferring the ISBN match
+			candidate := &DuplicateCandidate{Book: summary(a), Duplicate: summary(b)}
+			if NormalizeISBN(a.ISBN) == NormalizeISBN(b.ISBN) {
+				candidate.Reason = "isbn"
+				candidate.Similarity = 1
+			} else {
+				similarity := trigramSimilarity(a.Title+" "+a.Authors, b.Title+" "+b.Authors)
+				if similarity < trigramThreshold {
+					continue
+				}
+				candidate.Reason = "similar_title"
+				candidate.Similarity = similarity
+			}
+			if candidate.Similarity >= minSimilarity {
+				candidates = append(candidates, candidate)
+			}
+		}
+	}
+
+	slices.SortStableFunc(candidates, func(x, y *DuplicateCandidate) int {
+		return cmp.Or(
+			cmp.Compare(y.Similarity, x.Similarity),
+			cmp.Compare(x.Book.ID, y.Book.ID),
+			cmp.Compare(x.Duplicate.ID, y.Duplicate.ID),
+		)
+	})
+	candidates, metadata := memoryPageOf(candidates, filters)
+	return candidates, metadata, nil
+}
+
+func (m memoryBooks) Merge(ctx context.Context, canonicalID, duplicateID, mergedBy int64) error {
+	if canonicalID == duplicateID {
+		return errors.New("a book cannot be merged into itself")
+	}
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	canonical, ok := m.s.books[canonicalID]
+	if !ok {
+		return ErrRecordNotFound
+	}
+	duplicate, ok := m.s.books[duplicateID]
+	if !ok {
+		return ErrRecordNotFound
+	}
+	duplicateWork := duplicate.WorkID
+
+	for _, review := range m.s.reviews {
+		if review.BookID == duplicateID {
+			review.BookID = canonicalID
+		}
+	}
+	// reading list entries, unless the list already holds the canonical book;
+	// lists that held both lose the duplicate and are renumbered
+	for listID, entries := range m.s.listBooks {
+		hasCanonical := slices.ContainsFunc(entries, func(e *memoryListEntry) bool { return e.BookID == canonicalID })
+		for _, entry := range entries {
+			if entry.BookID == duplicateID && !hasCanonical {
+				entry.BookID = canonicalID
+			}
+		}
+		kept := slices.DeleteFunc(entries, func(e *memoryListEntry) bool { return e.BookID == duplicateID })
+		for i, entry := range kept {
+			entry.Position = i
+		}
+		m.s.listBooks[listID] = kept
+	}
+	for _, tag := range m.s.tags {
+		if tag.BookID == duplicateID && !slices.ContainsFunc(m.s.tags, func(t *Tag) bool {
+			return t.UserID == tag.UserID && t.BookID == canonicalID && t.Name == tag.Name
+		}) {
+			copied := *tag
+			copied.BookID = canonicalID
+			m.s.tags = append(m.s.tags, &copied)
+		}
+	}
+	for _, place := range m.s.seriesBooks {
+		if place.BookID == duplicateID && !slices.ContainsFunc(m.s.seriesBooks, func(sb *SeriesBook) bool {
+			return sb.SeriesID == place.SeriesID && sb.BookID == canonicalID
+		}) {
+			place.BookID = canonicalID
+		}
+	}
+	for _, progress := range m.s.progress {
+		if progress.BookID == duplicateID {
+			progress.BookID = canonicalID
+		}
+	}
+	for oldID, newID := range m.s.redirects {
+		if newID == duplicateID {
+			m.s.redirects[oldID] = canonicalID
+		}
+	}
+
+	m.s.deleteBook(duplicateID)
+	m.s.redirects[duplicateID] = canonicalID
+
+	// recompute the rating now that the reviews of both books are together
+	m.s.refreshRatings(canonicalID)
+	canonical.Version++
+
+	// the duplicate's work loses an edition, drop it if it has none left
+	if duplicateWork != 0 {
+		inUse := false
+		for _, book := range m.s.books {
+			if book.WorkID == duplicateWork {
+				inUse = true
+				break
+			}
+		}
+		if !inUse {
+			delete(m.s.works, duplicateWork)
+		} else {
+			m.s.refreshWorkRating(duplicateWork)
+		}
+	}
+	return nil
+}
+
+func (m memoryBooks) GetRedirect(ctx context.Context, oldID int64) (int64, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	newID, ok := m.s.redirects[oldID]
+	if !ok {
+		return 0, ErrRecordNotFound
+	}
+	return newID, nil
+}
+
+func (m memoryBooks) RefreshWeightedRatings(ctx context.Context) (int64, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.s.refreshWeightedRatings(), nil
+}
+
+func (m memoryBooks) NewImport(ctx context.Context, atomic bool) (BookImporter, error) {
+	return &memoryImport{s: m.s, atomic: atomic}, nil
+}
+
+// memoryImport adds the books of an atomic import to the store only when it
+// is committed, until then they are only visible to the import itself.
+type memoryImport struct {
+	s       *MemoryStore
+	atomic  bool
+	pending []*Book
+	works   []*Work
+	events  []*AuditEvent
+}
+
+func (i *memoryImport) ExistingISBNs(isbns []string) (map[string]bool, error) {
+	i.s.mu.Lock()
+	defer i.s.mu.Unlock()
+
+	existing := make(map[string]bool)
+	check := func(book *Book) {
+		isbn := NormalizeISBN(book.ISBN)
+		if slices.Contains(isbns, isbn) {
+			existing[isbn] = true
+		}
+	}
+	for _, book := range i.s.books {
+		check(book)
+	}
+	for _, book := range i.pending {
+		check(book)
+	}
+	return existing, nil
+}
+
+func (i *memoryImport) InsertBatch(books []*Book, audit func(*Book) (*AuditEvent, error)) error {
+	i.s.mu.Lock()
+	defer i.s.mu.Unlock()
+
+	if !i.atomic {
+		// a failing batch leaves nothing behind
+		for _, book := range books {
+			if _, ok := i.s.works[book.WorkID]; book.WorkID != 0 && !ok {
+				return fmt.Errorf("work %d does not exist", book.WorkID)
+			}
+		}
+		var events []*AuditEvent
+		for _, book := range books {
+			err := i.s.insertBook(book)
+			if err != nil {
+				return err
+			}
+			if audit != nil {
+				event, err := audit(book)
+				if err != nil {
+					return err
+				}
+				events = append(events, event)
+			}
+		}
+		for _, event := range events {
+			i.s.insertAuditEvent(event)
+		}
+		return nil
+	}
+
+	for _, book := range books {
+		if book.WorkID == 0 {
+			work := &Work{ID: i.s.nextID("works"), Title: book.Title, Authors: book.Authors, Description: book.Description, Version: 1}
+			i.works = append(i.works, work)
+			book.WorkID = work.ID
+		} else if _, ok := i.s.works[book.WorkID]; !ok && !slices.ContainsFunc(i.works, func(w *Work) bool {
+			return w.ID == book.WorkID
+		}) {
+			return fmt.Errorf("work %d does not exist", book.WorkID)
+		}
+		book.ID = i.s.nextID("books")
+		book.Version = 1
+		stored := *book
+		i.pending = append(i.pending, &stored)
+		if audit != nil {
+			event, err := audit(book)
+			if err != nil {
+				return err
+			}
+			i.events = append(i.events, event)
+		}
+	}
+	return nil
+}
+
+func (i *memoryImport) Commit() error {
+	i.s.mu.Lock()
+	defer i.s.mu.Unlock()
+
+	for _, work := range i.works {
+		i.s.works[work.ID] = work
+	}
+	for _, book := range i.pending {
+		i.s.books[book.ID] = book
+	}
+	for _, event := range i.events {
+		i.s.insertAuditEvent(event)
+	}
+	i.works, i.pending, i.events = nil, nil, nil
+	return nil
+}
+
+func (i *memoryImport) Rollback() {
+	i.s.mu.Lock()
+	defer i.s.mu.Unlock()
+
+	i.works, i.pending, i.events = nil, nil, nil
+}
+
+type memoryWorks struct {
+	s *MemoryStore
+}
+
+func (m memoryWorks) Get(ctx context.Context, id int64) (*Work, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.works[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	work := *stored
+	work.Editions = []*Book{}
+	for _, bookID := range sortedIDs(m.s.books) {
+		book, _ := m.s.getBook(bookID)
+		if book.WorkID == id {
+			work.Editions = append(work.Editions, book)
+		}
+	}
+	slices.SortStableFunc(work.Editions, func(a, b *Book) int {
+		return cmp.Or(strings.Compare(a.PublicationDate, b.PublicationDate), cmp.Compare(a.ID, b.ID))
+	})
+	for _, review := range m.s.reviews {
+		if book, ok := m.s.books[review.BookID]; ok && book.WorkID == id {
+			work.ReviewCount++
+		}
+	}
+	return &work, nil
+}
+
+func (m memoryWorks) Exists(ctx context.Context, id int64) (bool, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	_, ok := m.s.works[id]
+	return ok, nil
+}
@@ -0,0 +1,254 @@
+// Filename: internal/data/memory_changes.go
+package data
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+type memoryChangeRequests struct {
+	s *MemoryStore
+}
+
+func copyChangeRequest(cr *ChangeRequest) *ChangeRequest {
+	c := *cr
+	c.Changes = slices.Clone(cr.Changes)
+	return &c
+}
+
+func (m memoryChangeRequests) Insert(ctx context.Context, cr *ChangeRequest) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.books[cr.BookID]; !ok {
+		return fmt.Errorf("book %d does not exist", cr.BookID)
+	}
+
+	cr.ID = m.s.nextID("book_change_requests")
+	cr.Status = ChangePending
+	cr.CreatedAt = time.Now()
+	cr.Version = 1
+	m.s.changes[cr.ID] = copyChangeRequest(cr)
+	return nil
+}
+
+func (m memoryChangeRequests) Get(ctx context.Context, id int64) (*ChangeRequest, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	cr, ok := m.s.changes[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return copyChangeRequest(cr), nil
+}
+
+func (m memoryChangeRequests) GetAll(ctx context.Context, status string, bookID int64, filters Filters) ([]*ChangeRequest, Metadata, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	requests := []*ChangeRequest{}
+	for _, id := range sortedIDs(m.s.changes) {
+		cr := m.s.changes[id]
+		if (status == "" || cr.Status == status) && (bookID == 0 || cr.BookID == bookID) {
+			requests = append(requests, copyChangeRequest(cr))
+		}
+	}
+
+	// oldest first so moderators work through the queue in order
+	slices.SortStableFunc(requests, func(a, b *ChangeRequest) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	requests, metadata := memoryPageOf(requests, filters)
+	return requests, metadata, nil
+}
+
+func (m memoryChangeRequests) Resolve(ctx context.Context, cr *ChangeRequest) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.changes[cr.ID]
+	if !ok || stored.Version != cr.Version || stored.Status != ChangePending {
+		return ErrEditConflict
+	}
+
+	now := time.Now()
+	stored.Status = cr.Status
+	stored.Reason = cr.Reason
+	if cr.ReviewedBy != nil {
+		reviewedBy := *cr.ReviewedBy
+		stored.ReviewedBy = &reviewedBy
+	}
+	stored.ReviewedAt = &now
+	stored.Version++
+
+	cr.ReviewedAt = &now
+	cr.Version = stored.Version
+	return nil
+}
+
+type memoryRecommendations struct {
+	s *MemoryStore
+}
+
+// interactions is the in-memory version of bookInteractions: for every user
+// and book, the average rating they gave it out of 5, or 0.6 if the book is
+// only on one of their lists. The caller holds the lock.
+func (s *MemoryStore) interactions() map[int64]map[int64]float64 {
+	type signal struct {
+		sum   float64
+		count int
+	}
+	signals := make(map[int64]map[int64]*signal)
+	add := func(userID, bookID int64) *signal {
+		if signals[userID] == nil {
+			signals[userID] = make(map[int64]*signal)
+		}
+		if signals[userID][bookID] == nil {
+			signals[userID][bookID] = &signal{}
+		}
+		return signals[userID][bookID]
+	}
+
+	for listID, entries := range s.listBooks {
+		list, ok := s.lists[listID]
+		if !ok || list.CreatedBy == 0 {
+			continue
+		}
+		for _, entry := range entries {
+			add(int64(list.CreatedBy), entry.BookID)
+		}
+	}
+	for _, review := range s.reviews {
+		sig := add(review.UserID, review.BookID)
+		sig.sum += float64(review.Rating)
+		sig.count++
+	}
+
+	weights := make(map[int64]map[int64]float64, len(signals))
+	for userID, books := range signals {
+		weights[userID] = make(map[int64]float64, len(books))
+		for bookID, sig := range books {
+			weight := 0.6
+			if sig.count > 0 {
+				weight = sig.sum / float64(sig.count) / 5
+			}
+			weights[userID][bookID] = weight
+		}
+	}
+	return weights
+}
+
+func (m memoryRecommendations) RefreshSimilarities(ctx context.Context) (int64, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	interactions := m.s.interactions()
+
+	type pair struct {
+		dot    float64
+		shared int
+	}
+	norms := make(map[int64]float64)
+	pairs := make(map[int64]map[int64]*pair)
+	for _, books := range interactions {
+		for a, wa := range books {
+			norms[a] += wa * wa
+			for b, wb := range books {
+				if a == b {
+					continue
+				}
+				if pairs[a] == nil {
+					pairs[a] = make(map[int64]*pair)
+				}
+				if pairs[a][b] == nil {
+					pairs[a][b] = &pair{}
+				}
+				pairs[a][b].dot += wa * wb
+				pairs[a][b].shared++
+			}
+		}
+	}
+
+	similarities := make(map[int64][]*memorySimilarity)
+	var count int64
+	for a, similar := range pairs {
+		var ranked []*memorySimilarity
+		for b, p := range similar {
+			if p.shared < MinSharedReaders {
+				continue
+			}
+			ranked = append(ranked, &memorySimilarity{
+				SimilarBookID: b,
+				Score:         p.dot / (math.Sqrt(norms[a]) * math.Sqrt(norms[b])),
+				SharedReaders: p.shared,
+			})
+		}
+		slices.SortFunc(ranked, func(x, y *memorySimilarity) int {
+			return cmp.Or(cmp.Compare(y.Score, x.Score), cmp.Compare(x.SimilarBookID, y.SimilarBookID))
+		})
+		ranked = ranked[:min(len(ranked), similarBooksKept)]
+		if len(ranked) > 0 {
+			similarities[a] = ranked
+			count += int64(len(ranked))
+		}
+	}
+
+	m.s.similarities = similarities
+	return count, nil
+}
+
+func (m memoryRecommendations) Similar(ctx context.Context, bookID int64, limit int) ([]*RecommendedBook, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	books := []*RecommendedBook{}
+	for _, similarity := range m.s.similarities[bookID] {
+		if len(books) == limit {
+			break
+		}
+		book, ok := m.s.getBook(similarity.SimilarBookID)
+		if !ok {
+			continue
+		}
+		books = append(books, &RecommendedBook{Book: book, Score: similarity.Score, SharedReaders: similarity.SharedReaders})
+	}
+	return books, nil
+}
+
+func (m memoryRecommendations) ForUser(ctx context.Context, userID int64, limit int) ([]*RecommendedBook, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	mine := m.s.interactions()[userID]
+	var total float64
+	for _, weight := range mine {
+		total += weight
+	}
+
+	scores := make(map[int64]float64)
+	for bookID, weight := range mine {
+		for _, similarity := range m.s.similarities[bookID] {
+			if _, known := mine[similarity.SimilarBookID]; !known {
+				scores[similarity.SimilarBookID] += weight * similarity.Score
+			}
+		}
+	}
+
+	books := []*RecommendedBook{}
+	for bookID, score := range scores {
+		book, ok := m.s.getBook(bookID)
+		if !ok {
+			continue
+		}
+		books = append(books, &RecommendedBook{Book: book, Score: score / total})
+	}
+	slices.SortFunc(books, func(a, b *RecommendedBook) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.Book.ID, b.Book.ID))
+	})
+	return books[:min(len(books), limit)], nil
+}
@@ -0,0 +1,216 @@
+// Filename: internal/data/memory_imports.go
+package data
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+type memoryImportJobs struct {
+	s *MemoryStore
+}
+
+func copyImportJob(job *ImportJob) *ImportJob {
+	c := *job
+	c.Errors = slices.Clone(job.Errors)
+	if c.Errors == nil {
+		c.Errors = []ImportJobError{}
+	}
+	return &c
+}
+
+func (m memoryImportJobs) Insert(ctx context.Context, job *ImportJob) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.users[job.UserID]; !ok {
+		return fmt.Errorf("user %d does not exist", job.UserID)
+	}
+	if job.Status == "" {
+		job.Status = ImportJobQueued
+	}
+	if job.Errors == nil {
+		job.Errors = []ImportJobError{}
+	}
+
+	job.ID = m.s.nextID("import_jobs")
+	job.CreatedAt = time.Now().Truncate(time.Second)
+	job.UpdatedAt = job.CreatedAt
+	job.Version = 1
+	m.s.importJobs[job.ID] = copyImportJob(job)
+	return nil
+}
+
+func (m memoryImportJobs) Get(ctx context.Context, id int64) (*ImportJob, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	job, ok := m.s.importJobs[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return copyImportJob(job), nil
+}
+
+func (m memoryImportJobs) UpdateProgress(ctx context.Context, job *ImportJob) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.importJobs[job.ID]
+	if !ok {
+		return ErrRecordNotFound
+	}
+	stored.Status = job.Status
+	stored.ProcessedRows = job.ProcessedRows
+	stored.BooksMatched = job.BooksMatched
+	stored.BooksCreated = job.BooksCreated
+	stored.ListEntries = job.ListEntries
+	stored.Reviews = job.Reviews
+	stored.Errors = slices.Clone(job.Errors)
+	stored.FinishedAt = job.FinishedAt
+	stored.UpdatedAt = time.Now().Truncate(time.Second)
+	stored.Version++
+
+	job.UpdatedAt = stored.UpdatedAt
+	job.Version = stored.Version
+	return nil
+}
+
+func (m memoryImportJobs) EnsureGoodreadsList(ctx context.Context, userID int64, shelf string) (*ReadingList, bool, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, id := range sortedIDs(m.s.lists) {
+		if list := m.s.lists[id]; int64(list.CreatedBy) == userID && list.Name == shelf {
+			copied := *list
+			return &copied, false, nil
+		}
+	}
+
+	if _, ok := m.s.users[userID]; !ok {
+		return nil, false, fmt.Errorf("user %d does not exist", userID)
+	}
+	list := &ReadingList{
+		Name:        shelf,
+		Description: "Imported from the Goodreads shelf '" + shelf + "'",
+		CreatedBy:   int(userID),
+	}
+	m.s.insertList(list)
+	return list, true, nil
+}
+
+// ImportGoodreadsEntry works like the PostgreSQL version. Everything is
+// checked before the store is touched, so a failing entry leaves nothing
+// behind here as well.
+func (m memoryImportJobs) ImportGoodreadsEntry(ctx context.Context, userID int64, entry *GoodreadsEntry, listIDs []int64) (*GoodreadsResult, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.users[userID]; !ok {
+		return nil, fmt.Errorf("user %d does not exist", userID)
+	}
+	for _, listID := range listIDs {
+		if _, ok := m.s.lists[listID]; !ok {
+			return nil, fmt.Errorf("reading list %d does not exist", listID)
+		}
+	}
+
+	result := &GoodreadsResult{}
+
+	isbn13 := entry.ISBN13
+	if isbn13 == "" {
+		isbn13 = ISBN10To13(entry.ISBN)
+	}
+
+	isbns := []string{}
+	for _, isbn := range []string{isbn13, entry.ISBN} {
+		if isbn != "" {
+			isbns = append(isbns, isbn)
+		}
+	}
+
+	titles := goodreadsTitles(strings.ToLower(entry.Title))
+
+	ids := sortedIDs(m.s.books)
+	for _, id := range ids {
+		if slices.Contains(isbns, NormalizeISBN(m.s.books[id].ISBN)) {
+			result.BookID = id
+			break
+		}
+	}
+	if result.BookID == 0 {
+		for _, id := range ids {
+			book := m.s.books[id]
+			if slices.Contains(titles, strings.ToLower(book.Title)) &&
+				strings.Contains(strings.ToLower(book.Authors), strings.ToLower(entry.Author)) {
+				result.BookID = id
+				break
+			}
+		}
+	}
+
+	if result.BookID == 0 {
+		if isbn13 == "" {
+			return nil, ErrGoodreadsNoISBN
+		}
+		book, err := goodreadsBook(entry, isbn13)
+		if err != nil {
+			return nil, err
+		}
+		err = m.s.insertBook(book)
+		if err != nil {
+			return nil, err
+		}
+		result.BookID = book.ID
+		result.Book = book
+	}
+
+	now := time.Now()
+	addedAt := now
+	if entry.DateAdded != nil {
+		addedAt = *entry.DateAdded
+	}
+	status := GoodreadsShelfStatus(entry.ExclusiveShelf)
+	for _, listID := range listIDs {
+		if slices.ContainsFunc(m.s.listBooks[listID], func(e *memoryListEntry) bool { return e.BookID == result.BookID }) {
+			continue
+		}
+		var completedAt *time.Time
+		if status == "completed" {
+			completed := addedAt
+			if entry.DateRead != nil {
+				completed = *entry.DateRead
+			}
+			completedAt = &completed
+		}
+		added := m.s.appendToList(listID, result.BookID, status, addedAt, completedAt).BooksInList
+		result.Entries = append(result.Entries, &added)
+	}
+
+	// Goodreads uses 0 for "not rated"; a book the user already reviewed here
+	// keeps that review
+	reviewed := m.s.reviewsWhere(func(r *Review) bool { return r.BookID == result.BookID && r.UserID == userID })
+	if entry.MyRating >= 1 && entry.MyRating <= 5 && len(reviewed) == 0 {
+		reviewedAt := addedAt
+		if entry.DateRead != nil {
+			reviewedAt = *entry.DateRead
+		}
+		review := &Review{
+			BookID:     result.BookID,
+			UserID:     userID,
+			Rating:     int64(entry.MyRating),
+			ReviewText: entry.Review,
+			ReviewDate: reviewedAt,
+		}
+		err := m.s.insertReview(review)
+		if err != nil {
+			return nil, err
+		}
+		result.Review = review
+	}
+
+	return result, nil
+}
@@ -0,0 +1,422 @@
+// Filename: internal/data/memory_lists.go
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type memoryReadingLists struct {
+	s *MemoryStore
+}
+
+// insertList stores a new reading list. The caller holds the lock.
+func (s *MemoryStore) insertList(list *ReadingList) {
+	list.ID = s.nextID("readinglists")
+	list.Version = 1
+	stored := *list
+	s.lists[list.ID] = &stored
+}
+
+// appendToList puts a book at the end of a list. The caller holds the lock.
+func (s *MemoryStore) appendToList(listID, bookID int64, status string, addedAt time.Time, completedAt *time.Time) *memoryListEntry {
+	entry := &memoryListEntry{
+		BooksInList: BooksInList{
+			ReadingListID: listID,
+			BookID:        bookID,
+			Status:        status,
+			Position:      len(s.listBooks[listID]),
+			Version:       1,
+		},
+		AddedAt:     addedAt,
+		CompletedAt: completedAt,
+	}
+	s.listBooks[listID] = append(s.listBooks[listID], entry)
+	return entry
+}
+
+func (m memoryReadingLists) Insert(ctx context.Context, list *ReadingList) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.users[int64(list.CreatedBy)]; !ok {
+		return fmt.Errorf("invalid created_by ID: %d does not exist", list.CreatedBy)
+	}
+	m.s.insertList(list)
+	return nil
+}
+
+func (m memoryReadingLists) Get(ctx context.Context, id int64) (*ReadingList, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	list, ok := m.s.lists[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	c := *list
+	return &c, nil
+}
+
+func (m memoryReadingLists) Update(ctx context.Context, list *ReadingList) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.lists[list.ID]
+	if !ok || stored.Version != list.Version {
+		return ErrEditConflict
+	}
+	stored.Name = list.Name
+	stored.Description = list.Description
+	stored.CreatedBy = list.CreatedBy
+	stored.IsPublic = list.IsPublic
+	stored.Version++
+
+	list.Version = stored.Version
+	return nil
+}
+
+func (m memoryReadingLists) Delete(ctx context.Context, id int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	list, ok := m.s.lists[id]
+	if !ok {
+		return ErrRecordNotFound
+	}
+	// the list only goes to the trash, Purge removes it for good
+	now := time.Now()
+	list.DeletedAt = &now
+	delete(m.s.lists, id)
+	m.s.trashedLists[id] = list
+	return nil
+}
+
+// deleteList removes a list and everything that references it, like the
+// ON DELETE CASCADE foreign keys do. The caller holds the lock.
+func (s *MemoryStore) deleteList(id int64) {
+	delete(s.lists, id)
+	delete(s.trashedLists, id)
+	delete(s.listBooks, id)
+	delete(s.collaborators, id)
+	for shareID, share := range s.shares {
+		if share.ReadingListID == id {
+			delete(s.shares, shareID)
+		}
+	}
+}
+
+func (m memoryReadingLists) GetAll(ctx context.Context, name string, filters Filters) ([]*ReadingList, Metadata, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	lists := []*ReadingList{}
+	for _, id := range sortedIDs(m.s.lists) {
+		list := *m.s.lists[id]
+		if matchesWords(list.Name, name) {
+			lists = append(lists, &list)
+		}
+	}
+
+	lists, metadata := memoryPage(lists, filters, func(list *ReadingList, column string) any {
+		switch column {
+		case "id":
+			return list.ID
+		case "name":
+			return list.Name
+		}
+		panic("unknown sort column for reading lists: " + column)
+	}, func(list *ReadingList) int64 { return list.ID })
+	return lists, metadata, nil
+}
+
+func (m memoryReadingLists) AddBookToList(ctx context.Context, book *BooksInList) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.lists[book.ReadingListID]; !ok {
+		return fmt.Errorf("reading list %d does not exist", book.ReadingListID)
+	}
+	if _, ok := m.s.books[book.BookID]; !ok {
+		return fmt.Errorf("book %d does not exist", book.BookID)
+	}
+	if slices.ContainsFunc(m.s.listBooks[book.ReadingListID], func(e *memoryListEntry) bool {
+		return e.BookID == book.BookID
+	}) {
+		return ErrDuplicateBookInList
+	}
+
+	now := time.Now()
+	var completedAt *time.Time
+	if book.Status == "completed" {
+		completedAt = &now
+	}
+	entry := m.s.appendToList(book.ReadingListID, book.BookID, book.Status, now, completedAt)
+	book.Position = entry.Position
+	book.Version = entry.Version
+	return nil
+}
+
+func (m memoryReadingLists) RemoveBookFromList(ctx context.Context, listID, bookID int) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	entries := m.s.listBooks[int64(listID)]
+	i := slices.IndexFunc(entries, func(e *memoryListEntry) bool { return e.BookID == int64(bookID) })
+	if i < 0 {
+		return ErrRecordNotFound
+	}
+
+	// close the gap left behind by the removed book
+	entries = slices.Delete(entries, i, i+1)
+	for _, entry := range entries[i:] {
+		entry.Position--
+	}
+	m.s.listBooks[int64(listID)] = entries
+	return nil
+}
+
+func (m memoryReadingLists) GetBooks(ctx context.Context, listID int64) ([]*BooksInList, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	books := []*BooksInList{}
+	for _, entry := range m.s.listBooks[listID] {
+		// books in the trash are left out
+		if _, ok := m.s.books[entry.BookID]; !ok {
+			continue
+		}
+		book := entry.BooksInList
+		books = append(books, &book)
+	}
+	return books, nil
+}
+
+func (m memoryReadingLists) GetBookDetails(ctx context.Context, listID int64) ([]*ListedBook, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	books := []*ListedBook{}
+	for _, entry := range m.s.listBooks[listID] {
+		book, ok := m.s.getBook(entry.BookID)
+		if !ok {
+			continue
+		}
+		books = append(books, &ListedBook{Book: *book, Status: entry.Status, Position: entry.Position})
+	}
+	return books, nil
+}
+
+func (m memoryReadingLists) MoveBook(ctx context.Context, listID, bookID int64, newPosition int) (*BooksInList, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.lists[listID]; !ok {
+		return nil, ErrRecordNotFound
+	}
+	entries := m.s.listBooks[listID]
+	i := slices.IndexFunc(entries, func(e *memoryListEntry) bool { return e.BookID == bookID })
+	if i < 0 {
+		return nil, ErrRecordNotFound
+	}
+	entry := entries[i]
+
+	// an index past the end simply moves the book to the end
+	newPosition = min(newPosition, len(entries)-1)
+	if newPosition != entry.Position {
+		entries = slices.Delete(entries, i, i+1)
+		entries = slices.Insert(entries, newPosition, entry)
+		for position, e := range entries {
+			e.Position = position
+		}
+		entry.Version++
+		m.s.listBooks[listID] = entries
+	}
+
+	book := entry.BooksInList
+	return &book, nil
+}
+
+func (m memoryReadingLists) CanEdit(ctx context.Context, listID, userID int64) (bool, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if list, ok := m.s.lists[listID]; ok && int64(list.CreatedBy) == userID {
+		return true, nil
+	}
+	return slices.ContainsFunc(m.s.collaborators[listID], func(c *Collaborator) bool {
+		return c.UserID == userID && c.CanEdit
+	}), nil
+}
+
+func (m memoryReadingLists) CanView(ctx context.Context, listID, userID int64) (bool, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if list, ok := m.s.lists[listID]; ok && (list.IsPublic || int64(list.CreatedBy) == userID) {
+		return true, nil
+	}
+	return slices.ContainsFunc(m.s.collaborators[listID], func(c *Collaborator) bool {
+		return c.UserID == userID
+	}), nil
+}
+
+func (m memoryReadingLists) AddCollaborator(ctx context.Context, collaborator *Collaborator) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.lists[collaborator.ReadingListID]; !ok {
+		return fmt.Errorf("reading list %d does not exist", collaborator.ReadingListID)
+	}
+	user, ok := m.s.users[collaborator.UserID]
+	if !ok {
+		return fmt.Errorf("user %d does not exist", collaborator.UserID)
+	}
+	collaborator.Username = user.Username
+
+	for _, existing := range m.s.collaborators[collaborator.ReadingListID] {
+		if existing.UserID == collaborator.UserID {
+			existing.CanEdit = collaborator.CanEdit
+			collaborator.AddedAt = existing.AddedAt
+			return nil
+		}
+	}
+
+	collaborator.AddedAt = time.Now()
+	stored := *collaborator
+	m.s.collaborators[collaborator.ReadingListID] = append(m.s.collaborators[collaborator.ReadingListID], &stored)
+	return nil
+}
+
+func (m memoryReadingLists) RemoveCollaborator(ctx context.Context, listID, userID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	collaborators := m.s.collaborators[listID]
+	i := slices.IndexFunc(collaborators, func(c *Collaborator) bool { return c.UserID == userID })
+	if i < 0 {
+		return ErrRecordNotFound
+	}
+	m.s.collaborators[listID] = slices.Delete(collaborators, i, i+1)
+	return nil
+}
+
+func (m memoryReadingLists) GetCollaborators(ctx context.Context, listID int64) ([]*Collaborator, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	collaborators := []*Collaborator{}
+	for _, stored := range m.s.collaborators[listID] {
+		c := *stored
+		// the username is joined in, so it follows renames
+		if user, ok := m.s.users[c.UserID]; ok {
+			c.Username = user.Username
+		}
+		collaborators = append(collaborators, &c)
+	}
+	return collaborators, nil
+}
+
+func (m memoryReadingLists) Clone(ctx context.Context, sourceID int64, list *ReadingList) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	m.s.insertList(list)
+	now := time.Now()
+	for _, entry := range m.s.listBooks[sourceID] {
+		if _, ok := m.s.books[entry.BookID]; !ok {
+			continue
+		}
+		m.s.appendToList(list.ID, entry.BookID, "to read", now, nil)
+	}
+	return nil
+}
+
+type memoryShares struct {
+	s *MemoryStore
+}
+
+func (m memoryShares) New(ctx context.Context, listID, userID int64, ttl time.Duration) (*ShareLink, error) {
+	token, err := generateToken(userID, ttl, ScopeShare)
+	if err != nil {
+		return nil, err
+	}
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.lists[listID]; !ok {
+		return nil, fmt.Errorf("reading list %d does not exist", listID)
+	}
+
+	share := &ShareLink{
+		ID:            m.s.nextID("readinglist_shares"),
+		ReadingListID: listID,
+		Plaintext:     token.Plaintext,
+		Hash:          token.Hash,
+		CreatedBy:     userID,
+		CreatedAt:     time.Now(),
+	}
+	if ttl > 0 {
+		share.Expiry = &token.Expiry
+	}
+
+	stored := *share
+	stored.Plaintext = ""
+	m.s.shares[share.ID] = &stored
+	return share, nil
+}
+
+func (m memoryShares) GetListForToken(ctx context.Context, tokenPlaintext string) (*ReadingList, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	now := time.Now()
+	for _, share := range m.s.shares {
+		if string(share.Hash) != string(tokenHash[:]) || share.RevokedAt != nil ||
+			(share.Expiry != nil && !share.Expiry.After(now)) {
+			continue
+		}
+		if list, ok := m.s.lists[share.ReadingListID]; ok {
+			c := *list
+			return &c, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+func (m memoryShares) GetAllForList(ctx context.Context, listID int64) ([]*ShareLink, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	shares := []*ShareLink{}
+	ids := sortedIDs(m.s.shares)
+	// newest first
+	slices.Reverse(ids)
+	for _, id := range ids {
+		if share := m.s.shares[id]; share.ReadingListID == listID {
+			c := *share
+			c.Hash = nil
+			shares = append(shares, &c)
+		}
+	}
+	return shares, nil
+}
+
+func (m memoryShares) Revoke(ctx context.Context, id, listID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	share, ok := m.s.shares[id]
+	if !ok || share.ReadingListID != listID || share.RevokedAt != nil {
+		return ErrRecordNotFound
+	}
+	now := time.Now()
+	share.RevokedAt = &now
+	return nil
+}
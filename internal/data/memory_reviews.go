@@ -0,0 +1,383 @@
+// Filename: internal/data/memory_reviews.go
+package data
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+type memoryReviews struct {
+	s *MemoryStore
+}
+
+// insertReview stores a new review and updates the ratings. The caller holds
+// the lock.
+func (s *MemoryStore) insertReview(review *Review) error {
+	if _, ok := s.books[review.BookID]; !ok {
+		return fmt.Errorf("book %d does not exist", review.BookID)
+	}
+	if _, ok := s.users[review.UserID]; !ok {
+		return fmt.Errorf("user %d does not exist", review.UserID)
+	}
+
+	review.ReviewID = s.nextID("bookreviews")
+	if review.ReviewDate.IsZero() {
+		review.ReviewDate = time.Now()
+	}
+	review.Version = 1
+	stored := *review
+	s.reviews[review.ReviewID] = &stored
+	s.refreshRatings(review.BookID)
+	return nil
+}
+
+// reviewsWhere returns the reviews that match, newest first. The caller
+// holds the lock.
+func (s *MemoryStore) reviewsWhere(match func(*Review) bool) []*Review {
+	var reviews []*Review
+	for _, review := range s.reviews {
+		if match(review) {
+			c := *review
+			reviews = append(reviews, &c)
+		}
+	}
+	slices.SortFunc(reviews, func(a, b *Review) int {
+		return cmp.Or(b.ReviewDate.Compare(a.ReviewDate), cmp.Compare(b.ReviewID, a.ReviewID))
+	})
+	return reviews
+}
+
+func (m memoryReviews) InsertReview(ctx context.Context, review *Review) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.s.insertReview(review)
+}
+
+func (m memoryReviews) GetReview(ctx context.Context, id int64) (*Review, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	review, ok := m.s.reviews[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	c := *review
+	return &c, nil
+}
+
+func (m memoryReviews) GetAllBookReviews(ctx context.Context, bookID int64) ([]*Review, error) {
+	if bookID < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.s.reviewsWhere(func(r *Review) bool { return r.BookID == bookID }), nil
+}
+
+func (m memoryReviews) GetAllWorkReviews(ctx context.Context, workID int64) ([]*Review, error) {
+	if workID < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.s.reviewsWhere(func(r *Review) bool {
+		book, ok := m.s.books[r.BookID]
+		return ok && book.WorkID == workID
+	}), nil
+}
+
+func (m memoryReviews) UpdateReview(ctx context.Context, review *Review) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.reviews[review.ReviewID]
+	if !ok || stored.Version != review.Version {
+		return ErrEditConflict
+	}
+	stored.Rating = review.Rating
+	stored.ReviewText = review.ReviewText
+	stored.Version++
+	m.s.refreshRatings(stored.BookID)
+
+	review.Version = stored.Version
+	return nil
+}
+
+func (m memoryReviews) DeleteReview(ctx context.Context, id int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	review, ok := m.s.reviews[id]
+	if !ok {
+		return ErrRecordNotFound
+	}
+	// the review only goes to the trash, Purge removes it for good
+	now := time.Now()
+	review.DeletedAt = &now
+	delete(m.s.reviews, id)
+	m.s.trashedReviews[id] = review
+	m.s.refreshRatings(review.BookID)
+	return nil
+}
+
+type memoryTags struct {
+	s *MemoryStore
+}
+
+func (m memoryTags) Insert(ctx context.Context, tag *Tag) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.books[tag.BookID]; !ok {
+		return fmt.Errorf("book %d does not exist", tag.BookID)
+	}
+
+	// tagging a book twice with the same tag only updates its visibility
+	for _, existing := range m.s.tags {
+		if existing.UserID == tag.UserID && existing.BookID == tag.BookID && existing.Name == tag.Name {
+			existing.Public = tag.Public
+			tag.CreatedAt = existing.CreatedAt
+			return nil
+		}
+	}
+
+	tag.CreatedAt = time.Now()
+	stored := *tag
+	m.s.tags = append(m.s.tags, &stored)
+	return nil
+}
+
+func (m memoryTags) Delete(ctx context.Context, userID, bookID int64, name string) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	i := slices.IndexFunc(m.s.tags, func(t *Tag) bool {
+		return t.UserID == userID && t.BookID == bookID && t.Name == name
+	})
+	if i < 0 {
+		return ErrRecordNotFound
+	}
+	m.s.tags = slices.Delete(m.s.tags, i, i+1)
+	return nil
+}
+
+// countTags counts the tags that match, most used first. The caller holds
+// the lock.
+func (s *MemoryStore) countTags(match func(*Tag) bool) []TagCount {
+	counts := []TagCount{}
+	for _, tag := range s.tags {
+		if !match(tag) {
+			continue
+		}
+		i := slices.IndexFunc(counts, func(c TagCount) bool { return c.Name == tag.Name })
+		if i < 0 {
+			counts = append(counts, TagCount{Name: tag.Name})
+			i = len(counts) - 1
+		}
+		counts[i].Count++
+	}
+	slices.SortFunc(counts, func(a, b TagCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.Name, b.Name))
+	})
+	return counts
+}
+
+func (m memoryTags) GetAllForUser(ctx context.Context, userID int64) ([]TagCount, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.s.countTags(func(t *Tag) bool { return t.UserID == userID }), nil
+}
+
+func (m memoryTags) GetForBook(ctx context.Context, userID, bookID int64) ([]*Tag, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	tags := []*Tag{}
+	for _, tag := range m.s.tags {
+		if tag.UserID == userID && tag.BookID == bookID {
+			c := *tag
+			tags = append(tags, &c)
+		}
+	}
+	slices.SortFunc(tags, func(a, b *Tag) int { return strings.Compare(a.Name, b.Name) })
+	return tags, nil
+}
+
+func (m memoryTags) GetPopularForBook(ctx context.Context, bookID int64, limit int) ([]TagCount, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	counts := m.s.countTags(func(t *Tag) bool { return t.BookID == bookID && t.Public })
+	return counts[:min(limit, len(counts))], nil
+}
+
+type memorySeries struct {
+	s *MemoryStore
+}
+
+// seriesForBook returns every series a book belongs to. The caller holds
+// the lock.
+func (s *MemoryStore) seriesForBook(bookID int64) []*SeriesEntry {
+	entries := []*SeriesEntry{}
+	for _, place := range s.seriesBooks {
+		if series, ok := s.series[place.SeriesID]; ok && place.BookID == bookID {
+			entries = append(entries, &SeriesEntry{SeriesID: series.ID, Name: series.Name, Position: place.Position})
+		}
+	}
+	slices.SortFunc(entries, func(a, b *SeriesEntry) int { return strings.Compare(a.Name, b.Name) })
+	return entries
+}
+
+// seriesBook fills in the title and authors of a place in a series. The
+// caller holds the lock.
+func (s *MemoryStore) seriesBook(place *SeriesBook) *SeriesBook {
+	c := *place
+	if book, ok := s.books[place.BookID]; ok {
+		c.Title = book.Title
+		c.Authors = book.Authors
+	}
+	return &c
+}
+
+// hasCompleted reports whether the book is completed on any list of the
+// user. The caller holds the lock.
+func (s *MemoryStore) hasCompleted(userID, bookID int64) bool {
+	for listID, entries := range s.listBooks {
+		list, ok := s.lists[listID]
+		if !ok || int64(list.CreatedBy) != userID {
+			continue
+		}
+		if slices.ContainsFunc(entries, func(e *memoryListEntry) bool {
+			return e.BookID == bookID && e.Status == "completed"
+		}) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m memorySeries) Insert(ctx context.Context, series *Series) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	series.ID = m.s.nextID("series")
+	series.Version = 1
+	stored := *series
+	stored.Books = nil
+	m.s.series[series.ID] = &stored
+	return nil
+}
+
+func (m memorySeries) Get(ctx context.Context, id int64) (*Series, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.series[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	series := *stored
+	series.Books = []*SeriesBook{}
+	for _, place := range m.s.seriesBooks {
+		if place.SeriesID == id {
+			series.Books = append(series.Books, m.s.seriesBook(place))
+		}
+	}
+	slices.SortFunc(series.Books, func(a, b *SeriesBook) int { return cmp.Compare(a.Position, b.Position) })
+	return &series, nil
+}
+
+func (m memorySeries) AddBook(ctx context.Context, book *SeriesBook) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.series[book.SeriesID]; !ok {
+		return fmt.Errorf("series %d does not exist", book.SeriesID)
+	}
+	if _, ok := m.s.books[book.BookID]; !ok {
+		return fmt.Errorf("book %d does not exist", book.BookID)
+	}
+
+	var existing *SeriesBook
+	for _, place := range m.s.seriesBooks {
+		if place.SeriesID != book.SeriesID {
+			continue
+		}
+		switch {
+		case place.BookID == book.BookID:
+			existing = place
+		case place.Position == book.Position:
+			return ErrDuplicateSeriesPosition
+		}
+	}
+
+	// a book already in the series is moved
+	if existing != nil {
+		existing.Position = book.Position
+	} else {
+		m.s.seriesBooks = append(m.s.seriesBooks, &SeriesBook{SeriesID: book.SeriesID, BookID: book.BookID, Position: book.Position})
+	}
+
+	filled := m.s.seriesBook(&SeriesBook{SeriesID: book.SeriesID, BookID: book.BookID, Position: book.Position})
+	book.Title = filled.Title
+	book.Authors = filled.Authors
+	return nil
+}
+
+func (m memorySeries) RemoveBook(ctx context.Context, seriesID, bookID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	i := slices.IndexFunc(m.s.seriesBooks, func(sb *SeriesBook) bool {
+		return sb.SeriesID == seriesID && sb.BookID == bookID
+	})
+	if i < 0 {
+		return ErrRecordNotFound
+	}
+	m.s.seriesBooks = slices.Delete(m.s.seriesBooks, i, i+1)
+	return nil
+}
+
+func (m memorySeries) GetForBook(ctx context.Context, bookID int64) ([]*SeriesEntry, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.s.seriesForBook(bookID), nil
+}
+
+func (m memorySeries) NextInSeries(ctx context.Context, bookID, userID int64) ([]*SeriesBook, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	next := []*SeriesBook{}
+	for _, current := range m.s.seriesBooks {
+		if current.BookID != bookID {
+			continue
+		}
+		var first *SeriesBook
+		for _, place := range m.s.seriesBooks {
+			if place.SeriesID != current.SeriesID || place.Position <= current.Position ||
+				m.s.hasCompleted(userID, place.BookID) {
+				continue
+			}
+			if first == nil || place.Position < first.Position {
+				first = place
+			}
+		}
+		if first != nil {
+			next = append(next, m.s.seriesBook(first))
+		}
+	}
+	slices.SortFunc(next, func(a, b *SeriesBook) int { return cmp.Compare(a.SeriesID, b.SeriesID) })
+	return next, nil
+}
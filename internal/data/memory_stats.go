@@ -0,0 +1,330 @@
+// Filename: internal/data/memory_stats.go
+package data
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+type memoryStats struct {
+	s *MemoryStore
+}
+
+// dateOf drops the time of day, like storing a value in a date column.
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// listEntriesOf returns the list entries on the lists a user created. The
+// caller holds the lock.
+func (s *MemoryStore) listEntriesOf(userID int64) []*memoryListEntry {
+	var entries []*memoryListEntry
+	for _, listID := range sortedIDs(s.lists) {
+		if int64(s.lists[listID].CreatedBy) == userID {
+			entries = append(entries, s.listBooks[listID]...)
+		}
+	}
+	return entries
+}
+
+func (m memoryStats) InsertProgress(ctx context.Context, progress *ReadingProgress) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.users[progress.UserID]; !ok {
+		return fmt.Errorf("user %d does not exist", progress.UserID)
+	}
+	if _, ok := m.s.books[progress.BookID]; !ok {
+		return fmt.Errorf("book %d does not exist", progress.BookID)
+	}
+
+	progress.ID = m.s.nextID("reading_progress")
+	progress.LoggedOn = dateOf(progress.LoggedOn)
+	progress.Version = 1
+	stored := *progress
+	m.s.progress[progress.ID] = &stored
+	return nil
+}
+
+func (m memoryStats) UpsertGoal(ctx context.Context, goal *ReadingGoal) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	key := memoryGoalKey{goal.UserID, goal.Year}
+	if existing, ok := m.s.goals[key]; ok {
+		goal.Version = existing.Version + 1
+	} else {
+		goal.Version = 1
+	}
+	stored := *goal
+	m.s.goals[key] = &stored
+	return nil
+}
+
+func (m memoryStats) GetGoal(ctx context.Context, userID int64, year int) (*ReadingGoal, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	goal, ok := m.s.goals[memoryGoalKey{userID, year}]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	c := *goal
+	return &c, nil
+}
+
+func (m memoryStats) GetForUser(ctx context.Context, userID int64, year int) (*ReadingStats, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stats := &ReadingStats{
+		UserID:  userID,
+		Year:    year,
+		Monthly: make([]MonthlyStats, 12),
+		Genres:  []GenreCount{},
+	}
+	for i := range stats.Monthly {
+		stats.Monthly[i].Month = i + 1
+	}
+
+	entries := m.s.listEntriesOf(userID)
+
+	// A book that sits on several of the user's lists is only counted once,
+	// on the first day it was completed
+	firstCompleted := make(map[int64]time.Time)
+	lifetime := make(map[int64]bool)
+	var days []time.Time
+	for _, entry := range entries {
+		if entry.Status == "completed" {
+			lifetime[entry.BookID] = true
+		}
+		if entry.CompletedAt == nil {
+			continue
+		}
+		days = append(days, *entry.CompletedAt)
+		if entry.Status != "completed" {
+			continue
+		}
+		if first, ok := firstCompleted[entry.BookID]; !ok || entry.CompletedAt.Before(first) {
+			firstCompleted[entry.BookID] = *entry.CompletedAt
+		}
+	}
+	stats.LifetimeCompleted = len(lifetime)
+	for _, completed := range firstCompleted {
+		if completed.Year() == year {
+			stats.Monthly[completed.Month()-1].BooksCompleted++
+			stats.BooksCompleted++
+		}
+	}
+
+	// Genre breakdown of the books completed this year, counted the same way
+	genres := make(map[string]int)
+	for bookID, completed := range firstCompleted {
+		book, ok := m.s.books[bookID]
+		if !ok || book.DeletedAt != nil || completed.Year() != year {
+			continue
+		}
+		genre := book.Genre
+		if genre == "" {
+			genre = "unknown"
+		}
+		genres[genre]++
+	}
+	for genre, count := range genres {
+		stats.Genres = append(stats.Genres, GenreCount{Genre: genre, Count: count})
+	}
+	slices.SortFunc(stats.Genres, func(a, b GenreCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.Genre, b.Genre))
+	})
+
+	for _, progress := range m.s.progress {
+		if progress.UserID != userID {
+			continue
+		}
+		days = append(days, progress.LoggedOn)
+		if progress.LoggedOn.Year() == year {
+			stats.Monthly[progress.LoggedOn.Month()-1].PagesRead += progress.PagesRead
+			stats.PagesRead += progress.PagesRead
+		}
+	}
+
+	// Reviews written and the average rating the user gave this year
+	var ratingSum int64
+	for _, review := range m.s.reviews {
+		if review.UserID == userID && review.ReviewDate.Year() == year {
+			stats.ReviewsWritten++
+			ratingSum += review.Rating
+		}
+	}
+	if stats.ReviewsWritten > 0 {
+		stats.AverageRating = roundRating(float64(ratingSum) / float64(stats.ReviewsWritten))
+	}
+
+	stats.CurrentStreak, stats.LongestStreak = calculateStreaks(days, time.Now())
+
+	if goal, ok := m.s.goals[memoryGoalKey{userID, year}]; ok {
+		stats.Goal = calculateGoalProgress(goal, stats.BooksCompleted, stats.PagesRead)
+	}
+
+	return stats, nil
+}
+
+type memoryExports struct {
+	s *MemoryStore
+}
+
+func (m memoryExports) Size(ctx context.Context, userID int64) (int, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	size := len(m.s.listEntriesOf(userID))
+	for _, review := range m.s.reviews {
+		if review.UserID == userID {
+			size++
+		}
+	}
+	for _, progress := range m.s.progress {
+		if progress.UserID == userID {
+			size++
+		}
+	}
+	return size, nil
+}
+
+func (m memoryExports) GetForUser(ctx context.Context, userID int64) (*UserExport, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	user, ok := m.s.users[userID]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	export := &UserExport{
+		Profile: &User{
+			ID:        user.ID,
+			CreatedAt: user.CreatedAt,
+			Username:  user.Username,
+			Email:     user.Email,
+			Activated: user.Activated,
+		},
+		Lists:       []*ExportedList{},
+		Reviews:     []*ExportedReview{},
+		Progress:    []*ReadingProgress{},
+		Goals:       []*ReadingGoal{},
+		GeneratedAt: time.Now(),
+	}
+
+	for _, listID := range sortedIDs(m.s.lists) {
+		list := m.s.lists[listID]
+		if int64(list.CreatedBy) != userID {
+			continue
+		}
+		exported := &ExportedList{ReadingList: *list, Items: []*ExportedListItem{}}
+		for _, entry := range m.s.listBooks[listID] {
+			book, ok := m.s.books[entry.BookID]
+			if !ok {
+				continue
+			}
+			exported.Items = append(exported.Items, &ExportedListItem{
+				BookID:      book.ID,
+				Title:       book.Title,
+				Authors:     book.Authors,
+				ISBN:        book.ISBN,
+				Status:      entry.Status,
+				Position:    entry.Position,
+				AddedAt:     entry.AddedAt,
+				CompletedAt: entry.CompletedAt,
+			})
+		}
+		export.Lists = append(export.Lists, exported)
+	}
+
+	for _, id := range sortedIDs(m.s.reviews) {
+		review := m.s.reviews[id]
+		book, ok := m.s.books[review.BookID]
+		if review.UserID != userID || !ok {
+			continue
+		}
+		export.Reviews = append(export.Reviews, &ExportedReview{
+			ID:         review.ReviewID,
+			BookID:     book.ID,
+			Title:      book.Title,
+			Authors:    book.Authors,
+			ISBN:       book.ISBN,
+			Rating:     review.Rating,
+			Review:     review.ReviewText,
+			ReviewedAt: review.ReviewDate,
+		})
+	}
+	slices.SortStableFunc(export.Reviews, func(a, b *ExportedReview) int {
+		return a.ReviewedAt.Compare(b.ReviewedAt)
+	})
+
+	for _, id := range sortedIDs(m.s.progress) {
+		if progress := m.s.progress[id]; progress.UserID == userID {
+			c := *progress
+			export.Progress = append(export.Progress, &c)
+		}
+	}
+	slices.SortStableFunc(export.Progress, func(a, b *ReadingProgress) int {
+		return a.LoggedOn.Compare(b.LoggedOn)
+	})
+
+	for key, goal := range m.s.goals {
+		if key.userID == userID {
+			c := *goal
+			export.Goals = append(export.Goals, &c)
+		}
+	}
+	slices.SortFunc(export.Goals, func(a, b *ReadingGoal) int { return cmp.Compare(a.Year, b.Year) })
+
+	return export, nil
+}
+
+func (m memoryExports) SaveArchive(ctx context.Context, userID int64, format string, archive []byte, ttl time.Duration) (*Token, error) {
+	token, err := generateToken(userID, ttl, ScopeExport)
+	if err != nil {
+		return nil, err
+	}
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	now := time.Now()
+	for hash, export := range m.s.exports {
+		if export.Expiry.Before(now) {
+			delete(m.s.exports, hash)
+		}
+	}
+
+	m.s.exports[string(token.Hash)] = &memoryExport{
+		ExportArchive: ExportArchive{
+			UserID:    userID,
+			Format:    format,
+			Archive:   slices.Clone(archive),
+			CreatedAt: now,
+		},
+		Expiry: token.Expiry,
+	}
+	return token, nil
+}
+
+func (m memoryExports) GetArchive(ctx context.Context, tokenPlaintext string) (*ExportArchive, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	export, ok := m.s.exports[string(tokenHash[:])]
+	if !ok || !export.Expiry.After(time.Now()) {
+		return nil, ErrRecordNotFound
+	}
+	archive := export.ExportArchive
+	return &archive, nil
+}
@@ -0,0 +1,121 @@
+// Filename: internal/data/memory_trash.go
+package data
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+type memoryTrash struct {
+	s *MemoryStore
+}
+
+// trashed returns copies of the rows of a trash table that keep returns true
+// for, the most recently deleted first.
+func trashed[T any](table map[int64]*T, deletedAt func(*T) time.Time, keep func(*T) bool) []*T {
+	rows := []*T{}
+	for _, id := range sortedIDs(table) {
+		if keep(table[id]) {
+			row := *table[id]
+			rows = append(rows, &row)
+		}
+	}
+	// newest id first among rows deleted at the same time, like the ORDER BY
+	slices.Reverse(rows)
+	slices.SortStableFunc(rows, func(a, b *T) int { return deletedAt(b).Compare(deletedAt(a)) })
+	return rows
+}
+
+func (m memoryTrash) GetForUser(ctx context.Context, userID int64, includeBooks bool) (*Trash, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	trash := &Trash{
+		ReadingLists: trashed(m.s.trashedLists,
+			func(l *ReadingList) time.Time { return *l.DeletedAt },
+			func(l *ReadingList) bool { return int64(l.CreatedBy) == userID }),
+		Reviews: trashed(m.s.trashedReviews,
+			func(r *Review) time.Time { return *r.DeletedAt },
+			func(r *Review) bool { return r.UserID == userID }),
+	}
+	if includeBooks {
+		trash.Books = trashed(m.s.trashedBooks,
+			func(b *Book) time.Time { return *b.DeletedAt },
+			func(b *Book) bool { return true })
+		for _, book := range trash.Books {
+			book.Series = nil
+		}
+	}
+	return trash, nil
+}
+
+func (m memoryTrash) RestoreBook(ctx context.Context, id int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	book, ok := m.s.trashedBooks[id]
+	if !ok {
+		return ErrRecordNotFound
+	}
+	book.DeletedAt = nil
+	delete(m.s.trashedBooks, id)
+	m.s.books[id] = book
+	m.s.refreshRatings(id)
+	return nil
+}
+
+func (m memoryTrash) RestoreReadingList(ctx context.Context, id, ownerID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	list, ok := m.s.trashedLists[id]
+	if !ok || int64(list.CreatedBy) != ownerID {
+		return ErrRecordNotFound
+	}
+	list.DeletedAt = nil
+	delete(m.s.trashedLists, id)
+	m.s.lists[id] = list
+	return nil
+}
+
+func (m memoryTrash) RestoreReview(ctx context.Context, id, ownerID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	review, ok := m.s.trashedReviews[id]
+	if !ok || review.UserID != ownerID {
+		return ErrRecordNotFound
+	}
+	review.DeletedAt = nil
+	delete(m.s.trashedReviews, id)
+	m.s.reviews[id] = review
+	m.s.refreshRatings(review.BookID)
+	return nil
+}
+
+func (m memoryTrash) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	var purged int64
+	for id, review := range m.s.trashedReviews {
+		if review.DeletedAt.Before(cutoff) {
+			delete(m.s.trashedReviews, id)
+			purged++
+		}
+	}
+	for id, list := range m.s.trashedLists {
+		if list.DeletedAt.Before(cutoff) {
+			m.s.deleteList(id)
+			purged++
+		}
+	}
+	for id, book := range m.s.trashedBooks {
+		if book.DeletedAt.Before(cutoff) {
+			m.s.deleteBook(id)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,97 @@
+// Filename: internal/data/memory_tx.go
+package data
+
+import (
+	"context"
+	"maps"
+	"slices"
+)
+
+// memoryTransactor runs a unit of work on a copy of the store, which takes
+// the place of the original only if the work succeeds. The store stays locked
+// meanwhile, so nothing else sees the work half done.
+type memoryTransactor struct {
+	s *MemoryStore
+}
+
+func (t memoryTransactor) WithinTx(ctx context.Context, fn func(Models) error) error {
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+
+	tx := &MemoryStore{memoryTables: t.s.memoryTables.clone()}
+	err := fn(tx.Models())
+	if err != nil {
+		return err
+	}
+
+	t.s.memoryTables = tx.memoryTables
+	return nil
+}
+
+// cloneRows copies a table down to its rows, which the repositories change
+// in place.
+func cloneRows[K comparable, V any](table map[K]*V) map[K]*V {
+	c := make(map[K]*V, len(table))
+	for k, row := range table {
+		copied := *row
+		c[k] = &copied
+	}
+	return c
+}
+
+// cloneRowSlices is cloneRows for tables that keep a slice of rows per key.
+func cloneRowSlices[K comparable, V any](table map[K][]*V) map[K][]*V {
+	c := make(map[K][]*V, len(table))
+	for k, rows := range table {
+		c[k] = cloneSlice(rows)
+	}
+	return c
+}
+
+func cloneSlice[V any](rows []*V) []*V {
+	c := make([]*V, len(rows))
+	for i, row := range rows {
+		copied := *row
+		c[i] = &copied
+	}
+	return c
+}
+
+// clone returns a copy of the tables that shares nothing the repositories
+// change with the original.
+func (t memoryTables) clone() memoryTables {
+	c := memoryTables{
+		ids:           maps.Clone(t.ids),
+		books:         cloneRows(t.books),
+		works:         cloneRows(t.works),
+		redirects:     maps.Clone(t.redirects),
+		users:         cloneRows(t.users),
+		permissions:   make(map[int64]Permissions, len(t.permissions)),
+		tokens:        cloneSlice(t.tokens),
+		reviews:       cloneRows(t.reviews),
+		lists:         cloneRows(t.lists),
+		listBooks:     cloneRowSlices(t.listBooks),
+		collaborators: cloneRowSlices(t.collaborators),
+		shares:        cloneRows(t.shares),
+		tags:          cloneSlice(t.tags),
+		series:        cloneRows(t.series),
+		seriesBooks:   cloneSlice(t.seriesBooks),
+		progress:      cloneRows(t.progress),
+		goals:         cloneRows(t.goals),
+		importJobs:    cloneRows(t.importJobs),
+		exports:       cloneRows(t.exports),
+		changes:       cloneRows(t.changes),
+		// the similarities are only ever replaced as a whole
+		similarities: maps.Clone(t.similarities),
+		// events are only ever added
+		auditEvents: slices.Clone(t.auditEvents),
+
+		trashedBooks:   cloneRows(t.trashedBooks),
+		trashedLists:   cloneRows(t.trashedLists),
+		trashedReviews: cloneRows(t.trashedReviews),
+	}
+	for userID, codes := range t.permissions {
+		c.permissions[userID] = slices.Clone(codes)
+	}
+	return c
+}
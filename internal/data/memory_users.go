@@ -0,0 +1,201 @@
+// Filename: internal/data/memory_users.go
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"slices"
+	"strings"
+	"time"
+)
+
+type memoryUsers struct {
+	s *MemoryStore
+}
+
+func copyUser(user *User) *User {
+	c := *user
+	c.Password.hash = slices.Clone(user.Password.hash)
+	c.Password.plaintext = nil
+	return &c
+}
+
+func (m memoryUsers) Insert(ctx context.Context, user *User) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	// email is a citext column, so the check ignores case
+	for _, existing := range m.s.users {
+		if strings.EqualFold(existing.Email, user.Email) {
+			return ErrDuplicateEmail
+		}
+	}
+
+	user.ID = m.s.nextID("users")
+	user.CreatedAt = time.Now().Truncate(time.Second)
+	m.s.users[user.ID] = copyUser(user)
+	return nil
+}
+
+func (m memoryUsers) GetByEmail(ctx context.Context, email string) (*User, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, user := range m.s.users {
+		if strings.EqualFold(user.Email, email) {
+			return copyUser(user), nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+func (m memoryUsers) GetByID(ctx context.Context, id int64) (*User, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	user, ok := m.s.users[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return copyUser(user), nil
+}
+
+func (m memoryUsers) GetForToken(ctx context.Context, tokenScope, tokenPlaintext string) (*User, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range m.s.tokens {
+		if string(token.Hash) != string(tokenHash[:]) || token.Scope != tokenScope || !token.Expiry.After(now) {
+			continue
+		}
+		if user, ok := m.s.users[token.UserID]; ok {
+			return copyUser(user), nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+func (m memoryUsers) Update(ctx context.Context, user *User) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.users[user.ID]
+	if !ok || stored.Version != user.Version {
+		return ErrEditConflict
+	}
+	for id, existing := range m.s.users {
+		if id != user.ID && strings.EqualFold(existing.Email, user.Email) {
+			return ErrDuplicateEmail
+		}
+	}
+
+	user.Version++
+	m.s.users[user.ID] = copyUser(user)
+	return nil
+}
+
+func (m memoryUsers) GetUserReviews(ctx context.Context, userID int64) ([]UserReview, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	var reviews []UserReview
+	for _, id := range sortedIDs(m.s.reviews) {
+		review := m.s.reviews[id]
+		if review.UserID != userID {
+			continue
+		}
+		reviews = append(reviews, UserReview{
+			ReviewID:   review.ReviewID,
+			BookID:     review.BookID,
+			Rating:     review.Rating,
+			ReviewText: review.ReviewText,
+			ReviewDate: review.ReviewDate,
+			Version:    review.Version,
+		})
+	}
+	return reviews, nil
+}
+
+func (m memoryUsers) GetUserLists(ctx context.Context, userID int64) ([]UserList, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	var lists []UserList
+	for _, id := range sortedIDs(m.s.lists) {
+		list := m.s.lists[id]
+		if int64(list.CreatedBy) != userID {
+			continue
+		}
+		lists = append(lists, UserList{
+			ID:          list.ID,
+			Name:        list.Name,
+			Description: list.Description,
+			CreatedBy:   list.CreatedBy,
+			Version:     list.Version,
+		})
+	}
+	return lists, nil
+}
+
+type memoryTokens struct {
+	s *MemoryStore
+}
+
+func (m memoryTokens) New(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+	err = m.Insert(ctx, token)
+	return token, err
+}
+
+func (m memoryTokens) Insert(ctx context.Context, token *Token) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored := *token
+	stored.Plaintext = ""
+	m.s.tokens = append(m.s.tokens, &stored)
+	return nil
+}
+
+func (m memoryTokens) DeleteAllForUser(ctx context.Context, scope string, userID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	m.s.tokens = slices.DeleteFunc(m.s.tokens, func(t *Token) bool {
+		return t.Scope == scope && t.UserID == userID
+	})
+	return nil
+}
+
+// memoryPermissionCodes are the rows of the permissions table.
+var memoryPermissionCodes = []string{PermissionModerateBooks, PermissionReadAudit, PermissionDatabaseStats}
+
+type memoryPermissions struct {
+	s *MemoryStore
+}
+
+func (m memoryPermissions) GetAllForUser(ctx context.Context, userID int64) (Permissions, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return slices.Clone(m.s.permissions[userID]), nil
+}
+
+func (m memoryPermissions) AddForUser(ctx context.Context, userID int64, codes ...string) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	// unknown codes are skipped like the INSERT ... SELECT does
+	for _, code := range codes {
+		if slices.Contains(memoryPermissionCodes, code) && !m.s.permissions[userID].Include(code) {
+			m.s.permissions[userID] = append(m.s.permissions[userID], code)
+		}
+	}
+	return nil
+}
@@ -27,7 +27,8 @@ type Product struct {
 
 // ProductModel provides methods for interacting with the products database table.
 type ProductModel struct {
-	DB *sql.DB // Database connection pool.
+	DB      DBTX       // Database connection pool.
+	Timeout time.Duration // how long a single query may run
 }
 
 // ValidateProduct checks if the fields in the Product struct adhere to specified validation rules.
@@ -44,7 +45,7 @@ func ValidateProduct(v *validator.Validator, product *Product) {
 }
 
 // InsertProduct inserts a new product into the database, returning the product's unique ID, creation time, and version.
-func (p ProductModel) InsertProduct(product *Product) error {
+func (p ProductModel) InsertProduct(ctx context.Context, product *Product) error {
 	query := `
 		INSERT INTO products (name, description, category, image_url, price)
 		VALUES ($1, $2, $3, $4, $5)
@@ -52,7 +53,7 @@ func (p ProductModel) InsertProduct(product *Product) error {
 	`
 	args := []any{product.Name, product.Description, product.Category, product.ImageURL, product.Price}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
 	defer cancel()
 
 	return p.DB.QueryRowContext(ctx, query, args...).Scan(
@@ -63,7 +64,7 @@ func (p ProductModel) InsertProduct(product *Product) error {
 }
 
 // GetProduct retrieves a product by its ID from the database, returning an error if not found.
-func (p ProductModel) GetProduct(id int64) (*Product, error) {
+func (p ProductModel) GetProduct(ctx context.Context, id int64) (*Product, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound // Return an error for invalid ID.
 	}
@@ -75,7 +76,7 @@ func (p ProductModel) GetProduct(id int64) (*Product, error) {
 	`
 
 	var product Product
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
 	defer cancel()
 
 	err := p.DB.QueryRowContext(ctx, query, id).Scan(
@@ -101,7 +102,7 @@ func (p ProductModel) GetProduct(id int64) (*Product, error) {
 }
 
 // UpdateProduct updates an existing product in the database, incrementing its version for concurrency control.
-func (p ProductModel) UpdateProduct(product *Product) error {
+func (p ProductModel) UpdateProduct(ctx context.Context, product *Product) error {
 	query := `
 		UPDATE products
 		SET name = $1, description = $2, category = $3, image_url = $4, price = $5, avg_rating = $6, version = version + 1
@@ -112,14 +113,14 @@ func (p ProductModel) UpdateProduct(product *Product) error {
 	// Removed `product.UpdatedAt` from the args slice
 	args := []any{product.Name, product.Description, product.Category, product.ImageURL, product.Price, product.AvgRating, product.ProductID}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
 	defer cancel()
 
 	return p.DB.QueryRowContext(ctx, query, args...).Scan(&product.Version)
 }
 
 // DeleteProduct deletes a product by its ID from the database and checks that a row was deleted.
-func (p ProductModel) DeleteProduct(id int64) error {
+func (p ProductModel) DeleteProduct(ctx context.Context, id int64) error {
 	if id < 1 {
 		return ErrRecordNotFound // Return an error if the ID is invalid.
 	}
@@ -129,7 +130,7 @@ func (p ProductModel) DeleteProduct(id int64) error {
 		WHERE product_id = $1
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
 	defer cancel()
 
 	result, err := p.DB.ExecContext(ctx, query, id)
@@ -151,7 +152,7 @@ func (p ProductModel) DeleteProduct(id int64) error {
 
 // GetAllProducts retrieves all products from the database, with support for name/category filtering
 // and pagination controlled by the provided Filters struct.
-func (p ProductModel) GetAllProducts(name string, category string, filters Filters) ([]*Product, Metadata, error) {
+func (p ProductModel) GetAllProducts(ctx context.Context, name string, category string, filters Filters) ([]*Product, Metadata, error) {
 	query := fmt.Sprintf(`
 		SELECT COUNT(*) OVER(), product_id, name, description, category, image_url, price, avg_rating, created_at, version
 		FROM products
@@ -160,7 +161,7 @@ func (p ProductModel) GetAllProducts(name string, category string, filters Filte
 		ORDER BY %s %s, product_id ASC 
 		LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
 	defer cancel()
 
 	rows, err := p.DB.QueryContext(ctx, query, name, category, filters.limit(), filters.offset())
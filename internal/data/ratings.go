@@ -0,0 +1,56 @@
+// Filename: internal/data/ratings.go
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RatingDistribution holds the number of 1 to 5 star ratings of a book.
+// Half stars are counted with the star above them.
+type RatingDistribution [5]int
+
+// Scan reads the integer[] column the distribution is stored in.
+func (d *RatingDistribution) Scan(src any) error {
+	var counts pq.Int64Array
+	err := counts.Scan(src)
+	if err != nil {
+		return err
+	}
+	if len(counts) != len(d) {
+		return fmt.Errorf("rating distribution has %d entries, expected %d", len(counts), len(d))
+	}
+	for i, count := range counts {
+		d[i] = int(count)
+	}
+	return nil
+}
+
+// MarshalJSON writes the distribution keyed by star, e.g. {"1": 0, ..., "5": 12}.
+func (d RatingDistribution) MarshalJSON() ([]byte, error) {
+	counts := make(map[string]int, len(d))
+	for i, count := range d {
+		counts[strconv.Itoa(i+1)] = count
+	}
+	return json.Marshal(counts)
+}
+
+// RefreshWeightedRatings brings the weighted rating of every book back to the
+// mean over all ratings. A book's weighted rating is only recomputed when it
+// is rated itself, while the mean moves with every rating. It returns how
+// many books changed.
+func (c BookModel) RefreshWeightedRatings(ctx context.Context) (int64, error) {
+	// this can rewrite every book, so it gets more than the usual query
+	// timeout
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	var refreshed int64
+	err := c.DB.QueryRowContext(ctx, `SELECT refresh_weighted_ratings()`).Scan(&refreshed)
+	return refreshed, err
+}
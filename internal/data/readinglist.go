@@ -18,18 +18,33 @@ type ReadingList struct {
 	Name        string `json:"name"`        // Maps to 'name' in SQL
 	Description string `json:"description"` // Maps to 'description' in SQL
 	CreatedBy   int    `json:"created_by"`  // Maps to 'created_by' in SQL
+	IsPublic    bool   `json:"public"`      // Maps to 'is_public' in SQL
 	Version     int    `json:"version"`     // Maps to 'version' in SQL
+	// Set while the list is in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 type BooksInList struct {
 	ReadingListID int64  `json:"readinglist_id"`
 	BookID        int64  `json:"book_id"`
 	Status        string `json:"status"`
+	Position      int    `json:"position"`
 	Version       int16  `json:"version"`
 }
 
+// Collaborator is a user the owner of a list shared it with.
+type Collaborator struct {
+	ReadingListID int64     `json:"readinglist_id"`
+	UserID        int64     `json:"user_id"`
+	Username      string    `json:"username"`
+	CanEdit       bool      `json:"can_edit"`
+	AddedAt       time.Time `json:"added_at"`
+}
+
 type ReadingListModel struct {
-	DB *sql.DB
+	DB      DBTX
+	Reader  DBTX          // the read replica, for requests marked by AllowReplicaReads
+	Timeout time.Duration // how long a single query may run
 }
 
 func ValidateReadingList(v *validator.Validator, list *ReadingList) {
@@ -48,66 +63,65 @@ func ValidateReadingList(v *validator.Validator, list *ReadingList) {
 // validate if status for book being added to reading list is correct
 func ValidateReadingStatus(v *validator.Validator, readingStatus string) {
 	v.Check(readingStatus != "", "status", "must be provided")
-	v.Check(readingStatus == "currently reading" || readingStatus == "completed",
+	v.Check(validator.PermittedValue(readingStatus, "to read", "currently reading", "completed"),
 		"status",
-		"status must be of values 'completed' or 'currently reading'")
+		"status must be of values 'to read', 'completed' or 'currently reading'")
 }
 
-func (c ReadingListModel) Insert(list *ReadingList) error {
-	// Create a context with a 3-second timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (c ReadingListModel) Insert(ctx context.Context, list *ReadingList) error {
+	// Create a context bounded by the model timeout
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 	defer cancel()
 
-	// Check if the CreatedBy user exists
-	var createdByExists bool
-	err := c.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)", list.CreatedBy).Scan(&createdByExists)
-	if err != nil {
-		return fmt.Errorf("error checking created_by user: %w", err)
-	}
-	if !createdByExists {
-		return fmt.Errorf("invalid created_by ID: %d does not exist", list.CreatedBy)
-	}
-
+	// The list is only inserted if the CreatedBy user exists, checked in
+	// the same statement so the user can't disappear in between
 	query := `
-		INSERT INTO readinglists (name, description, created_by) 
-		VALUES ($1, $2, $3) 
+		INSERT INTO readinglists (name, description, created_by, is_public) 
+		SELECT $1::text, $2::text, $3::int, $4::bool
+		WHERE EXISTS (SELECT 1 FROM users WHERE id = $3)
 		RETURNING id, version;
 			 `
 
-	args := []any{list.Name, list.Description, list.CreatedBy}
+	args := []any{list.Name, list.Description, list.CreatedBy, list.IsPublic}
 
-	err = c.DB.QueryRowContext(ctx, query, args...).Scan(&list.ID, &list.Version)
+	err := c.DB.QueryRowContext(ctx, query, args...).Scan(&list.ID, &list.Version)
 	if err != nil {
-		return fmt.Errorf("error inserting reading list: %w", err)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("invalid created_by ID: %d does not exist", list.CreatedBy)
+		default:
+			return fmt.Errorf("error inserting reading list: %w", err)
+		}
 	}
 
 	return nil
 }
 
 // Get a specific Comment from the comments table
-func (c ReadingListModel) Get(id int64) (*ReadingList, error) {
+func (c ReadingListModel) Get(ctx context.Context, id int64) (*ReadingList, error) {
 	// check if the id is valid
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 	// the SQL query to be executed against the database table
 	query := `
-		 SELECT  id, name, description, created_by, books, status, version
+		 SELECT  id, name, description, created_by, is_public, version
 		 FROM readinglists
-		 WHERE id = $1
+		 WHERE id = $1 AND deleted_at IS NULL
 	   `
 	// declare a variable of type Comment to store the returned comment
 	var list ReadingList
 
-	// Set a 3-second context/timer
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	// Bound the query by the model timeout
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 	defer cancel()
 
-	err := c.DB.QueryRowContext(ctx, query, id).Scan(
+	err := readFrom(ctx, c.DB, c.Reader).QueryRowContext(ctx, query, id).Scan(
 		&list.ID,
 		&list.Name,
 		&list.Description,
 		&list.CreatedBy,
+		&list.IsPublic,
 		&list.Version,
 	)
 	// Cont'd on the next slide
@@ -123,36 +137,46 @@ func (c ReadingListModel) Get(id int64) (*ReadingList, error) {
 	return &list, nil
 }
 
-func (c ReadingListModel) Update(list *ReadingList) error {
+func (c ReadingListModel) Update(ctx context.Context, list *ReadingList) error {
 	// The SQL query to be executed against the database table
 	// Every time we make an update, we increment the version number
 	query := `
 			UPDATE readinglists
-			SET  name = $1, description = $2, created_by = $3, version = version + 1
-			WHERE id = $4
+			SET  name = $1, description = $2, created_by = $3, is_public = $4, version = version + 1
+			WHERE id = $5 AND version = $6 AND deleted_at IS NULL
 			RETURNING version
 			`
 
-	args := []any{list.Name, list.Description, list.CreatedBy, list.ID}
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	args := []any{list.Name, list.Description, list.CreatedBy, list.IsPublic, list.ID, list.Version}
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 	defer cancel()
 
-	return c.DB.QueryRowContext(ctx, query, args...).Scan(&list.Version)
-
+	// no row means the list was changed (or deleted) since it was read
+	err := c.DB.QueryRowContext(ctx, query, args...).Scan(&list.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
 }
 
-func (c ReadingListModel) Delete(id int64) error {
+func (c ReadingListModel) Delete(ctx context.Context, id int64) error {
 
 	// check if the id is valid
 	if id < 1 {
 		return ErrRecordNotFound
 	}
-	// the SQL query to be executed against the database table
+	// the list only goes to the trash, the purge job removes it for good
 	query := `
-        DELETE FROM readinglists
-        WHERE id = $1
+        UPDATE readinglists
+        SET deleted_at = NOW()
+        WHERE id = $1 AND deleted_at IS NULL
 		`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 	defer cancel()
 
 	// ExecContext does not return any rows unlike QueryRowContext.
@@ -177,23 +201,22 @@ func (c ReadingListModel) Delete(id int64) error {
 
 }
 
-func (c ReadingListModel) GetAll(name string, filters Filters) ([]*ReadingList, Metadata, error) {
+func (c ReadingListModel) GetAll(ctx context.Context, name string, filters Filters) ([]*ReadingList, Metadata, error) {
 
 	// the SQL query to be executed against the database table
 	query := fmt.Sprintf(`
-	SELECT COUNT(*) OVER(), id, name, description, created_by, books, status, version
+	SELECT COUNT(*) OVER(), id, name, description, created_by, is_public, version
 	FROM readinglists
-	WHERE (to_tsvector('simple', name) @@
+	WHERE deleted_at IS NULL
+	AND (to_tsvector('simple', name) @@
 		  plainto_tsquery('simple', $1) OR $1 = '')
-	AND (to_tsvector('simple', status) @@
-		 plainto_tsquery('simple', $2) OR $2 = '')
 	ORDER BY %s %s, id ASC
-	LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 	defer cancel()
 
-	rows, err := c.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	rows, err := readFrom(ctx, c.DB, c.Reader).QueryContext(ctx, query, name, filters.limit(), filters.offset())
 	if err != nil {
 		return nil, Metadata{}, err
 	}
@@ -204,12 +227,12 @@ func (c ReadingListModel) GetAll(name string, filters Filters) ([]*ReadingList,
 
 	for rows.Next() {
 		var list ReadingList
-		// Scan the values, including the pointer for 'books'
 		err := rows.Scan(&totalRecords,
 			&list.ID,
 			&list.Name,
 			&list.Description,
 			&list.CreatedBy,
+			&list.IsPublic,
 			&list.Version,
 		)
 		if err != nil {
@@ -228,55 +251,93 @@ func (c ReadingListModel) GetAll(name string, filters Filters) ([]*ReadingList,
 	return lists, metadata, nil
 }
 
-func (c *ReadingListModel) AddBookToList(book *BooksInList) error {
+func (c *ReadingListModel) AddBookToList(ctx context.Context, book *BooksInList) error {
+	// Create a context bounded by the model timeout. No database
+	// operation should take longer or we will quit it
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, c.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// lock the list so books added at the same time do not get the same
+	// position, the way MoveBook does
+	err = tx.QueryRowContext(ctx, `SELECT id FROM readinglists WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, book.ReadingListID).Scan(new(int64))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
 
+	// new books go to the end of the list
 	query := `
-	INSERT INTO readinglist_books (readinglist_id, book_id, status) 
-	VALUES ($1, $2, $3) 
-	RETURNING readinglist_id, version;
+	INSERT INTO readinglist_books (readinglist_id, book_id, status, completed_at, position) 
+	VALUES ($1, $2, $3, CASE WHEN $3 = 'completed' THEN NOW() END,
+		(SELECT COALESCE(MAX(position) + 1, 0) FROM readinglist_books WHERE readinglist_id = $1)) 
+	RETURNING readinglist_id, position, version;
 `
 	args := []any{book.ReadingListID, book.BookID, book.Status}
 
-	// Create a context with a 3-second timeout. No database
-	// operation should take more than 3 seconds or we will quit it
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
 	// execute the query against the comments database table. We ask for the the
 	// id, created_at, and version to be sent back to us which we will use
 	// to update the Comment struct later on
-	return c.DB.QueryRowContext(ctx, query, args...).Scan(
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
 		&book.ReadingListID,
+		&book.Position,
 		&book.Version)
+	if err != nil {
+		if strings.Contains(err.Error(), "readinglist_books_pkey") {
+			return ErrDuplicateBookInList
+		}
+		return err
+	}
+	return tx.Commit()
 }
 
-func (c *ReadingListModel) RemoveBookFromList(listID, bookID int) error {
+func (c *ReadingListModel) RemoveBookFromList(ctx context.Context, listID, bookID int) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, c.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
 	query := `
 	DELETE FROM readinglist_books
 	WHERE readinglist_id = $1 AND book_id = $2
+	RETURNING position
 	`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
 	//excecute the query
-	result, err := c.DB.ExecContext(ctx, query, listID, bookID)
+	var position int
+	err = tx.QueryRowContext(ctx, query, listID, bookID).Scan(&position)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound //no rows affected
+		}
 		return err
 	}
 
-	//check if any rows affected
-	rowsAffected, err := result.RowsAffected()
+	// close the gap left behind by the removed book
+	query = `
+	UPDATE readinglist_books
+	SET position = position - 1
+	WHERE readinglist_id = $1 AND position > $2
+	`
+	_, err = tx.ExecContext(ctx, query, listID, position)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return ErrRecordNotFound //no rows affected
-	}
-	return nil
+	return tx.Commit()
 }
 
-func (b *ReadingListModel) ReadingListExist(id int64) error {
+func (b *ReadingListModel) ReadingListExist(ctx context.Context, id int64) error {
 
 	if id < 1 {
 		return ErrRecordNotFound
@@ -285,12 +346,349 @@ func (b *ReadingListModel) ReadingListExist(id int64) error {
 	query := `
 	SELECT id 
 	FROM readinglists
-	WHERE id = $1`
+	WHERE id = $1 AND deleted_at IS NULL`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, b.Timeout)
 	defer cancel()
 
 	var ID int64
 
 	return b.DB.QueryRowContext(ctx, query, id).Scan(&ID)
 }
+
+// GetBooks returns the books of a list in their manual order.
+func (c ReadingListModel) GetBooks(ctx context.Context, listID int64) ([]*BooksInList, error) {
+	query := `
+	SELECT rb.readinglist_id, rb.book_id, rb.status, rb.position, rb.version
+	FROM readinglist_books rb
+	INNER JOIN books b ON b.id = rb.book_id AND b.deleted_at IS NULL
+	WHERE rb.readinglist_id = $1
+	ORDER BY rb.position ASC
+	`
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	books := []*BooksInList{}
+	for rows.Next() {
+		var book BooksInList
+		err := rows.Scan(
+			&book.ReadingListID,
+			&book.BookID,
+			&book.Status,
+			&book.Position,
+			&book.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, &book)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+// MoveBook moves a book to a new index in the list and shifts the books in
+// between by one. The position constraint is deferred so the shifting never
+// trips over itself before the transaction commits.
+func (c ReadingListModel) MoveBook(ctx context.Context, listID, bookID int64, newPosition int) (*BooksInList, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, c.DB)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// lock the list so concurrent reorders are applied one after the other
+	var count int
+	err = tx.QueryRowContext(ctx, `SELECT id FROM readinglists WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, listID).Scan(new(int64))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM readinglist_books WHERE readinglist_id = $1`, listID).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+
+	book := &BooksInList{ReadingListID: listID, BookID: bookID}
+	query := `
+	SELECT status, position, version
+	FROM readinglist_books
+	WHERE readinglist_id = $1 AND book_id = $2
+	`
+	err = tx.QueryRowContext(ctx, query, listID, bookID).Scan(&book.Status, &book.Position, &book.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	// an index past the end simply moves the book to the end
+	if newPosition > count-1 {
+		newPosition = count - 1
+	}
+	if newPosition == book.Position {
+		return book, tx.Commit()
+	}
+
+	if newPosition < book.Position {
+		query = `
+		UPDATE readinglist_books SET position = position + 1
+		WHERE readinglist_id = $1 AND position >= $2 AND position < $3
+		`
+	} else {
+		query = `
+		UPDATE readinglist_books SET position = position - 1
+		WHERE readinglist_id = $1 AND position <= $2 AND position > $3
+		`
+	}
+	_, err = tx.ExecContext(ctx, query, listID, newPosition, book.Position)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+	UPDATE readinglist_books SET position = $1, version = version + 1
+	WHERE readinglist_id = $2 AND book_id = $3
+	RETURNING position, version
+	`
+	err = tx.QueryRowContext(ctx, query, newPosition, listID, bookID).Scan(&book.Position, &book.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	return book, tx.Commit()
+}
+
+// CanEdit reports whether the user owns the list or collaborates on it with
+// edit rights.
+func (c ReadingListModel) CanEdit(ctx context.Context, listID, userID int64) (bool, error) {
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM readinglists WHERE id = $1 AND created_by = $2
+		UNION
+		SELECT 1 FROM readinglist_collaborators WHERE readinglist_id = $1 AND user_id = $2 AND can_edit
+	)
+	`
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	var canEdit bool
+	err := c.DB.QueryRowContext(ctx, query, listID, userID).Scan(&canEdit)
+	return canEdit, err
+}
+
+// CanView reports whether the user may read the list: it is public, theirs,
+// or shared with them.
+func (c ReadingListModel) CanView(ctx context.Context, listID, userID int64) (bool, error) {
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM readinglists WHERE id = $1 AND (is_public OR created_by = $2)
+		UNION
+		SELECT 1 FROM readinglist_collaborators WHERE readinglist_id = $1 AND user_id = $2
+	)
+	`
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	var canView bool
+	err := c.DB.QueryRowContext(ctx, query, listID, userID).Scan(&canView)
+	return canView, err
+}
+
+func (c ReadingListModel) AddCollaborator(ctx context.Context, collaborator *Collaborator) error {
+	query := `
+	INSERT INTO readinglist_collaborators (readinglist_id, user_id, can_edit)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (readinglist_id, user_id) DO UPDATE SET can_edit = EXCLUDED.can_edit
+	RETURNING added_at, (SELECT username FROM users WHERE id = $2)
+	`
+	args := []any{collaborator.ReadingListID, collaborator.UserID, collaborator.CanEdit}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	return c.DB.QueryRowContext(ctx, query, args...).Scan(&collaborator.AddedAt, &collaborator.Username)
+}
+
+func (c ReadingListModel) RemoveCollaborator(ctx context.Context, listID, userID int64) error {
+	query := `
+	DELETE FROM readinglist_collaborators
+	WHERE readinglist_id = $1 AND user_id = $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	result, err := c.DB.ExecContext(ctx, query, listID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (c ReadingListModel) GetCollaborators(ctx context.Context, listID int64) ([]*Collaborator, error) {
+	query := `
+	SELECT rc.readinglist_id, rc.user_id, u.username, rc.can_edit, rc.added_at
+	FROM readinglist_collaborators rc
+	INNER JOIN users u ON u.id = rc.user_id
+	WHERE rc.readinglist_id = $1
+	ORDER BY rc.added_at ASC
+	`
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collaborators := []*Collaborator{}
+	for rows.Next() {
+		var collaborator Collaborator
+		err := rows.Scan(
+			&collaborator.ReadingListID,
+			&collaborator.UserID,
+			&collaborator.Username,
+			&collaborator.CanEdit,
+			&collaborator.AddedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		collaborators = append(collaborators, &collaborator)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return collaborators, nil
+}
+
+// Clone copies the books of the source list into the new list, keeping their
+// order. The copied books start out as 'to read' for the new owner.
+func (c ReadingListModel) Clone(ctx context.Context, sourceID int64, list *ReadingList) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, c.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO readinglists (name, description, created_by, is_public)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, version
+	`
+	args := []any{list.Name, list.Description, list.CreatedBy, list.IsPublic}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&list.ID, &list.Version)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO readinglist_books (readinglist_id, book_id, status, position)
+		SELECT $1, rb.book_id, 'to read', rb.position
+		FROM readinglist_books rb
+		INNER JOIN books b ON b.id = rb.book_id AND b.deleted_at IS NULL
+		WHERE rb.readinglist_id = $2
+	`
+	_, err = tx.ExecContext(ctx, query, list.ID, sourceID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListedBook is a book together with its place and status in a list.
+type ListedBook struct {
+	Book
+	Status   string `json:"status"`
+	Position int    `json:"position"`
+}
+
+// GetBookDetails returns the full book records of a list in their manual order.
+func (c ReadingListModel) GetBookDetails(ctx context.Context, listID int64) ([]*ListedBook, error) {
+	query := `
+	SELECT b.id, b.title, b.authors, b.isbn, b.publication_date, b.genre, b.description,
+	       b.average_rating, b.version, COALESCE(b.work_id, 0), b.format, b.publisher, b.language,
+	       b.page_count, b.cover_key, b.rating_count, b.rating_distribution, b.weighted_rating,
+	       rb.status, rb.position
+	FROM readinglist_books rb
+	INNER JOIN books b ON b.id = rb.book_id AND b.deleted_at IS NULL
+	WHERE rb.readinglist_id = $1
+	ORDER BY rb.position ASC
+	`
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	books := []*ListedBook{}
+	for rows.Next() {
+		var book ListedBook
+		err := rows.Scan(
+			&book.ID,
+			&book.Title,
+			&book.Authors,
+			&book.ISBN,
+			&book.PublicationDate,
+			&book.Genre,
+			&book.Description,
+			&book.AverageRating,
+			&book.Version,
+			&book.WorkID,
+			&book.Format,
+			&book.Publisher,
+			&book.Language,
+			&book.PageCount,
+			&book.Cover,
+			&book.RatingCount,
+			&book.RatingDistribution,
+			&book.WeightedRating,
+			&book.Status,
+			&book.Position,
+		)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, &book)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
@@ -0,0 +1,191 @@
+// Filename: internal/data/recommendations.go
+package data
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// MinSharedReaders is how many users must have read two books before
+	// they count as similar; with fewer a single reader decides it.
+	MinSharedReaders = 2
+	// similarBooksKept is how many similar books are stored per book.
+	similarBooksKept = 50
+)
+
+// bookInteractions is how strongly each user is linked to each book. A rating
+// says how much they liked it, a book on one of their lists that they never
+// rated counts as a three star rating.
+const bookInteractions = `
+	SELECT user_id, book_id, COALESCE(AVG(rating) / 5.0, 0.6) AS weight
+	FROM (
+		SELECT rl.created_by AS user_id, rb.book_id, NULL::float8 AS rating
+		FROM readinglist_books rb
+		INNER JOIN readinglists rl ON rl.id = rb.readinglist_id
+		WHERE rl.created_by IS NOT NULL AND rb.book_id IS NOT NULL AND rl.deleted_at IS NULL
+		UNION ALL
+		SELECT user_id, book_id, rating
+		FROM bookreviews
+		WHERE user_id IS NOT NULL AND book_id IS NOT NULL AND rating IS NOT NULL AND deleted_at IS NULL
+	) signals
+	GROUP BY user_id, book_id`
+
+// RecommendedBook is a book suggested to a reader, with how well it matches.
+type RecommendedBook struct {
+	Book          *Book   `json:"book"`
+	Score         float64 `json:"score"`
+	SharedReaders int     `json:"shared_readers,omitempty"`
+}
+
+type RecommendationModel struct {
+	DB      DBTX
+	Timeout time.Duration // how long a single query may run
+}
+
+// RefreshSimilarities recomputes the similarity of every pair of books as the
+// cosine of their interaction vectors over all users, keeping the closest
+// books for each. The old scores stay visible until the new ones are in.
+func (m RecommendationModel) RefreshSimilarities(ctx context.Context) (int64, error) {
+	// this reads every rating and list entry, so it gets more than the
+	// usual query timeout
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM book_similarities`)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		WITH interactions AS (` + bookInteractions + `),
+		norms AS (
+			SELECT book_id, SQRT(SUM(weight * weight)) AS norm
+			FROM interactions
+			GROUP BY book_id
+		),
+		pairs AS (
+			SELECT a.book_id, b.book_id AS similar_book_id, SUM(a.weight * b.weight) AS dot, COUNT(*) AS shared_readers
+			FROM interactions a
+			INNER JOIN interactions b ON b.user_id = a.user_id AND b.book_id <> a.book_id
+			GROUP BY a.book_id, b.book_id
+			HAVING COUNT(*) >= $1
+		),
+		ranked AS (
+			SELECT p.book_id, p.similar_book_id, p.dot / (na.norm * nb.norm) AS score, p.shared_readers,
+			       ROW_NUMBER() OVER (PARTITION BY p.book_id ORDER BY p.dot / (na.norm * nb.norm) DESC, p.similar_book_id) AS rank
+			FROM pairs p
+			INNER JOIN norms na ON na.book_id = p.book_id
+			INNER JOIN norms nb ON nb.book_id = p.similar_book_id
+		)
+		INSERT INTO book_similarities (book_id, similar_book_id, score, shared_readers)
+		SELECT book_id, similar_book_id, score, shared_readers
+		FROM ranked
+		WHERE rank <= $2
+	`
+	result, err := tx.ExecContext(ctx, query, MinSharedReaders, similarBooksKept)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, tx.Commit()
+}
+
+// Similar returns the books most often read together with the given book.
+func (m RecommendationModel) Similar(ctx context.Context, bookID int64, limit int) ([]*RecommendedBook, error) {
+	query := `
+		SELECT s.score, s.shared_readers,
+		       b.id, b.title, b.authors, b.isbn, b.publication_date, b.genre, b.description, b.average_rating, b.version,
+		       COALESCE(b.work_id, 0), b.format, b.publisher, b.language, b.page_count, b.cover_key,
+		       b.rating_count, b.rating_distribution, b.weighted_rating
+		FROM book_similarities s
+		INNER JOIN books b ON b.id = s.similar_book_id AND b.deleted_at IS NULL
+		WHERE s.book_id = $1
+		ORDER BY s.score DESC, b.id ASC
+		LIMIT $2
+	`
+	return m.queryRecommendedBooks(ctx, query, bookID, limit)
+}
+
+// ForUser recommends books similar to the ones the user read, weighted by how
+// much they liked them. Books that are on one of their lists or that they
+// reviewed are left out since they already know them.
+func (m RecommendationModel) ForUser(ctx context.Context, userID int64, limit int) ([]*RecommendedBook, error) {
+	query := `
+		WITH mine AS (
+			SELECT * FROM (` + bookInteractions + `) interactions
+			WHERE user_id = $1
+		)
+		SELECT SUM(mine.weight * s.score) / (SELECT SUM(weight) FROM mine) AS score, 0,
+		       b.id, b.title, b.authors, b.isbn, b.publication_date, b.genre, b.description, b.average_rating, b.version,
+		       COALESCE(b.work_id, 0), b.format, b.publisher, b.language, b.page_count, b.cover_key,
+		       b.rating_count, b.rating_distribution, b.weighted_rating
+		FROM mine
+		INNER JOIN book_similarities s ON s.book_id = mine.book_id
+		INNER JOIN books b ON b.id = s.similar_book_id AND b.deleted_at IS NULL
+		WHERE NOT EXISTS (SELECT 1 FROM mine WHERE mine.book_id = s.similar_book_id)
+		GROUP BY b.id
+		ORDER BY score DESC, b.id ASC
+		LIMIT $2
+	`
+	return m.queryRecommendedBooks(ctx, query, userID, limit)
+}
+
+func (m RecommendationModel) queryRecommendedBooks(ctx context.Context, query string, id int64, limit int) ([]*RecommendedBook, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	books := []*RecommendedBook{}
+	for rows.Next() {
+		var book Book
+		var recommended RecommendedBook
+		err := rows.Scan(
+			&recommended.Score,
+			&recommended.SharedReaders,
+			&book.ID,
+			&book.Title,
+			&book.Authors,
+			&book.ISBN,
+			&book.PublicationDate,
+			&book.Genre,
+			&book.Description,
+			&book.AverageRating,
+			&book.Version,
+			&book.WorkID,
+			&book.Format,
+			&book.Publisher,
+			&book.Language,
+			&book.PageCount,
+			&book.Cover,
+			&book.RatingCount,
+			&book.RatingDistribution,
+			&book.WeightedRating,
+		)
+		if err != nil {
+			return nil, err
+		}
+		recommended.Book = &book
+		books = append(books, &recommended)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
@@ -0,0 +1,103 @@
+// Filename: internal/data/replica.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+)
+
+// replicaRetry is how long reads stay on the primary after the replica
+// failed one, so a replica that is down does not slow every read.
+const replicaRetry = 10 * time.Second
+
+// ReplicaDB sends the queries of Get, GetAll and Search to a read replica,
+// for the read-only requests marked by AllowReplicaReads. A query the
+// replica fails is run on the primary instead. Statements that change data
+// always go to the primary.
+type ReplicaDB struct {
+	primary   *sql.DB
+	replica   *sql.DB
+	downUntil atomic.Int64 // unix nanoseconds until which the replica is skipped
+	fallbacks atomic.Int64 // queries the replica failed and the primary ran
+}
+
+type replicaReadsContextKey struct{}
+
+// AllowReplicaReads marks ctx as that of a request that only shows data, so
+// its reads may be served by the replica. Requests that read a record to
+// change it must not be marked: the replica may lag behind, and they would
+// see a stale version of what they just wrote.
+func AllowReplicaReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaReadsContextKey{}, true)
+}
+
+// readFrom returns replica for a request marked by AllowReplicaReads and db
+// for any other.
+func readFrom(ctx context.Context, db, replica DBTX) DBTX {
+	if allowed, _ := ctx.Value(replicaReadsContextKey{}).(bool); allowed && replica != nil {
+		return replica
+	}
+	return db
+}
+
+func NewReplicaDB(primary, replica *sql.DB) *ReplicaDB {
+	return &ReplicaDB{primary: primary, replica: replica}
+}
+
+// ReplicaStats are the replica's connection pool statistics, along with how
+// many queries fell back to the primary.
+type ReplicaStats struct {
+	sql.DBStats
+	Fallbacks int64
+}
+
+func (r *ReplicaDB) Stats() ReplicaStats {
+	return ReplicaStats{DBStats: r.replica.Stats(), Fallbacks: r.fallbacks.Load()}
+}
+
+// useReplica reports whether the replica is not sitting out a failure.
+func (r *ReplicaDB) useReplica() bool {
+	return time.Now().UnixNano() >= r.downUntil.Load()
+}
+
+// failed takes the replica out of use for a while, unless the query only
+// failed because the caller gave up on it.
+func (r *ReplicaDB) failed(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	r.downUntil.Store(time.Now().Add(replicaRetry).UnixNano())
+	r.fallbacks.Add(1)
+	return true
+}
+
+func (r *ReplicaDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if r.useReplica() {
+		rows, err := r.replica.QueryContext(ctx, query, args...)
+		if err == nil || !r.failed(ctx) {
+			return rows, err
+		}
+	}
+	return r.primary.QueryContext(ctx, query, args...)
+}
+
+func (r *ReplicaDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if r.useReplica() {
+		row := r.replica.QueryRowContext(ctx, query, args...)
+		// Err is only set when the query itself failed, not for sql.ErrNoRows
+		if row.Err() == nil || !r.failed(ctx) {
+			return row
+		}
+	}
+	return r.primary.QueryRowContext(ctx, query, args...)
+}
+
+func (r *ReplicaDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return r.primary.ExecContext(ctx, query, args...)
+}
+
+func (r *ReplicaDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return r.primary.PrepareContext(ctx, query)
+}
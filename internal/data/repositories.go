@@ -0,0 +1,239 @@
+// Filename: internal/data/repositories.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// The handlers only talk to the models through these interfaces, so the
+// PostgreSQL models can be swapped for the in-memory ones (see memory.go).
+
+// BookRepository stores the catalog of books.
+type BookRepository interface {
+	Insert(ctx context.Context, book *Book) error
+	Get(ctx context.Context, id int64) (*Book, error)
+	Update(ctx context.Context, book *Book) error
+	Delete(ctx context.Context, id int64) error
+	GetAll(ctx context.Context, tag string, userID int64, filters Filters) ([]*Book, Metadata, error)
+	Search(ctx context.Context, title string, author string, genre string, filters Filters) ([]*Book, Metadata, error)
+	BookExists(ctx context.Context, id int64) (bool, error)
+	SetCover(ctx context.Context, bookID int64, cover CoverImage) (CoverImage, error)
+	FindDuplicates(ctx context.Context, minSimilarity float64, filters Filters) ([]*DuplicateCandidate, Metadata, error)
+	Merge(ctx context.Context, canonicalID, duplicateID, mergedBy int64) error
+	GetRedirect(ctx context.Context, oldID int64) (int64, error)
+	NewImport(ctx context.Context, atomic bool) (BookImporter, error)
+	RefreshWeightedRatings(ctx context.Context) (int64, error)
+}
+
+// BookImporter inserts the books of a bulk import in batches. The audit
+// function, if given, returns the audit event for a book that was inserted,
+// which is stored in the same transaction as the book.
+type BookImporter interface {
+	ExistingISBNs(isbns []string) (map[string]bool, error)
+	InsertBatch(books []*Book, audit func(*Book) (*AuditEvent, error)) error
+	Commit() error
+	Rollback()
+}
+
+// WorkRepository reads works and their editions.
+type WorkRepository interface {
+	Get(ctx context.Context, id int64) (*Work, error)
+	Exists(ctx context.Context, id int64) (bool, error)
+}
+
+// ReadingListRepository stores reading lists, the books on them and who
+// they are shared with.
+type ReadingListRepository interface {
+	Insert(ctx context.Context, list *ReadingList) error
+	Get(ctx context.Context, id int64) (*ReadingList, error)
+	Update(ctx context.Context, list *ReadingList) error
+	Delete(ctx context.Context, id int64) error
+	GetAll(ctx context.Context, name string, filters Filters) ([]*ReadingList, Metadata, error)
+	AddBookToList(ctx context.Context, book *BooksInList) error
+	RemoveBookFromList(ctx context.Context, listID, bookID int) error
+	GetBooks(ctx context.Context, listID int64) ([]*BooksInList, error)
+	GetBookDetails(ctx context.Context, listID int64) ([]*ListedBook, error)
+	MoveBook(ctx context.Context, listID, bookID int64, newPosition int) (*BooksInList, error)
+	CanEdit(ctx context.Context, listID, userID int64) (bool, error)
+	CanView(ctx context.Context, listID, userID int64) (bool, error)
+	AddCollaborator(ctx context.Context, collaborator *Collaborator) error
+	RemoveCollaborator(ctx context.Context, listID, userID int64) error
+	GetCollaborators(ctx context.Context, listID int64) ([]*Collaborator, error)
+	Clone(ctx context.Context, sourceID int64, list *ReadingList) error
+}
+
+// ReviewRepository stores book reviews.
+type ReviewRepository interface {
+	InsertReview(ctx context.Context, review *Review) error
+	GetReview(ctx context.Context, id int64) (*Review, error)
+	GetAllBookReviews(ctx context.Context, bookID int64) ([]*Review, error)
+	GetAllWorkReviews(ctx context.Context, workID int64) ([]*Review, error)
+	UpdateReview(ctx context.Context, review *Review) error
+	DeleteReview(ctx context.Context, id int64) error
+}
+
+// UserRepository stores user accounts.
+type UserRepository interface {
+	Insert(ctx context.Context, user *User) error
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetByID(ctx context.Context, id int64) (*User, error)
+	GetForToken(ctx context.Context, tokenScope, tokenPlaintext string) (*User, error)
+	Update(ctx context.Context, user *User) error
+	GetUserReviews(ctx context.Context, userID int64) ([]UserReview, error)
+	GetUserLists(ctx context.Context, userID int64) ([]UserList, error)
+}
+
+// TokenRepository stores activation and authentication tokens.
+type TokenRepository interface {
+	New(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error)
+	Insert(ctx context.Context, token *Token) error
+	DeleteAllForUser(ctx context.Context, scope string, userID int64) error
+}
+
+// PermissionRepository stores the permissions granted to users.
+type PermissionRepository interface {
+	GetAllForUser(ctx context.Context, userID int64) (Permissions, error)
+	AddForUser(ctx context.Context, userID int64, codes ...string) error
+}
+
+// StatsRepository stores reading progress and goals and sums them up.
+type StatsRepository interface {
+	InsertProgress(ctx context.Context, progress *ReadingProgress) error
+	UpsertGoal(ctx context.Context, goal *ReadingGoal) error
+	GetGoal(ctx context.Context, userID int64, year int) (*ReadingGoal, error)
+	GetForUser(ctx context.Context, userID int64, year int) (*ReadingStats, error)
+}
+
+// TagRepository stores the tags users put on books.
+type TagRepository interface {
+	Insert(ctx context.Context, tag *Tag) error
+	Delete(ctx context.Context, userID, bookID int64, name string) error
+	GetAllForUser(ctx context.Context, userID int64) ([]TagCount, error)
+	GetForBook(ctx context.Context, userID, bookID int64) ([]*Tag, error)
+	GetPopularForBook(ctx context.Context, bookID int64, limit int) ([]TagCount, error)
+}
+
+// ShareRepository stores the read-only links to reading lists.
+type ShareRepository interface {
+	New(ctx context.Context, listID, userID int64, ttl time.Duration) (*ShareLink, error)
+	GetListForToken(ctx context.Context, tokenPlaintext string) (*ReadingList, error)
+	GetAllForList(ctx context.Context, listID int64) ([]*ShareLink, error)
+	Revoke(ctx context.Context, id, listID int64) error
+}
+
+// SeriesRepository stores series and the reading order of their books.
+type SeriesRepository interface {
+	Insert(ctx context.Context, series *Series) error
+	Get(ctx context.Context, id int64) (*Series, error)
+	AddBook(ctx context.Context, book *SeriesBook) error
+	RemoveBook(ctx context.Context, seriesID, bookID int64) error
+	GetForBook(ctx context.Context, bookID int64) ([]*SeriesEntry, error)
+	NextInSeries(ctx context.Context, bookID, userID int64) ([]*SeriesBook, error)
+}
+
+// ImportJobRepository stores background Goodreads imports and carries them out.
+type ImportJobRepository interface {
+	Insert(ctx context.Context, job *ImportJob) error
+	Get(ctx context.Context, id int64) (*ImportJob, error)
+	UpdateProgress(ctx context.Context, job *ImportJob) error
+	EnsureGoodreadsList(ctx context.Context, userID int64, shelf string) (*ReadingList, bool, error)
+	ImportGoodreadsEntry(ctx context.Context, userID int64, entry *GoodreadsEntry, listIDs []int64) (*GoodreadsResult, error)
+}
+
+// ExportRepository gathers personal data exports and keeps the archives.
+type ExportRepository interface {
+	Size(ctx context.Context, userID int64) (int, error)
+	GetForUser(ctx context.Context, userID int64) (*UserExport, error)
+	SaveArchive(ctx context.Context, userID int64, format string, archive []byte, ttl time.Duration) (*Token, error)
+	GetArchive(ctx context.Context, tokenPlaintext string) (*ExportArchive, error)
+}
+
+// ChangeRequestRepository stores proposed book edits.
+type ChangeRequestRepository interface {
+	Insert(ctx context.Context, cr *ChangeRequest) error
+	Get(ctx context.Context, id int64) (*ChangeRequest, error)
+	GetAll(ctx context.Context, status string, bookID int64, filters Filters) ([]*ChangeRequest, Metadata, error)
+	Resolve(ctx context.Context, cr *ChangeRequest) error
+}
+
+// RecommendationRepository computes book similarities and recommendations.
+type RecommendationRepository interface {
+	RefreshSimilarities(ctx context.Context) (int64, error)
+	Similar(ctx context.Context, bookID int64, limit int) ([]*RecommendedBook, error)
+	ForUser(ctx context.Context, userID int64, limit int) ([]*RecommendedBook, error)
+}
+
+// TrashRepository lists, restores and purges deleted books, lists and reviews.
+type TrashRepository interface {
+	GetForUser(ctx context.Context, userID int64, includeBooks bool) (*Trash, error)
+	RestoreBook(ctx context.Context, id int64) error
+	RestoreReadingList(ctx context.Context, id, ownerID int64) error
+	RestoreReview(ctx context.Context, id, ownerID int64) error
+	Purge(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// AuditRepository stores the append-only log of changes made through the API.
+type AuditRepository interface {
+	Insert(ctx context.Context, event *AuditEvent) error
+	GetAll(ctx context.Context, resource string, resourceID int64, filters Filters) ([]*AuditEvent, Metadata, error)
+}
+
+// Models holds one repository of each kind, all backed by the same store.
+type Models struct {
+	Books           BookRepository
+	Works           WorkRepository
+	ReadingLists    ReadingListRepository
+	Reviews         ReviewRepository
+	Users           UserRepository
+	Tokens          TokenRepository
+	Permissions     PermissionRepository
+	Stats           StatsRepository
+	Tags            TagRepository
+	Shares          ShareRepository
+	Series          SeriesRepository
+	ImportJobs      ImportJobRepository
+	Exports         ExportRepository
+	ChangeRequests  ChangeRequestRepository
+	Recommendations RecommendationRepository
+	Trash           TrashRepository
+	Audit           AuditRepository
+
+	// Transactor runs units of work across the repositories above
+	Transactor Transactor
+}
+
+// NewModels returns the repositories backed by PostgreSQL. Each query they
+// run is cancelled after timeout, or earlier if its context is. The reads of
+// requests marked by AllowReplicaReads run on reader, which is db itself or
+// a ReplicaDB.
+func NewModels(db *sql.DB, reader DBTX, timeout time.Duration) Models {
+	return newModels(db, reader, timeout, pgTransactor{db: db, timeout: timeout})
+}
+
+// newModels returns the PostgreSQL repositories running their statements on
+// db, which is the pool or the transaction of a unit of work, and the reads
+// of read-only requests on reader.
+func newModels(db, reader DBTX, timeout time.Duration, transactor Transactor) Models {
+	return Models{
+		Books:           &BookModel{DB: db, Reader: reader, Timeout: timeout},
+		Works:           &WorkModel{DB: db, Reader: reader, Timeout: timeout},
+		ReadingLists:    &ReadingListModel{DB: db, Reader: reader, Timeout: timeout},
+		Reviews:         &ReviewModel{DB: db, Reader: reader, Timeout: timeout},
+		Users:           &UserModel{DB: db, Timeout: timeout},
+		Tokens:          &TokenModel{DB: db, Timeout: timeout},
+		Permissions:     &PermissionModel{DB: db, Timeout: timeout},
+		Stats:           &StatsModel{DB: db, Timeout: timeout},
+		Tags:            &TagModel{DB: db, Timeout: timeout},
+		Shares:          &ShareModel{DB: db, Timeout: timeout},
+		Series:          &SeriesModel{DB: db, Reader: reader, Timeout: timeout},
+		ImportJobs:      &ImportJobModel{DB: db, Timeout: timeout},
+		Exports:         &ExportModel{DB: db, Timeout: timeout},
+		ChangeRequests:  &ChangeRequestModel{DB: db, Reader: reader, Timeout: timeout},
+		Recommendations: &RecommendationModel{DB: db, Timeout: timeout},
+		Trash:           &TrashModel{DB: db, Timeout: timeout},
+		Audit:           &AuditModel{DB: db, Reader: reader, Timeout: timeout},
+		Transactor:      transactor,
+	}
+}
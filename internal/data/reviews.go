@@ -19,10 +19,14 @@ type Review struct {
 	ReviewText string    `json:"review"`
 	ReviewDate time.Time `json:"-"`
 	Version    int       `json:"version"`
+	// Set while the review is in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 type ReviewModel struct {
-	DB *sql.DB
+	DB      DBTX
+	Reader  DBTX          // the read replica, for requests marked by AllowReplicaReads
+	Timeout time.Duration // how long a single query may run
 }
 
 func ValidateReview(v *validator.Validator, review *Review) {
@@ -34,7 +38,7 @@ func ValidateReview(v *validator.Validator, review *Review) {
 	v.Check(review.Rating >= 1 && review.Rating <= 5, "rating", "must be between 1 and 5")
 }
 
-func (c ReviewModel) InsertReview(review *Review) error {
+func (c ReviewModel) InsertReview(ctx context.Context, review *Review) error {
 	query := `
 		INSERT INTO bookreviews (book_id, user_id, rating, review)
 		VALUES ($1, $2, $3, $4)
@@ -42,7 +46,7 @@ func (c ReviewModel) InsertReview(review *Review) error {
 	`
 	args := []any{review.BookID, review.UserID, review.Rating, review.ReviewText}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 	defer cancel()
 
 	return c.DB.QueryRowContext(ctx, query, args...).Scan(
@@ -50,21 +54,21 @@ func (c ReviewModel) InsertReview(review *Review) error {
 		&review.ReviewDate,
 		&review.Version)
 }
-func (c ReviewModel) GetReview(id int64) (*Review, error) {
+func (c ReviewModel) GetReview(ctx context.Context, id int64) (*Review, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 	query := `
 		SELECT  id, book_id, user_id, rating, review, review_date, version
 		FROM bookreviews
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 	var review Review
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 	defer cancel()
 
-	err := c.DB.QueryRowContext(ctx, query, id).Scan(
+	err := readFrom(ctx, c.DB, c.Reader).QueryRowContext(ctx, query, id).Scan(
 		&review.ReviewID,
 		&review.BookID,
 		&review.UserID,
@@ -82,7 +86,7 @@ func (c ReviewModel) GetReview(id int64) (*Review, error) {
 	return &review, nil
 }
 
-func (c ReviewModel) GetAllBookReviews(bookID int64) ([]*Review, error) {
+func (c ReviewModel) GetAllBookReviews(ctx context.Context, bookID int64) ([]*Review, error) {
 	if bookID < 1 {
 		return nil, ErrRecordNotFound
 	}
@@ -90,13 +94,13 @@ func (c ReviewModel) GetAllBookReviews(bookID int64) ([]*Review, error) {
 	query := `
 		SELECT id, book_id, user_id, rating, review, review_date, version
 		FROM bookreviews
-		WHERE book_id = $1
+		WHERE book_id = $1 AND deleted_at IS NULL
 		ORDER BY review_date DESC
 	`
 
 	var reviews []*Review
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 	defer cancel()
 
 	rows, err := c.DB.QueryContext(ctx, query, bookID)
@@ -129,32 +133,93 @@ func (c ReviewModel) GetAllBookReviews(bookID int64) ([]*Review, error) {
 	return reviews, nil
 }
 
-func (c ReviewModel) UpdateReview(review *Review) error {
+// GetAllWorkReviews returns the reviews of every edition of a work.
+func (c ReviewModel) GetAllWorkReviews(ctx context.Context, workID int64) ([]*Review, error) {
+	if workID < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT r.id, r.book_id, r.user_id, r.rating, r.review, r.review_date, r.version
+		FROM bookreviews r
+		INNER JOIN books b ON b.id = r.book_id AND b.deleted_at IS NULL
+		WHERE b.work_id = $1 AND r.deleted_at IS NULL
+		ORDER BY r.review_date DESC
+	`
+
+	var reviews []*Review
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, workID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var review Review
+		err := rows.Scan(
+			&review.ReviewID,
+			&review.BookID,
+			&review.UserID,
+			&review.Rating,
+			&review.ReviewText,
+			&review.ReviewDate,
+			&review.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, &review)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+func (c ReviewModel) UpdateReview(ctx context.Context, review *Review) error {
 	query := `
 		UPDATE bookreviews
 		SET  rating = $1, review = $2, version = version + 1
-		WHERE id = $3
+		WHERE id = $3 AND version = $4 AND deleted_at IS NULL
 		RETURNING version
 	`
 
-	args := []any{review.Rating, review.ReviewText, review.ReviewID}
+	args := []any{review.Rating, review.ReviewText, review.ReviewID, review.Version}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 	defer cancel()
 
-	return c.DB.QueryRowContext(ctx, query, args...).Scan(&review.Version)
+	// no row means the review was changed (or deleted) since it was read
+	err := c.DB.QueryRowContext(ctx, query, args...).Scan(&review.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
 }
 
-func (c ReviewModel) DeleteReview(id int64) error {
+func (c ReviewModel) DeleteReview(ctx context.Context, id int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
+	// the review only goes to the trash, the purge job removes it for good
 	query := `
-		DELETE FROM bookreviews
-		WHERE id = $1
+		UPDATE bookreviews
+		SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 	defer cancel()
 
 	result, err := c.DB.ExecContext(ctx, query, id)
@@ -173,20 +238,28 @@ func (c ReviewModel) DeleteReview(id int64) error {
 	return nil
 }
 
-func (m *BookModel) BookExists(productID int64) (bool, error) {
-	query := `SELECT EXISTS (SELECT 1 FROM books WHERE id = $1)`
+func (m *BookModel) BookExists(ctx context.Context, productID int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM books WHERE id = $1 AND deleted_at IS NULL)`
 	var exists bool
-	err := m.DB.QueryRow(query, productID).Scan(&exists)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, productID).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
 	return exists, nil
 }
 
-func (m *ReviewModel) Exists(id int64) (bool, error) {
+func (m *ReviewModel) Exists(ctx context.Context, id int64) (bool, error) {
 	var exists bool
-	query := `SELECT EXISTS(SELECT 1 FROM bookreviews WHERE id = $1)`
-	err := m.DB.QueryRow(query, id).Scan(&exists)
+	query := `SELECT EXISTS(SELECT 1 FROM bookreviews WHERE id = $1 AND deleted_at IS NULL)`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
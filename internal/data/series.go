@@ -0,0 +1,243 @@
+// Filename: internal/data/series.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Duane-Arzu/test-1.git/internal/validator"
+)
+
+var ErrDuplicateSeriesPosition = errors.New("duplicate position in series")
+
+// Series is a named, ordered group of books.
+type Series struct {
+	ID          int64         `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Books       []*SeriesBook `json:"books,omitempty"`
+	Version     int           `json:"version"`
+}
+
+// SeriesBook is a book and its place in the reading order of a series.
+type SeriesBook struct {
+	SeriesID int64   `json:"series_id"`
+	BookID   int64   `json:"book_id"`
+	Title    string  `json:"title"`
+	Authors  string  `json:"authors"`
+	Position float64 `json:"position"`
+}
+
+// SeriesEntry is the series information embedded in a Book.
+type SeriesEntry struct {
+	SeriesID int64   `json:"id"`
+	Name     string  `json:"name"`
+	Position float64 `json:"position"`
+}
+
+type SeriesModel struct {
+	DB      DBTX
+	Reader  DBTX          // the read replica, for requests marked by AllowReplicaReads
+	Timeout time.Duration // how long a single query may run
+}
+
+func ValidateSeries(v *validator.Validator, series *Series) {
+	v.Check(strings.TrimSpace(series.Name) != "", "name", "must be provided")
+	v.Check(len(series.Name) <= 255, "name", "must not be more than 255 bytes long")
+	v.Check(len(series.Description) <= 500, "description", "must not be more than 500 bytes long")
+}
+
+func ValidateSeriesBook(v *validator.Validator, book *SeriesBook) {
+	v.Check(book.BookID > 0, "book_id", "must be a positive integer")
+	v.Check(book.Position > 0, "position", "must be greater than zero")
+	v.Check(book.Position < 10000, "position", "must be less than 10000")
+	// positions are stored with two decimals, 2.5 is fine but 2.125 is not
+	v.Check(math.Abs(book.Position*100-math.Round(book.Position*100)) < 1e-9, "position", "must not have more than two decimal places")
+}
+
+func (m SeriesModel) Insert(ctx context.Context, series *Series) error {
+	query := `
+		INSERT INTO series (name, description)
+		VALUES ($1, $2)
+		RETURNING id, version
+	`
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, series.Name, series.Description).Scan(&series.ID, &series.Version)
+}
+
+// Get returns a series with its books in reading order.
+func (m SeriesModel) Get(ctx context.Context, id int64) (*Series, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+		SELECT id, name, description, version
+		FROM series
+		WHERE id = $1
+	`
+	var series Series
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := readFrom(ctx, m.DB, m.Reader).QueryRowContext(ctx, query, id).Scan(
+		&series.ID,
+		&series.Name,
+		&series.Description,
+		&series.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	query = `
+		SELECT sb.series_id, sb.book_id, b.title, b.authors, sb.position
+		FROM series_books sb
+		INNER JOIN books b ON b.id = sb.book_id AND b.deleted_at IS NULL
+		WHERE sb.series_id = $1
+		ORDER BY sb.position ASC
+	`
+	series.Books, err = m.getBooks(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &series, nil
+}
+
+// AddBook places a book in a series, or moves it if it is already there.
+func (m SeriesModel) AddBook(ctx context.Context, book *SeriesBook) error {
+	query := `
+		INSERT INTO series_books (series_id, book_id, position)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (series_id, book_id) DO UPDATE SET position = EXCLUDED.position
+		RETURNING (SELECT title FROM books WHERE id = $2), (SELECT authors FROM books WHERE id = $2)
+	`
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, book.SeriesID, book.BookID, book.Position).Scan(&book.Title, &book.Authors)
+	if err != nil {
+		if strings.Contains(err.Error(), "series_books_series_id_position_key") {
+			return ErrDuplicateSeriesPosition
+		}
+		return err
+	}
+	return nil
+}
+
+func (m SeriesModel) RemoveBook(ctx context.Context, seriesID, bookID int64) error {
+	query := `
+		DELETE FROM series_books
+		WHERE series_id = $1 AND book_id = $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, seriesID, bookID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetForBook returns every series a book belongs to.
+func (m SeriesModel) GetForBook(ctx context.Context, bookID int64) ([]*SeriesEntry, error) {
+	query := `
+		SELECT s.id, s.name, sb.position
+		FROM series_books sb
+		INNER JOIN series s ON s.id = sb.series_id
+		WHERE sb.book_id = $1
+		ORDER BY s.name ASC
+	`
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*SeriesEntry{}
+	for rows.Next() {
+		var entry SeriesEntry
+		err := rows.Scan(&entry.SeriesID, &entry.Name, &entry.Position)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// NextInSeries returns, for every series the book belongs to, the first book
+// after it that the user has not completed yet.
+func (m SeriesModel) NextInSeries(ctx context.Context, bookID, userID int64) ([]*SeriesBook, error) {
+	query := `
+		SELECT DISTINCT ON (sb.series_id) sb.series_id, sb.book_id, b.title, b.authors, sb.position
+		FROM series_books cur
+		INNER JOIN series_books sb ON sb.series_id = cur.series_id AND sb.position > cur.position
+		INNER JOIN books b ON b.id = sb.book_id AND b.deleted_at IS NULL
+		WHERE cur.book_id = $1
+		AND NOT EXISTS (
+			SELECT 1
+			FROM readinglist_books rb
+			INNER JOIN readinglists rl ON rl.id = rb.readinglist_id
+			WHERE rl.created_by = $2 AND rb.book_id = sb.book_id AND rb.status = 'completed' AND rl.deleted_at IS NULL
+		)
+		ORDER BY sb.series_id, sb.position ASC
+	`
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.getBooks(ctx, query, bookID, userID)
+}
+
+func (m SeriesModel) getBooks(ctx context.Context, query string, args ...any) ([]*SeriesBook, error) {
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	books := []*SeriesBook{}
+	for rows.Next() {
+		var book SeriesBook
+		err := rows.Scan(&book.SeriesID, &book.BookID, &book.Title, &book.Authors, &book.Position)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, &book)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
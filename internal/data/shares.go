@@ -0,0 +1,167 @@
+// Filename: internal/data/shares.go
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ShareLink gives read-only access to a reading list to anyone holding the token.
+type ShareLink struct {
+	ID            int64      `json:"id"`
+	ReadingListID int64      `json:"readinglist_id"`
+	Plaintext     string     `json:"token,omitempty"` // only returned when the link is created
+	Hash          []byte     `json:"-"`
+	CreatedBy     int64      `json:"created_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	Expiry        *time.Time `json:"expiry"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+}
+
+type ShareModel struct {
+	DB      DBTX
+	Timeout time.Duration // how long a single query may run
+}
+
+// New mints a share link for a list. A ttl of zero creates a link that stays
+// valid until it is revoked.
+func (m ShareModel) New(ctx context.Context, listID, userID int64, ttl time.Duration) (*ShareLink, error) {
+	// the token is generated the same way as activation and login tokens
+	token, err := generateToken(userID, ttl, ScopeShare)
+	if err != nil {
+		return nil, err
+	}
+
+	share := &ShareLink{
+		ReadingListID: listID,
+		Plaintext:     token.Plaintext,
+		Hash:          token.Hash,
+		CreatedBy:     userID,
+	}
+	if ttl > 0 {
+		share.Expiry = &token.Expiry
+	}
+
+	query := `
+		INSERT INTO readinglist_shares (hash, readinglist_id, created_by, expiry)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	args := []any{share.Hash, share.ReadingListID, share.CreatedBy, share.Expiry}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&share.ID, &share.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// GetListForToken returns the list an active (not expired, not revoked) share
+// token points to.
+func (m ShareModel) GetListForToken(ctx context.Context, tokenPlaintext string) (*ReadingList, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+		SELECT rl.id, rl.name, rl.description, rl.created_by, rl.is_public, rl.version
+		FROM readinglists rl
+		INNER JOIN readinglist_shares rs ON rs.readinglist_id = rl.id
+		WHERE rs.hash = $1
+		AND rl.deleted_at IS NULL
+		AND rs.revoked_at IS NULL
+		AND (rs.expiry IS NULL OR rs.expiry > $2)
+	`
+	var list ReadingList
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, tokenHash[:], time.Now()).Scan(
+		&list.ID,
+		&list.Name,
+		&list.Description,
+		&list.CreatedBy,
+		&list.IsPublic,
+		&list.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &list, nil
+}
+
+// GetAllForList returns every share link of a list, newest first.
+func (m ShareModel) GetAllForList(ctx context.Context, listID int64) ([]*ShareLink, error) {
+	query := `
+		SELECT id, readinglist_id, created_by, created_at, expiry, revoked_at
+		FROM readinglist_shares
+		WHERE readinglist_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []*ShareLink{}
+	for rows.Next() {
+		var share ShareLink
+		err := rows.Scan(
+			&share.ID,
+			&share.ReadingListID,
+			&share.CreatedBy,
+			&share.CreatedAt,
+			&share.Expiry,
+			&share.RevokedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, &share)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+// Revoke disables a share link. Revoking it twice reports ErrRecordNotFound.
+func (m ShareModel) Revoke(ctx context.Context, id, listID int64) error {
+	query := `
+		UPDATE readinglist_shares
+		SET revoked_at = NOW()
+		WHERE id = $1 AND readinglist_id = $2 AND revoked_at IS NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, listID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
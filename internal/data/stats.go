@@ -0,0 +1,391 @@
+// Filename: internal/data/stats.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Duane-Arzu/test-1.git/internal/validator"
+)
+
+// ReadingProgress is a single log of pages a user read from a book on a day.
+type ReadingProgress struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	BookID    int64     `json:"book_id"`
+	PagesRead int       `json:"pages_read"`
+	LoggedOn  time.Time `json:"logged_on"`
+	Version   int       `json:"version"`
+}
+
+// ReadingGoal is the number of books (and optionally pages) a user wants to read in a year.
+type ReadingGoal struct {
+	UserID      int64 `json:"user_id"`
+	Year        int   `json:"year"`
+	TargetBooks int   `json:"target_books"`
+	TargetPages int   `json:"target_pages"`
+	Version     int   `json:"version"`
+}
+
+// GoalProgress shows how far along a user is with their yearly goal.
+type GoalProgress struct {
+	ReadingGoal
+	BooksPercent float64 `json:"books_percent"`
+	PagesPercent float64 `json:"pages_percent,omitempty"`
+}
+
+// MonthlyStats holds the totals for one month of the year (1-12).
+type MonthlyStats struct {
+	Month          int `json:"month"`
+	BooksCompleted int `json:"books_completed"`
+	PagesRead      int `json:"pages_read"`
+}
+
+// GenreCount is the number of completed books for a genre.
+type GenreCount struct {
+	Genre string `json:"genre"`
+	Count int    `json:"count"`
+}
+
+// ReadingStats is the yearly summary returned by the stats endpoint.
+type ReadingStats struct {
+	UserID            int64          `json:"user_id"`
+	Year              int            `json:"year"`
+	BooksCompleted    int            `json:"books_completed"`
+	PagesRead         int            `json:"pages_read"`
+	ReviewsWritten    int            `json:"reviews_written"`
+	AverageRating     float64        `json:"average_rating_given"`
+	Monthly           []MonthlyStats `json:"monthly"`
+	Genres            []GenreCount   `json:"genres"`
+	CurrentStreak     int            `json:"current_streak_days"`
+	LongestStreak     int            `json:"longest_streak_days"`
+	Goal              *GoalProgress  `json:"goal,omitempty"`
+	LifetimeCompleted int            `json:"lifetime_books_completed"`
+}
+
+type StatsModel struct {
+	DB      DBTX
+	Timeout time.Duration // how long a single query may run
+}
+
+func ValidateReadingProgress(v *validator.Validator, progress *ReadingProgress) {
+	v.Check(progress.BookID > 0, "book_id", "must be a positive integer")
+	v.Check(progress.PagesRead > 0, "pages_read", "must be greater than zero")
+	v.Check(progress.PagesRead <= 5000, "pages_read", "must not be more than 5000")
+	v.Check(!progress.LoggedOn.After(time.Now()), "logged_on", "must not be in the future")
+}
+
+func ValidateReadingGoal(v *validator.Validator, goal *ReadingGoal) {
+	ValidateStatsYear(v, goal.Year)
+	v.Check(goal.TargetBooks > 0, "target_books", "must be greater than zero")
+	v.Check(goal.TargetBooks <= 1000, "target_books", "must not be more than 1000")
+	v.Check(goal.TargetPages >= 0, "target_pages", "must not be negative")
+}
+
+func ValidateStatsYear(v *validator.Validator, year int) {
+	v.Check(year >= 1900, "year", "must be greater than or equal to 1900")
+	v.Check(year <= time.Now().Year()+1, "year", "must not be more than one year in the future")
+}
+
+// InsertProgress logs pages read for a book.
+func (m StatsModel) InsertProgress(ctx context.Context, progress *ReadingProgress) error {
+	query := `
+		INSERT INTO reading_progress (user_id, book_id, pages_read, logged_on)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, version
+	`
+	args := []any{progress.UserID, progress.BookID, progress.PagesRead, progress.LoggedOn}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&progress.ID, &progress.Version)
+}
+
+// UpsertGoal creates or replaces the user's goal for a year.
+func (m StatsModel) UpsertGoal(ctx context.Context, goal *ReadingGoal) error {
+	query := `
+		INSERT INTO reading_goals (user_id, year, target_books, target_pages)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, year) DO UPDATE
+		SET target_books = EXCLUDED.target_books, target_pages = EXCLUDED.target_pages,
+		    version = reading_goals.version + 1
+		RETURNING version
+	`
+	args := []any{goal.UserID, goal.Year, goal.TargetBooks, goal.TargetPages}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&goal.Version)
+}
+
+// GetGoal returns the user's goal for a year.
+func (m StatsModel) GetGoal(ctx context.Context, userID int64, year int) (*ReadingGoal, error) {
+	query := `
+		SELECT user_id, year, target_books, target_pages, version
+		FROM reading_goals
+		WHERE user_id = $1 AND year = $2
+	`
+	var goal ReadingGoal
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID, year).Scan(
+		&goal.UserID,
+		&goal.Year,
+		&goal.TargetBooks,
+		&goal.TargetPages,
+		&goal.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &goal, nil
+}
+
+// GetForUser aggregates the reading statistics of a user for the given year.
+func (m StatsModel) GetForUser(ctx context.Context, userID int64, year int) (*ReadingStats, error) {
+	stats := &ReadingStats{
+		UserID:  userID,
+		Year:    year,
+		Monthly: make([]MonthlyStats, 12),
+		Genres:  []GenreCount{},
+	}
+	for i := range stats.Monthly {
+		stats.Monthly[i].Month = i + 1
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	// Completed books per month. A book that sits on several of the user's
+	// lists is only counted once, on the first day it was completed.
+	query := `
+		SELECT EXTRACT(MONTH FROM first_completed)::int, COUNT(*)
+		FROM (
+			SELECT rb.book_id, MIN(rb.completed_at) AS first_completed
+			FROM readinglist_books rb
+			INNER JOIN readinglists rl ON rl.id = rb.readinglist_id
+			WHERE rl.created_by = $1 AND rl.deleted_at IS NULL AND rb.status = 'completed' AND rb.completed_at IS NOT NULL
+			GROUP BY rb.book_id
+		) completed
+		WHERE EXTRACT(YEAR FROM first_completed)::int = $2
+		GROUP BY 1
+	`
+	rows, err := m.DB.QueryContext(ctx, query, userID, year)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var month, count int
+		err = rows.Scan(&month, &count)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.Monthly[month-1].BooksCompleted = count
+		stats.BooksCompleted += count
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Pages read per month come from the progress logs
+	query = `
+		SELECT EXTRACT(MONTH FROM logged_on)::int, SUM(pages_read)
+		FROM reading_progress
+		WHERE user_id = $1 AND EXTRACT(YEAR FROM logged_on)::int = $2
+		GROUP BY 1
+	`
+	rows, err = m.DB.QueryContext(ctx, query, userID, year)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var month, pages int
+		err = rows.Scan(&month, &pages)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.Monthly[month-1].PagesRead = pages
+		stats.PagesRead += pages
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Genre breakdown of the books completed this year, counted the same
+	// way as above so a book on several lists adds to a genre once
+	query = `
+		SELECT COALESCE(NULLIF(b.genre, ''), 'unknown'), COUNT(DISTINCT b.id)
+		FROM (
+			SELECT rb.book_id, MIN(rb.completed_at) AS first_completed
+			FROM readinglist_books rb
+			INNER JOIN readinglists rl ON rl.id = rb.readinglist_id
+			WHERE rl.created_by = $1 AND rl.deleted_at IS NULL AND rb.status = 'completed' AND rb.completed_at IS NOT NULL
+			GROUP BY rb.book_id
+		) completed
+		INNER JOIN books b ON b.id = completed.book_id AND b.deleted_at IS NULL
+		WHERE EXTRACT(YEAR FROM first_completed)::int = $2
+		GROUP BY 1
+		ORDER BY 2 DESC, 1 ASC
+	`
+	rows, err = m.DB.QueryContext(ctx, query, userID, year)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var genre GenreCount
+		err = rows.Scan(&genre.Genre, &genre.Count)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.Genres = append(stats.Genres, genre)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Reviews written and the average rating the user gave this year
+	query = `
+		SELECT COUNT(*), COALESCE(ROUND(CAST(AVG(rating) AS NUMERIC), 2), 0)
+		FROM bookreviews
+		WHERE user_id = $1 AND deleted_at IS NULL AND EXTRACT(YEAR FROM review_date)::int = $2
+	`
+	err = m.DB.QueryRowContext(ctx, query, userID, year).Scan(&stats.ReviewsWritten, &stats.AverageRating)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT COUNT(DISTINCT rb.book_id)
+		FROM readinglist_books rb
+		INNER JOIN readinglists rl ON rl.id = rb.readinglist_id
+		WHERE rl.created_by = $1 AND rl.deleted_at IS NULL AND rb.status = 'completed'
+	`
+	err = m.DB.QueryRowContext(ctx, query, userID).Scan(&stats.LifetimeCompleted)
+	if err != nil {
+		return nil, err
+	}
+
+	// A streak is a run of consecutive days on which the user either logged
+	// progress or completed a book
+	query = `
+		SELECT logged_on FROM reading_progress WHERE user_id = $1
+		UNION
+		SELECT rb.completed_at::date
+		FROM readinglist_books rb
+		INNER JOIN readinglists rl ON rl.id = rb.readinglist_id
+		WHERE rl.created_by = $1 AND rl.deleted_at IS NULL AND rb.completed_at IS NOT NULL
+		ORDER BY 1
+	`
+	rows, err = m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	var days []time.Time
+	for rows.Next() {
+		var day time.Time
+		err = rows.Scan(&day)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	stats.CurrentStreak, stats.LongestStreak = calculateStreaks(days, time.Now())
+
+	goal, err := m.GetGoal(ctx, userID, year)
+	if err != nil && !errors.Is(err, ErrRecordNotFound) {
+		return nil, err
+	}
+	if goal != nil {
+		stats.Goal = calculateGoalProgress(goal, stats.BooksCompleted, stats.PagesRead)
+	}
+
+	return stats, nil
+}
+
+// calculateGoalProgress works out the percentage of the goal that was reached,
+// capped at 100.
+func calculateGoalProgress(goal *ReadingGoal, books, pages int) *GoalProgress {
+	progress := &GoalProgress{ReadingGoal: *goal}
+	progress.BooksPercent = percentOf(books, goal.TargetBooks)
+	if goal.TargetPages > 0 {
+		progress.PagesPercent = percentOf(pages, goal.TargetPages)
+	}
+	return progress
+}
+
+func percentOf(value, target int) float64 {
+	if target <= 0 {
+		return 0
+	}
+	percent := float64(value) * 100 / float64(target)
+	if percent > 100 {
+		percent = 100
+	}
+	// round to 2 decimal places
+	return float64(int(percent*100+0.5)) / 100
+}
+
+// calculateStreaks returns the current and longest run of consecutive reading
+// days. The current streak is still alive if the last reading day was today
+// or yesterday.
+func calculateStreaks(days []time.Time, now time.Time) (current int, longest int) {
+	if len(days) == 0 {
+		return 0, 0
+	}
+
+	// normalise to calendar days and remove duplicates
+	seen := make(map[time.Time]bool, len(days))
+	unique := make([]time.Time, 0, len(days))
+	for _, day := range days {
+		d := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+		if !seen[d] {
+			seen[d] = true
+			unique = append(unique, d)
+		}
+	}
+	sort.Slice(unique, func(i, j int) bool { return unique[i].Before(unique[j]) })
+
+	run := 1
+	longest = 1
+	for i := 1; i < len(unique); i++ {
+		if unique[i].Sub(unique[i-1]) == 24*time.Hour {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	last := unique[len(unique)-1]
+	if today.Sub(last) <= 24*time.Hour {
+		current = run
+	}
+
+	return current, longest
+}
@@ -0,0 +1,174 @@
+// Filename: internal/data/tags.go
+package data
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Duane-Arzu/test-1.git/internal/validator"
+)
+
+var tagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9 \-]*$`)
+
+// Tag is a free-form label a user puts on a book.
+type Tag struct {
+	UserID    int64     `json:"user_id"`
+	BookID    int64     `json:"book_id"`
+	Name      string    `json:"tag"`
+	Public    bool      `json:"public"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TagCount is a tag together with how many times it was used.
+type TagCount struct {
+	Name  string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+type TagModel struct {
+	DB      DBTX
+	Timeout time.Duration // how long a single query may run
+}
+
+// NormalizeTag lowercases a tag and collapses repeated whitespace so that
+// "Sci  Fi" and "sci fi" end up as the same tag.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+func ValidateTag(v *validator.Validator, tag *Tag) {
+	v.Check(tag.BookID > 0, "book_id", "must be a positive integer")
+	v.Check(tag.Name != "", "tag", "must be provided")
+	v.Check(len(tag.Name) <= 50, "tag", "must not be more than 50 bytes long")
+	v.Check(validator.Matches(tag.Name, tagRX), "tag", "must only contain letters, digits, spaces and dashes")
+}
+
+// Insert tags a book for a user. Tagging a book twice with the same tag only
+// updates its visibility.
+func (m TagModel) Insert(ctx context.Context, tag *Tag) error {
+	query := `
+		INSERT INTO user_book_tags (user_id, book_id, tag, is_public)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, book_id, tag) DO UPDATE SET is_public = EXCLUDED.is_public
+		RETURNING created_at
+	`
+	args := []any{tag.UserID, tag.BookID, tag.Name, tag.Public}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&tag.CreatedAt)
+}
+
+// Delete removes a tag the user put on a book.
+func (m TagModel) Delete(ctx context.Context, userID, bookID int64, name string) error {
+	query := `
+		DELETE FROM user_book_tags
+		WHERE user_id = $1 AND book_id = $2 AND tag = $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, bookID, name)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAllForUser returns every tag the user has used and on how many books.
+func (m TagModel) GetAllForUser(ctx context.Context, userID int64) ([]TagCount, error) {
+	query := `
+		SELECT tag, COUNT(*)
+		FROM user_book_tags
+		WHERE user_id = $1
+		GROUP BY tag
+		ORDER BY COUNT(*) DESC, tag ASC
+	`
+	return m.getCounts(ctx, query, userID)
+}
+
+// GetForBook returns the tags the user put on one book.
+func (m TagModel) GetForBook(ctx context.Context, userID, bookID int64) ([]*Tag, error) {
+	query := `
+		SELECT user_id, book_id, tag, is_public, created_at
+		FROM user_book_tags
+		WHERE user_id = $1 AND book_id = $2
+		ORDER BY tag ASC
+	`
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*Tag{}
+	for rows.Next() {
+		var tag Tag
+		err := rows.Scan(&tag.UserID, &tag.BookID, &tag.Name, &tag.Public, &tag.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// GetPopularForBook aggregates the public tags of all users for a book.
+func (m TagModel) GetPopularForBook(ctx context.Context, bookID int64, limit int) ([]TagCount, error) {
+	query := `
+		SELECT tag, COUNT(*)
+		FROM user_book_tags
+		WHERE book_id = $1 AND is_public
+		GROUP BY tag
+		ORDER BY COUNT(*) DESC, tag ASC
+		LIMIT $2
+	`
+	return m.getCounts(ctx, query, bookID, limit)
+}
+
+func (m TagModel) getCounts(ctx context.Context, query string, args ...any) ([]TagCount, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []TagCount{}
+	for rows.Next() {
+		var count TagCount
+		err := rows.Scan(&count.Name, &count.Count)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
-	"database/sql"
 	"encoding/base32"
 	"time"
 
@@ -15,6 +14,8 @@ import (
 const (
 	ScopeActivation     = "activation"     // Token for account activation.
 	ScopeAuthentication = "authentication" // Token for user authentication.
+	ScopeShare          = "share"          // Token for read-only reading list links.
+	ScopeExport         = "export"         // Token for downloading a personal data export.
 )
 
 // Token represents a user's token with associated metadata.
@@ -60,11 +61,12 @@ func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string) {
 
 // TokenModel provides methods for managing tokens in the database.
 type TokenModel struct {
-	DB *sql.DB // Database connection pool.
+	DB      DBTX          // Database connection pool.
+	Timeout time.Duration // how long a single query may run
 }
 
 // New creates a new token, saves it in the database, and returns it.
-func (t TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token, error) {
+func (t TokenModel) New(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error) {
 	// Generate a new token for the user.
 	token, err := generateToken(userID, ttl, scope)
 	if err != nil {
@@ -72,12 +74,12 @@ func (t TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token,
 	}
 
 	// Insert the token into the database.
-	err = t.Insert(token)
+	err = t.Insert(ctx, token)
 	return token, err
 }
 
 // Insert saves the token into the database.
-func (t TokenModel) Insert(token *Token) error {
+func (t TokenModel) Insert(ctx context.Context, token *Token) error {
 	query := `
               INSERT INTO tokens (hash, user_id, expiry, scope) 
               VALUES ($1, $2, $3, $4)
@@ -86,7 +88,7 @@ func (t TokenModel) Insert(token *Token) error {
 	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope}
 
 	// Use a context with a timeout to prevent long-running queries.
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
 	defer cancel()
 
 	_, err := t.DB.ExecContext(ctx, query, args...)
@@ -94,13 +96,13 @@ func (t TokenModel) Insert(token *Token) error {
 }
 
 // DeleteAllForUser removes all tokens for a specific user and scope.
-func (t TokenModel) DeleteAllForUser(scope string, userID int64) error {
+func (t TokenModel) DeleteAllForUser(ctx context.Context, scope string, userID int64) error {
 	query := `
             DELETE FROM tokens 
             WHERE scope = $1 AND user_id = $2
 			`
 	// Context with a timeout for safety.
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
 	defer cancel()
 
 	_, err := t.DB.ExecContext(ctx, query, scope, userID)
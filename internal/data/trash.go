@@ -0,0 +1,213 @@
+// Filename: internal/data/trash.go
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// Trash holds the deleted records a user can still restore. Books have no
+// owner, so they are only listed for moderators.
+type Trash struct {
+	Books        []*Book        `json:"books,omitempty"`
+	ReadingLists []*ReadingList `json:"reading_lists"`
+	Reviews      []*Review      `json:"reviews"`
+}
+
+// TrashModel looks after the books, reading lists and reviews that were
+// deleted: their Delete methods only set deleted_at, and the rows stay until
+// they are restored or purged.
+type TrashModel struct {
+	DB      DBTX
+	Timeout time.Duration // how long a single query may run
+}
+
+// GetForUser returns the user's lists and reviews in the trash, and every
+// book in it when includeBooks is set. The most recently deleted come first.
+func (m TrashModel) GetForUser(ctx context.Context, userID int64, includeBooks bool) (*Trash, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	trash := &Trash{ReadingLists: []*ReadingList{}, Reviews: []*Review{}}
+
+	if includeBooks {
+		trash.Books = []*Book{}
+		rows, err := m.DB.QueryContext(ctx, `
+			SELECT id, title, authors, isbn, publication_date, genre, description, average_rating, version,
+			       COALESCE(work_id, 0), format, publisher, language, page_count, cover_key,
+			       rating_count, rating_distribution, weighted_rating, deleted_at
+			FROM books
+			WHERE deleted_at IS NOT NULL
+			ORDER BY deleted_at DESC, id DESC`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var book Book
+			err := rows.Scan(
+				&book.ID,
+				&book.Title,
+				&book.Authors,
+				&book.ISBN,
+				&book.PublicationDate,
+				&book.Genre,
+				&book.Description,
+				&book.AverageRating,
+				&book.Version,
+				&book.WorkID,
+				&book.Format,
+				&book.Publisher,
+				&book.Language,
+				&book.PageCount,
+				&book.Cover,
+				&book.RatingCount,
+				&book.RatingDistribution,
+				&book.WeightedRating,
+				&book.DeletedAt,
+			)
+			if err != nil {
+				return nil, err
+			}
+			trash.Books = append(trash.Books, &book)
+		}
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := m.DB.QueryContext(ctx, `
+		SELECT id, name, description, created_by, is_public, version, deleted_at
+		FROM readinglists
+		WHERE created_by = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var list ReadingList
+		err := rows.Scan(
+			&list.ID,
+			&list.Name,
+			&list.Description,
+			&list.CreatedBy,
+			&list.IsPublic,
+			&list.Version,
+			&list.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		trash.ReadingLists = append(trash.ReadingLists, &list)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = m.DB.QueryContext(ctx, `
+		SELECT id, book_id, user_id, rating, review, review_date, version, deleted_at
+		FROM bookreviews
+		WHERE user_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var review Review
+		err := rows.Scan(
+			&review.ReviewID,
+			&review.BookID,
+			&review.UserID,
+			&review.Rating,
+			&review.ReviewText,
+			&review.ReviewDate,
+			&review.Version,
+			&review.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		trash.Reviews = append(trash.Reviews, &review)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return trash, nil
+}
+
+// RestoreBook takes a book out of the trash.
+func (m TrashModel) RestoreBook(ctx context.Context, id int64) error {
+	return m.restore(ctx, `
+		UPDATE books SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL`, id)
+}
+
+// RestoreReadingList takes a list out of the trash if it belongs to ownerID.
+func (m TrashModel) RestoreReadingList(ctx context.Context, id, ownerID int64) error {
+	return m.restore(ctx, `
+		UPDATE readinglists SET deleted_at = NULL
+		WHERE id = $1 AND created_by = $2 AND deleted_at IS NOT NULL`, id, ownerID)
+}
+
+// RestoreReview takes a review out of the trash if ownerID wrote it. The
+// rating trigger puts its rating back into the book's statistics.
+func (m TrashModel) RestoreReview(ctx context.Context, id, ownerID int64) error {
+	return m.restore(ctx, `
+		UPDATE bookreviews SET deleted_at = NULL
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`, id, ownerID)
+}
+
+func (m TrashModel) restore(ctx context.Context, query string, args ...any) error {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	// nothing of that id (and owner) is in the trash
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Purge removes everything that went to the trash before cutoff for good,
+// along with what the foreign keys cascade to, and returns how many books,
+// lists and reviews it removed.
+func (m TrashModel) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	// a long trash takes a while to empty
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var purged int64
+	for _, table := range []string{"bookreviews", "readinglists", "books"} {
+		result, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE deleted_at < $1`, cutoff)
+		if err != nil {
+			return 0, err
+		}
+		count, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		purged += count
+	}
+
+	return purged, tx.Commit()
+}
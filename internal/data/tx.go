@@ -0,0 +1,149 @@
+// Filename: internal/data/tx.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DBTX is what the models need to run their statements: the connection pool,
+// or a transaction when they are part of a unit of work.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// Transactor runs a unit of work: fn gets repositories that all work in one
+// transaction, which is committed if fn returns nil and rolled back
+// otherwise. fn may be run more than once, so it should not have effects
+// outside the repositories it is given.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(Models) error) error
+}
+
+// txAttempts is how many times a unit of work is tried before a
+// serialization failure is returned to the caller.
+const txAttempts = 3
+
+type pgTransactor struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// WithinTx runs fn in a serializable transaction, starting over when
+// PostgreSQL aborts it because of a concurrent transaction.
+func (t pgTransactor) WithinTx(ctx context.Context, fn func(Models) error) error {
+	var err error
+	for attempt := 1; attempt <= txAttempts; attempt++ {
+		err = t.run(ctx, fn)
+		if !isSerializationFailure(err) {
+			return err
+		}
+
+		// give the transaction we collided with a moment to finish
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt*attempt) * 10 * time.Millisecond):
+		}
+	}
+	return err
+}
+
+func (t pgTransactor) run(ctx context.Context, fn func(Models) error) error {
+	tx, err := t.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// units of work started inside this one join its transaction, and its
+	// reads stay in it too, so they see what it changed
+	joined := &joinedTransactor{}
+	models := newModels(tx, tx, t.timeout, joined)
+	joined.models = models
+
+	err = fn(models)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type joinedTransactor struct {
+	models Models
+}
+
+func (t *joinedTransactor) WithinTx(ctx context.Context, fn func(Models) error) error {
+	return fn(t.models)
+}
+
+// isSerializationFailure reports whether PostgreSQL gave up on the
+// transaction because of a concurrent one, serialization_failure (40001) or
+// deadlock_detected (40P01), in which case running it again can succeed.
+func isSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == "40001" || pqErr.Code == "40P01")
+}
+
+// txn is the transaction a model method runs its statements in.
+type txn interface {
+	DBTX
+	Commit() error
+	Rollback() error
+}
+
+// savepoints names the savepoints so nested ones do not clash.
+var savepoints atomic.Int64
+
+// beginTx starts a transaction for a model method. When the model is already
+// part of a unit of work the method gets a savepoint instead, so it can still
+// undo its own statements without ending the outer transaction.
+func beginTx(ctx context.Context, db DBTX) (txn, error) {
+	switch db := db.(type) {
+	case *sql.DB:
+		return db.BeginTx(ctx, nil)
+	case *sql.Tx:
+		name := fmt.Sprintf("model_%d", savepoints.Add(1))
+		_, err := db.ExecContext(ctx, "SAVEPOINT "+name)
+		if err != nil {
+			return nil, err
+		}
+		return &savepoint{Tx: db, ctx: ctx, name: name}, nil
+	}
+	return nil, fmt.Errorf("cannot start a transaction on %T", db)
+}
+
+// savepoint behaves like a transaction inside the one it is part of.
+type savepoint struct {
+	*sql.Tx
+	ctx  context.Context
+	name string
+	done bool
+}
+
+func (s *savepoint) Commit() error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+	s.done = true
+	_, err := s.Tx.ExecContext(s.ctx, "RELEASE SAVEPOINT "+s.name)
+	return err
+}
+
+func (s *savepoint) Rollback() error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+	s.done = true
+	_, err := s.Tx.ExecContext(s.ctx, "ROLLBACK TO SAVEPOINT "+s.name)
+	return err
+}
@@ -48,7 +48,8 @@ type password struct {
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB      DBTX
+	Timeout time.Duration // how long a single query may run
 }
 
 func (u *User) IsAnonymous() bool {
@@ -103,7 +104,7 @@ func ValidateUser(v *validator.Validator, user *User) {
 }
 
 // Insert a new user into the database.
-func (u UserModel) Insert(user *User) error {
+func (u UserModel) Insert(ctx context.Context, user *User) error {
 	query := `
 		INSERT INTO users (created_at, username, email, password_hash, activated, version)
 		VALUES ($1, $2, $3, $4, $5, $6)
@@ -118,7 +119,7 @@ func (u UserModel) Insert(user *User) error {
 		user.Version,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, u.Timeout)
 	defer cancel()
 
 	err := u.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
@@ -132,7 +133,7 @@ func (u UserModel) Insert(user *User) error {
 	return nil
 }
 
-func (u UserModel) GetByEmail(email string) (*User, error) {
+func (u UserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
 	SELECT id, created_at, username, email, password_hash, activated, version
 	FROM users
@@ -140,7 +141,7 @@ func (u UserModel) GetByEmail(email string) (*User, error) {
    `
 	var user User
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, u.Timeout)
 	defer cancel()
 	err := u.DB.QueryRowContext(ctx, query, email).Scan(
 		&user.ID,
@@ -164,7 +165,7 @@ func (u UserModel) GetByEmail(email string) (*User, error) {
 }
 
 // Update an existing user in the database.
-func (u UserModel) Update(user *User) error {
+func (u UserModel) Update(ctx context.Context, user *User) error {
 	query := `
 		UPDATE users 
 		SET username = $1, email = $2, password_hash = $3,
@@ -181,7 +182,7 @@ func (u UserModel) Update(user *User) error {
 		user.Version,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, u.Timeout)
 	defer cancel()
 
 	err := u.DB.QueryRowContext(ctx, query, args...).Scan(&user.Version)
@@ -195,7 +196,7 @@ func (u UserModel) Update(user *User) error {
 	return nil
 }
 
-func (u UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+func (u UserModel) GetForToken(ctx context.Context, tokenScope, tokenPlaintext string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 
 	query := `
@@ -210,7 +211,7 @@ func (u UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
        `
 	args := []any{tokenHash[:], tokenScope, time.Now()}
 	var user User
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, u.Timeout)
 	defer cancel()
 	err := u.DB.QueryRowContext(ctx, query, args...).Scan(
 		&user.ID,
@@ -232,13 +233,13 @@ func (u UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 	return &user, nil
 }
 
-func (u *UserModel) GetByID(id int64) (*User, error) {
+func (u *UserModel) GetByID(ctx context.Context, id int64) (*User, error) {
 	query := `
 	SELECT id, created_at, username, email, activated, version
 	FROM users
 	WHERE id = $1
 	`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, u.Timeout)
 	defer cancel()
 
 	var user User
@@ -262,13 +263,13 @@ func (u *UserModel) GetByID(id int64) (*User, error) {
 	return &user, nil
 }
 
-func (u *UserModel) GetUserReviews(userID int64) ([]UserReview, error) {
+func (u *UserModel) GetUserReviews(ctx context.Context, userID int64) ([]UserReview, error) {
 	query := `
 	SELECT id, book_id, rating, review, review_date, version
 	FROM bookreviews
-	WHERE user_id = $1
+	WHERE user_id = $1 AND deleted_at IS NULL
 	`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, u.Timeout)
 	defer cancel()
 
 	rows, err := u.DB.QueryContext(ctx, query, userID)
@@ -302,13 +303,13 @@ func (u *UserModel) GetUserReviews(userID int64) ([]UserReview, error) {
 	return reviews, nil
 }
 
-func (u *UserModel) GetUserLists(userID int64) ([]UserList, error) {
+func (u *UserModel) GetUserLists(ctx context.Context, userID int64) ([]UserList, error) {
 	query := `
 	SELECT id, name, description, created_by, version
 	FROM readinglists
-	WHERE created_by = $1
+	WHERE created_by = $1 AND deleted_at IS NULL
 	`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, u.Timeout)
 	defer cancel()
 
 	rows, err := u.DB.QueryContext(ctx, query, userID)
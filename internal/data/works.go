@@ -0,0 +1,126 @@
+// Filename: internal/data/works.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Work is a book as written. Its editions are the rows of the books table
+// that point to it, and its rating is the average over all of their reviews.
+type Work struct {
+	ID            int64   `json:"id"`
+	Title         string  `json:"title"`
+	Authors       string  `json:"authors"`
+	Description   string  `json:"description"`
+	AverageRating float32 `json:"average_rating"`
+	ReviewCount   int     `json:"review_count"`
+	Editions      []*Book `json:"editions"`
+	Version       int32   `json:"version"`
+}
+
+type WorkModel struct {
+	DB      DBTX
+	Reader  DBTX          // the read replica, for requests marked by AllowReplicaReads
+	Timeout time.Duration // how long a single query may run
+}
+
+// Get returns a work together with all of its editions.
+func (m WorkModel) Get(ctx context.Context, id int64) (*Work, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+		SELECT w.id, w.title, COALESCE(w.authors, ''), COALESCE(w.description, ''),
+		       w.average_rating, w.version,
+		       (SELECT COUNT(*) FROM bookreviews r INNER JOIN books b ON b.id = r.book_id
+		        WHERE b.work_id = w.id AND b.deleted_at IS NULL AND r.deleted_at IS NULL)
+		FROM works w
+		WHERE w.id = $1
+	`
+	var work Work
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := readFrom(ctx, m.DB, m.Reader).QueryRowContext(ctx, query, id).Scan(
+		&work.ID,
+		&work.Title,
+		&work.Authors,
+		&work.Description,
+		&work.AverageRating,
+		&work.Version,
+		&work.ReviewCount,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	query = `
+		SELECT id, title, authors, isbn, publication_date, genre, description, average_rating, version,
+		       COALESCE(work_id, 0), format, publisher, language, page_count, cover_key,
+		       rating_count, rating_distribution, weighted_rating
+		FROM books
+		WHERE work_id = $1 AND deleted_at IS NULL
+		ORDER BY publication_date ASC, id ASC
+	`
+	rows, err := readFrom(ctx, m.DB, m.Reader).QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	work.Editions = []*Book{}
+	for rows.Next() {
+		var book Book
+		err := rows.Scan(
+			&book.ID,
+			&book.Title,
+			&book.Authors,
+			&book.ISBN,
+			&book.PublicationDate,
+			&book.Genre,
+			&book.Description,
+			&book.AverageRating,
+			&book.Version,
+			&book.WorkID,
+			&book.Format,
+			&book.Publisher,
+			&book.Language,
+			&book.PageCount,
+			&book.Cover,
+			&book.RatingCount,
+			&book.RatingDistribution,
+			&book.WeightedRating,
+		)
+		if err != nil {
+			return nil, err
+		}
+		work.Editions = append(work.Editions, &book)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &work, nil
+}
+
+// Exists reports whether a work with the id exists.
+func (m WorkModel) Exists(ctx context.Context, id int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM works WHERE id = $1)`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,287 @@
+// Filename: internal/epub/epub.go
+
+// Package epub reads the catalog metadata and the cover image out of EPUB
+// files. Only the container and the OPF package document are looked at, the
+// content of the book itself is never read.
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxDocumentBytes = 2 << 20  // container.xml and the OPF file
+	maxCoverBytes    = 10 << 20 // cover images larger than this are skipped
+)
+
+// ErrNotEPUB is returned for files that are not a readable EPUB.
+var ErrNotEPUB = errors.New("the file is not a valid EPUB")
+
+// Identifier is a dc:identifier of the publication, e.g. an ISBN or a UUID.
+type Identifier struct {
+	Scheme string `json:"scheme,omitempty"`
+	Value  string `json:"value"`
+}
+
+// Cover is the cover image stored in the EPUB.
+type Cover struct {
+	Href      string `json:"href"`
+	MediaType string `json:"media_type"`
+	Data      []byte `json:"-"`
+}
+
+// Metadata is the Dublin Core metadata of an EPUB.
+type Metadata struct {
+	Title       string       `json:"title"`
+	Creators    []string     `json:"creators"`
+	Identifiers []Identifier `json:"identifiers"`
+	Language    string       `json:"language"`
+	Description string       `json:"description"`
+	Date        string       `json:"date"`
+	Publisher   string       `json:"publisher"`
+	Subjects    []string     `json:"subjects"`
+	Cover       *Cover       `json:"cover,omitempty"`
+}
+
+var isbnPattern = regexp.MustCompile(`^(97[89])?\d{9}[\dX]$`)
+
+// ISBN returns the first identifier that is an ISBN, without hyphens or any
+// "urn:isbn:" prefix. It returns "" when the EPUB carries no ISBN.
+func (m *Metadata) ISBN() string {
+	for _, id := range m.Identifiers {
+		value := strings.ToUpper(id.Value)
+		value = strings.TrimPrefix(value, "URN:ISBN:")
+		value = strings.TrimPrefix(value, "ISBN:")
+		value = strings.NewReplacer("-", "", " ", "").Replace(value)
+		if isbnPattern.MatchString(value) {
+			return value
+		}
+	}
+	return ""
+}
+
+type container struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type opfPackage struct {
+	Metadata struct {
+		Titles       []string        `xml:"title"`
+		Creators     []opfCreator    `xml:"creator"`
+		Identifiers  []opfIdentifier `xml:"identifier"`
+		Languages    []string        `xml:"language"`
+		Descriptions []string        `xml:"description"`
+		Dates        []string        `xml:"date"`
+		Publishers   []string        `xml:"publisher"`
+		Subjects     []string        `xml:"subject"`
+		Metas        []opfMeta       `xml:"meta"`
+	} `xml:"metadata"`
+	Items []opfItem `xml:"manifest>item"`
+}
+
+type opfCreator struct {
+	ID   string `xml:"id,attr"`
+	Role string `xml:"role,attr"` // EPUB 2 opf:role
+	Name string `xml:",chardata"`
+}
+
+type opfIdentifier struct {
+	Scheme string `xml:"scheme,attr"` // EPUB 2 opf:scheme
+	Value  string `xml:",chardata"`
+}
+
+type opfMeta struct {
+	Name     string `xml:"name,attr"`
+	Content  string `xml:"content,attr"`
+	Property string `xml:"property,attr"`
+	Refines  string `xml:"refines,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type opfItem struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+// Read parses the EPUB in r, which holds size bytes.
+func Read(r io.ReaderAt, size int64) (*Metadata, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, ErrNotEPUB
+	}
+
+	var c container
+	err = decodeFile(archive, "META-INF/container.xml", &c)
+	if err != nil {
+		return nil, err
+	}
+	opfPath := ""
+	for _, rootfile := range c.Rootfiles {
+		if rootfile.MediaType == "" || rootfile.MediaType == "application/oebps-package+xml" {
+			opfPath = rootfile.FullPath
+			break
+		}
+	}
+	if opfPath == "" {
+		return nil, fmt.Errorf("%w: container.xml names no package document", ErrNotEPUB)
+	}
+
+	var pkg opfPackage
+	err = decodeFile(archive, opfPath, &pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &Metadata{
+		Title:       first(pkg.Metadata.Titles),
+		Language:    first(pkg.Metadata.Languages),
+		Description: plainText(first(pkg.Metadata.Descriptions)),
+		Date:        first(pkg.Metadata.Dates),
+		Publisher:   first(pkg.Metadata.Publishers),
+		Creators:    []string{},
+		Identifiers: []Identifier{},
+		Subjects:    []string{},
+	}
+
+	// EPUB 3 moves the creator role into a meta element refining the creator
+	roles := make(map[string]string)
+	for _, m := range pkg.Metadata.Metas {
+		if m.Property == "role" && strings.HasPrefix(m.Refines, "#") {
+			roles[strings.TrimPrefix(m.Refines, "#")] = strings.TrimSpace(m.Value)
+		}
+	}
+	for _, creator := range pkg.Metadata.Creators {
+		role := creator.Role
+		if role == "" {
+			role = roles[creator.ID]
+		}
+		name := strings.TrimSpace(creator.Name)
+		if name != "" && (role == "" || role == "aut") {
+			meta.Creators = append(meta.Creators, name)
+		}
+	}
+	for _, id := range pkg.Metadata.Identifiers {
+		value := strings.TrimSpace(id.Value)
+		if value != "" {
+			meta.Identifiers = append(meta.Identifiers, Identifier{Scheme: id.Scheme, Value: value})
+		}
+	}
+	for _, subject := range pkg.Metadata.Subjects {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			meta.Subjects = append(meta.Subjects, subject)
+		}
+	}
+
+	cover := findCover(&pkg)
+	if cover != nil {
+		// hrefs in the manifest are URL encoded and relative to the package document
+		href, err := url.PathUnescape(cover.Href)
+		if err != nil {
+			href = cover.Href
+		}
+		cover.Href = path.Join(path.Dir(opfPath), href)
+		cover.Data, err = readFile(archive, cover.Href, maxCoverBytes)
+		if err == nil {
+			meta.Cover = cover
+		}
+	}
+
+	return meta, nil
+}
+
+// findCover looks for the cover the EPUB 3 way (a manifest item with the
+// cover-image property), then the EPUB 2 way (a meta named cover pointing at
+// an item), then for any image whose id or name mentions "cover".
+func findCover(pkg *opfPackage) *Cover {
+	isImage := func(item opfItem) bool { return strings.HasPrefix(item.MediaType, "image/") }
+	for _, item := range pkg.Items {
+		if isImage(item) && strings.Contains(" "+item.Properties+" ", " cover-image ") {
+			return &Cover{Href: item.Href, MediaType: item.MediaType}
+		}
+	}
+	for _, m := range pkg.Metadata.Metas {
+		if m.Name != "cover" {
+			continue
+		}
+		for _, item := range pkg.Items {
+			if item.ID == m.Content && isImage(item) {
+				return &Cover{Href: item.Href, MediaType: item.MediaType}
+			}
+		}
+	}
+	for _, item := range pkg.Items {
+		if isImage(item) && (strings.Contains(strings.ToLower(item.ID), "cover") ||
+			strings.Contains(strings.ToLower(item.Href), "cover")) {
+			return &Cover{Href: item.Href, MediaType: item.MediaType}
+		}
+	}
+	return nil
+}
+
+func decodeFile(archive *zip.Reader, name string, v any) error {
+	content, err := readFile(archive, name, maxDocumentBytes)
+	if err != nil {
+		return err
+	}
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	dec.Strict = false
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		// package documents are UTF-8 in practice, whatever they declare
+		return input, nil
+	}
+	err = dec.Decode(v)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrNotEPUB, name, err)
+	}
+	return nil
+}
+
+func readFile(archive *zip.Reader, name string, limit int64) ([]byte, error) {
+	f, err := archive.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is missing", ErrNotEPUB, name)
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrNotEPUB, name, err)
+	}
+	if int64(len(content)) > limit {
+		return nil, fmt.Errorf("%w: %s is too large", ErrNotEPUB, name)
+	}
+	return content, nil
+}
+
+func first(values []string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// plainText strips the HTML publishers like to put in descriptions.
+func plainText(s string) string {
+	s = tagPattern.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	return strings.Join(strings.Fields(s), " ")
+}
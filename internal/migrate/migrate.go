@@ -0,0 +1,292 @@
+// Filename: internal/migrate/migrate.go
+
+// Package migrate applies the SQL migrations in the migrations folder. The
+// applied version is kept in a schema_migrations table laid out the way the
+// migrate CLI keeps it, so a database can be moved between the two.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// ErrDirty is returned when an earlier run of the migrate CLI failed half way.
+// The schema has to be repaired by hand and the version forced before
+// anything else is applied.
+var ErrDirty = errors.New("migrate: the database is dirty")
+
+// ErrNoChange is returned by Down when there is nothing left to revert.
+var ErrNoChange = errors.New("migrate: no change")
+
+// Migration is one numbered step of the schema.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration together with whether it is applied.
+type Status struct {
+	Migration
+	Applied bool
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Load reads the migrations from fsys, ordered by version. Every migration
+// needs an up file, the down file is optional.
+func Load(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d is used by both %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migrate: version %d (%s) has no up migration", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []*Migration
+}
+
+// New loads the migrations in fsys.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// Up applies every migration newer than the current version, each in its own
+// transaction together with the version change. It returns the migrations
+// that were applied.
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	applied := []*Migration{}
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		current, err := m.version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.Migrations {
+			if migration.Version <= current {
+				continue
+			}
+			err = m.run(ctx, conn, migration.Up, migration.Version)
+			if err != nil {
+				return fmt.Errorf("migrate: %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the most recent migration and returns it. Only one step is
+// taken at a time since down migrations throw data away.
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	var reverted *Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		current, err := m.version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if current < 0 {
+			return ErrNoChange
+		}
+
+		previous := int64(-1)
+		for _, migration := range m.Migrations {
+			if migration.Version == current {
+				reverted = migration
+				break
+			}
+			previous = migration.Version
+		}
+		if reverted == nil {
+			return fmt.Errorf("migrate: the database is at version %d, which this binary does not know", current)
+		}
+		if reverted.Down == "" {
+			return fmt.Errorf("migrate: %d_%s has no down migration", reverted.Version, reverted.Name)
+		}
+
+		err = m.run(ctx, conn, reverted.Down, previous)
+		if err != nil {
+			return fmt.Errorf("migrate: %d_%s: %w", reverted.Version, reverted.Name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reverted, nil
+}
+
+// Status lists the known migrations and the version the database is at, -1
+// if no migration was applied yet.
+func (m *Migrator) Status(ctx context.Context) ([]Status, int64, bool, error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer conn.Close()
+
+	err = createVersionTable(ctx, conn)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	current, dirty, err := readVersion(ctx, conn)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	statuses := make([]Status, len(m.Migrations))
+	for i, migration := range m.Migrations {
+		statuses[i] = Status{Migration: *migration, Applied: migration.Version <= current}
+	}
+	return statuses, current, dirty, nil
+}
+
+// locked runs fn on a single connection holding an advisory lock, so two
+// instances starting at once do not both apply the same migrations. The
+// second one waits and then finds nothing left to do.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var database string
+	err = conn.QueryRowContext(ctx, `SELECT current_database()`).Scan(&database)
+	if err != nil {
+		return err
+	}
+	lockID := int64(crc32.ChecksumIEEE([]byte(database + "\x00schema_migrations")))
+
+	_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID)
+	if err != nil {
+		return err
+	}
+	// unlock with a fresh context so a cancelled ctx still releases the lock
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	err = createVersionTable(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// version returns the current version, refusing to go on from a dirty one.
+func (m *Migrator) version(ctx context.Context, conn *sql.Conn) (int64, error) {
+	current, dirty, err := readVersion(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("%w at version %d", ErrDirty, current)
+	}
+	return current, nil
+}
+
+// run executes the SQL of a migration and records the new version in the
+// same transaction, so a failing migration leaves nothing behind.
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, query string, version int64) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// without arguments the whole file is sent as one multi-statement query
+	_, err = tx.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `TRUNCATE schema_migrations`)
+	if err != nil {
+		return err
+	}
+	if version >= 0 {
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, version)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func createVersionTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint NOT NULL PRIMARY KEY,
+			dirty boolean NOT NULL
+		)`)
+	return err
+}
+
+// readVersion returns the version in schema_migrations, -1 when it is empty.
+func readVersion(ctx context.Context, conn *sql.Conn) (int64, bool, error) {
+	var version int64
+	var dirty bool
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return -1, false, nil
+		default:
+			return 0, false, err
+		}
+	}
+	return version, dirty, nil
+}
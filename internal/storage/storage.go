@@ -0,0 +1,26 @@
+// Filename: internal/storage/storage.go
+
+// Package storage keeps uploaded files such as book covers. Files are
+// addressed by slash separated keys, e.g. "covers/12/1700000000/small.jpg".
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when no file is stored under a key.
+var ErrNotFound = errors.New("storage: file not found")
+
+// ErrInvalidKey is returned for keys that would escape the storage root.
+var ErrInvalidKey = errors.New("storage: invalid key")
+
+// Storage is implemented by the places uploaded files can be kept.
+type Storage interface {
+	// Put stores the content of r under key, replacing any earlier file.
+	Put(key string, r io.Reader) error
+	// Open returns the file stored under key.
+	Open(key string) (io.ReadSeekCloser, error)
+	// DeletePrefix removes every file whose key starts with prefix + "/".
+	DeletePrefix(prefix string) error
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS reading_goals;
+DROP TABLE IF EXISTS reading_progress;
+ALTER TABLE readinglist_books DROP COLUMN IF EXISTS completed_at;
+ALTER TABLE readinglist_books DROP COLUMN IF EXISTS added_at;
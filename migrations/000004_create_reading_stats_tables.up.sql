@@ -0,0 +1,30 @@
+-- Track when a book was added to a list and when it was completed so that
+-- completed books can be counted per year and per month
+ALTER TABLE readinglist_books ADD COLUMN IF NOT EXISTS added_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW();
+ALTER TABLE readinglist_books ADD COLUMN IF NOT EXISTS completed_at timestamp(0) WITH TIME ZONE;
+
+-- Books that were already completed get their added date as the completion date
+UPDATE readinglist_books SET completed_at = added_at WHERE status = 'completed' AND completed_at IS NULL;
+
+-- Create the 'reading_progress' table to store pages read by a user on a given day
+CREATE TABLE IF NOT EXISTS reading_progress (
+    id bigserial PRIMARY KEY, -- Unique identifier for each progress log
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- Reader, deleted if user is removed
+    book_id bigint NOT NULL REFERENCES books(id) ON DELETE CASCADE, -- Book being read, deleted if book is removed
+    pages_read integer NOT NULL CHECK (pages_read > 0), -- Number of pages read in this session
+    logged_on date NOT NULL DEFAULT CURRENT_DATE, -- Day the reading happened
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(), -- Timestamp when the log was created
+    version integer NOT NULL DEFAULT 1 -- Version for tracking record changes
+);
+
+CREATE INDEX IF NOT EXISTS reading_progress_user_id_logged_on_idx ON reading_progress (user_id, logged_on);
+
+-- Create the 'reading_goals' table to store a user's yearly reading goal
+CREATE TABLE IF NOT EXISTS reading_goals (
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- Owner of the goal
+    year integer NOT NULL, -- Year the goal applies to
+    target_books integer NOT NULL CHECK (target_books > 0), -- Number of books the user wants to complete
+    target_pages integer NOT NULL DEFAULT 0 CHECK (target_pages >= 0), -- Optional number of pages to read
+    version integer NOT NULL DEFAULT 1, -- Version for tracking record changes
+    PRIMARY KEY (user_id, year)
+);
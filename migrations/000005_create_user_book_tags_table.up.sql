@@ -0,0 +1,14 @@
+CREATE EXTENSION IF NOT EXISTS citext;
+
+-- Create the 'user_book_tags' table to store lightweight per-user tags on books
+CREATE TABLE IF NOT EXISTS user_book_tags (
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- Owner of the tag
+    book_id bigint NOT NULL REFERENCES books(id) ON DELETE CASCADE, -- Tagged book
+    tag citext NOT NULL, -- Tag name, case-insensitive
+    is_public bool NOT NULL DEFAULT true, -- Public tags count towards a book's popular tags
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(), -- Timestamp when the tag was added
+    PRIMARY KEY (user_id, book_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS user_book_tags_book_id_tag_idx ON user_book_tags (book_id, tag);
+CREATE INDEX IF NOT EXISTS user_book_tags_user_id_tag_idx ON user_book_tags (user_id, tag);
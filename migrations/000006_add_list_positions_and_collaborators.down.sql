@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS readinglist_collaborators;
+ALTER TABLE readinglist_books DROP CONSTRAINT IF EXISTS readinglist_books_position_key;
+ALTER TABLE readinglist_books DROP COLUMN IF EXISTS position;
+DELETE FROM readinglist_books WHERE status = 'to read';
+ALTER TABLE readinglist_books DROP CONSTRAINT IF EXISTS readinglist_books_status_check;
+ALTER TABLE readinglist_books ADD CONSTRAINT readinglist_books_status_check
+    CHECK (status IN ('currently reading', 'completed'));
+ALTER TABLE readinglists DROP COLUMN IF EXISTS is_public;
@@ -0,0 +1,34 @@
+-- Lists can be made public so other users can clone them
+ALTER TABLE readinglists ADD COLUMN IF NOT EXISTS is_public bool NOT NULL DEFAULT false;
+
+-- Books that are only planned get a 'to read' status (used when a list is cloned)
+ALTER TABLE readinglist_books DROP CONSTRAINT IF EXISTS readinglist_books_status_check;
+ALTER TABLE readinglist_books ADD CONSTRAINT readinglist_books_status_check
+    CHECK (status IN ('to read', 'currently reading', 'completed'));
+
+-- Manual ordering of the books inside a list, starting at 0
+ALTER TABLE readinglist_books ADD COLUMN IF NOT EXISTS position integer;
+
+UPDATE readinglist_books rb
+SET position = numbered.position
+FROM (
+    SELECT readinglist_id, book_id,
+           ROW_NUMBER() OVER (PARTITION BY readinglist_id ORDER BY added_at, book_id) - 1 AS position
+    FROM readinglist_books
+) numbered
+WHERE rb.readinglist_id = numbered.readinglist_id AND rb.book_id = numbered.book_id;
+
+ALTER TABLE readinglist_books ALTER COLUMN position SET NOT NULL;
+
+-- The check is deferred so a reorder can shift several rows inside one transaction
+ALTER TABLE readinglist_books ADD CONSTRAINT readinglist_books_position_key
+    UNIQUE (readinglist_id, position) DEFERRABLE INITIALLY DEFERRED;
+
+-- Create the 'readinglist_collaborators' table to store users who share a list
+CREATE TABLE IF NOT EXISTS readinglist_collaborators (
+    readinglist_id bigint NOT NULL REFERENCES readinglists(id) ON DELETE CASCADE, -- Shared list
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- Collaborator
+    can_edit bool NOT NULL DEFAULT true, -- Whether the collaborator may change the books in the list
+    added_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(), -- Timestamp when the collaborator was added
+    PRIMARY KEY (readinglist_id, user_id)
+);
@@ -0,0 +1,10 @@
+-- Create the 'readinglist_shares' table to store read-only share links for lists
+CREATE TABLE IF NOT EXISTS readinglist_shares (
+    id bigserial PRIMARY KEY, -- Unique identifier for each share link
+    hash bytea UNIQUE NOT NULL, -- SHA-256 hash of the share token, the plaintext is never stored
+    readinglist_id bigint NOT NULL REFERENCES readinglists(id) ON DELETE CASCADE, -- Shared list
+    created_by bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- Owner who created the link
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(), -- Timestamp when the link was created
+    expiry timestamp(0) WITH TIME ZONE, -- Links without an expiry stay valid until revoked
+    revoked_at timestamp(0) WITH TIME ZONE -- Set when the owner revokes the link
+);
@@ -0,0 +1,20 @@
+-- Create the 'series' table to store book series
+CREATE TABLE IF NOT EXISTS series (
+    id bigserial PRIMARY KEY, -- Unique identifier for each series
+    name VARCHAR(255) NOT NULL, -- Name of the series
+    description TEXT NOT NULL DEFAULT '', -- Short description of the series
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(), -- Timestamp when the series was created
+    version integer NOT NULL DEFAULT 1 -- Version for tracking record changes
+);
+
+-- Junction table holding the reading order of the books in a series.
+-- Positions are numeric so novellas can sit between two books (e.g. 2.5)
+CREATE TABLE IF NOT EXISTS series_books (
+    series_id bigint NOT NULL REFERENCES series(id) ON DELETE CASCADE, -- Series the book belongs to
+    book_id bigint NOT NULL REFERENCES books(id) ON DELETE CASCADE, -- Book in the series
+    position NUMERIC(6, 2) NOT NULL CHECK (position > 0), -- Place of the book in the reading order
+    PRIMARY KEY (series_id, book_id),
+    UNIQUE (series_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS series_books_book_id_idx ON series_books (book_id);
@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS update_work_rating ON bookreviews;
+DROP FUNCTION IF EXISTS automatic_work_rating();
+DROP INDEX IF EXISTS books_work_id_idx;
+ALTER TABLE books DROP COLUMN IF EXISTS page_count;
+ALTER TABLE books DROP COLUMN IF EXISTS language;
+ALTER TABLE books DROP COLUMN IF EXISTS publisher;
+ALTER TABLE books DROP COLUMN IF EXISTS format;
+ALTER TABLE books DROP COLUMN IF EXISTS work_id;
+DROP TABLE IF EXISTS works;
@@ -0,0 +1,63 @@
+-- Create the 'works' table. A work is the book as written, every row in
+-- 'books' is one edition (hardcover, paperback, translation...) of a work
+CREATE TABLE IF NOT EXISTS works (
+    id bigserial PRIMARY KEY, -- Unique identifier for each work
+    title VARCHAR(255) NOT NULL, -- Title of the work
+    authors TEXT, -- Authors of the work
+    description TEXT, -- Short description or summary of the work
+    average_rating DECIMAL(3, 2) DEFAULT 0.00, -- Average rating over the reviews of all editions
+    version integer NOT NULL DEFAULT 1 -- Version for tracking record changes
+);
+
+-- Edition details on the books table
+ALTER TABLE books ADD COLUMN IF NOT EXISTS work_id bigint REFERENCES works(id) ON DELETE SET NULL;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS format VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS publisher VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS language VARCHAR(35) NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS page_count integer NOT NULL DEFAULT 0 CHECK (page_count >= 0);
+
+CREATE INDEX IF NOT EXISTS books_work_id_idx ON books (work_id);
+
+-- Every existing book becomes the single edition of its own work
+ALTER TABLE works ADD COLUMN seed_book_id bigint;
+
+INSERT INTO works (title, authors, description, average_rating, seed_book_id)
+SELECT title, authors, description, average_rating, id
+FROM books
+WHERE work_id IS NULL;
+
+UPDATE books SET work_id = works.id
+FROM works
+WHERE works.seed_book_id = books.id;
+
+ALTER TABLE works DROP COLUMN seed_book_id;
+
+-- Function to recalculate the average rating of a work from the reviews of all its editions
+CREATE OR REPLACE FUNCTION automatic_work_rating()
+RETURNS TRIGGER AS $$
+DECLARE
+    reviewed_book bigint;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        reviewed_book := OLD.book_id;
+    ELSE
+        reviewed_book := NEW.book_id;
+    END IF;
+
+    UPDATE works
+    SET average_rating = COALESCE((
+        SELECT ROUND(CAST(AVG(r.rating) AS NUMERIC), 2)
+        FROM bookreviews r
+        INNER JOIN books b ON b.id = r.book_id
+        WHERE b.work_id = works.id
+    ), 0)
+    WHERE id = (SELECT work_id FROM books WHERE id = reviewed_book);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER update_work_rating
+AFTER INSERT OR UPDATE OR DELETE ON bookreviews
+FOR EACH ROW
+EXECUTE FUNCTION automatic_work_rating();
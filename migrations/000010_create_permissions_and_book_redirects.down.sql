@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS books_title_authors_trgm_idx;
+DROP TABLE IF EXISTS book_redirects;
+DROP TABLE IF EXISTS users_permissions;
+DROP TABLE IF EXISTS permissions;
@@ -0,0 +1,28 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Create the 'permissions' table to store the permission codes users can be granted
+CREATE TABLE IF NOT EXISTS permissions (
+    id bigserial PRIMARY KEY, -- Unique identifier for each permission
+    code text NOT NULL UNIQUE -- Permission code, e.g. 'books:moderate'
+);
+
+-- Junction table for granting permissions to users
+CREATE TABLE IF NOT EXISTS users_permissions (
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- User holding the permission
+    permission_id bigint NOT NULL REFERENCES permissions(id) ON DELETE CASCADE, -- Granted permission
+    PRIMARY KEY (user_id, permission_id)
+);
+
+INSERT INTO permissions (code) VALUES ('books:moderate') ON CONFLICT (code) DO NOTHING;
+
+-- Create the 'book_redirects' table so the ids of merged duplicates keep resolving
+CREATE TABLE IF NOT EXISTS book_redirects (
+    old_id bigint PRIMARY KEY, -- Id of the duplicate that was merged away
+    new_id bigint NOT NULL REFERENCES books(id) ON DELETE CASCADE, -- Canonical book it was merged into
+    merged_by bigint REFERENCES users(id) ON DELETE SET NULL, -- Moderator who did the merge
+    merged_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW() -- Timestamp of the merge
+);
+
+-- Trigram index used to find books with near identical titles and authors
+CREATE INDEX IF NOT EXISTS books_title_authors_trgm_idx
+    ON books USING gin ((title || ' ' || COALESCE(authors, '')) gin_trgm_ops);
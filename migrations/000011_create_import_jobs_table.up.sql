@@ -0,0 +1,20 @@
+-- Create the 'import_jobs' table to track imports that run in the background
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id bigserial PRIMARY KEY, -- Unique identifier for each import job
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- User whose data is imported
+    source text NOT NULL, -- Where the file came from, e.g. 'goodreads'
+    status text NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'completed', 'failed')), -- State of the job
+    total_rows integer NOT NULL DEFAULT 0, -- Number of rows in the uploaded file
+    processed_rows integer NOT NULL DEFAULT 0, -- Number of rows handled so far
+    books_matched integer NOT NULL DEFAULT 0, -- Rows matched to a book already in the catalog
+    books_created integer NOT NULL DEFAULT 0, -- Rows that added a new book to the catalog
+    list_entries integer NOT NULL DEFAULT 0, -- Books placed on reading lists
+    reviews integer NOT NULL DEFAULT 0, -- Ratings and reviews imported
+    errors jsonb NOT NULL DEFAULT '[]', -- Rows that could not be imported and why
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(), -- Timestamp when the job was created
+    updated_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(), -- Timestamp of the last progress update
+    finished_at timestamp(0) WITH TIME ZONE, -- Timestamp when the job completed or failed
+    version integer NOT NULL DEFAULT 1 -- Version for tracking record changes
+);
+
+CREATE INDEX IF NOT EXISTS import_jobs_user_id_idx ON import_jobs (user_id);
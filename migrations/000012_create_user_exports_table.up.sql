@@ -0,0 +1,12 @@
+-- Create the 'user_exports' table to hold personal data exports until they are downloaded
+CREATE TABLE IF NOT EXISTS user_exports (
+    id bigserial PRIMARY KEY, -- Unique identifier for each export
+    hash bytea UNIQUE NOT NULL, -- SHA-256 hash of the download token, the plaintext is only emailed
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- User the data belongs to
+    format text NOT NULL, -- Format of the files inside the archive: json, csv or goodreads
+    archive bytea NOT NULL, -- The ZIP archive itself
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(), -- Timestamp when the export was generated
+    expiry timestamp(0) WITH TIME ZONE NOT NULL -- The download token stops working after this
+);
+
+CREATE INDEX IF NOT EXISTS user_exports_expiry_idx ON user_exports (expiry);
@@ -0,0 +1,3 @@
+-- Books can have a cover image. The key points at the folder in storage
+-- that holds the original upload and its thumbnails, '' means no cover
+ALTER TABLE books ADD COLUMN IF NOT EXISTS cover_key text NOT NULL DEFAULT '';
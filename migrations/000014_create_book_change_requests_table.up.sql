@@ -0,0 +1,18 @@
+-- Create the 'book_change_requests' table to store edits members propose for moderators to review
+CREATE TABLE IF NOT EXISTS book_change_requests (
+    id bigserial PRIMARY KEY, -- Unique identifier for each change request
+    book_id bigint NOT NULL REFERENCES books(id) ON DELETE CASCADE, -- Book the edit is for, dropped with the book
+    proposed_by bigint REFERENCES users(id) ON DELETE SET NULL, -- Member who proposed the edit
+    base_version integer NOT NULL, -- Version of the book the edit was made against
+    changes jsonb NOT NULL, -- The fields that change, each with its value before and after
+    comment text NOT NULL DEFAULT '', -- Why the proposer wants the change
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')), -- Review state
+    reason text NOT NULL DEFAULT '', -- Why a moderator rejected the change
+    reviewed_by bigint REFERENCES users(id) ON DELETE SET NULL, -- Moderator who approved or rejected it
+    reviewed_at timestamp(0) WITH TIME ZONE, -- Timestamp of the review
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(), -- Timestamp when the change was proposed
+    version integer NOT NULL DEFAULT 1 -- Version for tracking record changes
+);
+
+CREATE INDEX IF NOT EXISTS book_change_requests_status_idx ON book_change_requests (status, created_at);
+CREATE INDEX IF NOT EXISTS book_change_requests_book_id_idx ON book_change_requests (book_id);
@@ -0,0 +1,12 @@
+-- Create the 'book_similarities' table to store how alike two books are, as
+-- computed from ratings and reading lists by a background job
+CREATE TABLE IF NOT EXISTS book_similarities (
+    book_id bigint NOT NULL REFERENCES books(id) ON DELETE CASCADE, -- Book the similar book is listed for
+    similar_book_id bigint NOT NULL REFERENCES books(id) ON DELETE CASCADE, -- Book that readers of book_id also read
+    score float8 NOT NULL, -- Cosine similarity of the two books, between 0 and 1
+    shared_readers integer NOT NULL, -- Number of users who read or rated both books
+    computed_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(), -- Timestamp of the run that computed the row
+    PRIMARY KEY (book_id, similar_book_id)
+);
+
+CREATE INDEX IF NOT EXISTS book_similarities_book_id_score_idx ON book_similarities (book_id, score DESC);
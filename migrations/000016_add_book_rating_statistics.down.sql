@@ -0,0 +1,26 @@
+-- Put back the trigger function from 000002
+CREATE OR REPLACE FUNCTION automatic_average_rating()
+RETURNS TRIGGER AS $$
+BEGIN
+    -- Update the book's average rating based on associated reviews
+    UPDATE books
+    SET average_rating = (
+        SELECT ROUND(CAST(AVG(rating) AS NUMERIC), 2) -- Calculate the average rating rounded to 2 decimal places
+        FROM bookreviews
+        WHERE bookreviews.book_id = NEW.book_id
+    )
+    WHERE id = NEW.book_id;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP FUNCTION IF EXISTS apply_book_rating(bigint, float8, integer);
+DROP FUNCTION IF EXISTS bayesian_rating(float8, integer);
+DROP FUNCTION IF EXISTS rating_star(float8);
+DROP TABLE IF EXISTS rating_totals;
+DROP INDEX IF EXISTS books_weighted_rating_idx;
+ALTER TABLE books DROP COLUMN IF EXISTS weighted_rating;
+ALTER TABLE books DROP COLUMN IF EXISTS rating_distribution;
+ALTER TABLE books DROP COLUMN IF EXISTS rating_sum;
+ALTER TABLE books DROP COLUMN IF EXISTS rating_count;
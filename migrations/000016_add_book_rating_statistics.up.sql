@@ -0,0 +1,107 @@
+-- Rating statistics kept on every book so ranking by rating does not have to
+-- aggregate the reviews on each request
+ALTER TABLE books ADD COLUMN IF NOT EXISTS rating_count integer NOT NULL DEFAULT 0; -- Number of rated reviews
+ALTER TABLE books ADD COLUMN IF NOT EXISTS rating_sum float8 NOT NULL DEFAULT 0; -- Sum of the ratings
+ALTER TABLE books ADD COLUMN IF NOT EXISTS rating_distribution integer[] NOT NULL DEFAULT '{0,0,0,0,0}'; -- Number of 1 to 5 star ratings
+ALTER TABLE books ADD COLUMN IF NOT EXISTS weighted_rating float8 NOT NULL DEFAULT 0; -- Bayesian average of the ratings
+
+CREATE INDEX IF NOT EXISTS books_weighted_rating_idx ON books (weighted_rating);
+
+-- Create the 'rating_totals' table, a single row with the totals over all
+-- ratings. Their mean is what the weighted rating of every book starts from
+CREATE TABLE IF NOT EXISTS rating_totals (
+    id boolean PRIMARY KEY DEFAULT true CHECK (id), -- Allows only one row
+    rating_count bigint NOT NULL DEFAULT 0, -- Number of rated reviews
+    rating_sum float8 NOT NULL DEFAULT 0 -- Sum of all ratings
+);
+
+-- Star bucket of a rating, half stars count with the star above them
+CREATE OR REPLACE FUNCTION rating_star(rating float8)
+RETURNS integer AS $$
+    SELECT LEAST(5, GREATEST(1, FLOOR(rating + 0.5)))::integer;
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Bayesian average of a book: its own ratings plus 5 imaginary ratings at the
+-- mean over all books, so a single 5 star review cannot outrank a book that
+-- hundreds of readers rated 4.5
+CREATE OR REPLACE FUNCTION bayesian_rating(book_sum float8, book_count integer)
+RETURNS float8 AS $$
+    SELECT CASE WHEN t.rating_count = 0 THEN 0
+                ELSE (5 * t.rating_sum / t.rating_count + book_sum) / (5 + book_count)
+           END
+    FROM rating_totals t;
+$$ LANGUAGE sql STABLE;
+
+-- Fill in the statistics from the reviews there already are
+INSERT INTO rating_totals (rating_count, rating_sum)
+SELECT COUNT(*), COALESCE(SUM(rating), 0)
+FROM bookreviews
+WHERE rating IS NOT NULL AND book_id IS NOT NULL
+ON CONFLICT (id) DO NOTHING;
+
+UPDATE books
+SET rating_count = r.rating_count,
+    rating_sum = r.rating_sum,
+    rating_distribution = r.rating_distribution
+FROM (
+    SELECT book_id, COUNT(*) AS rating_count, SUM(rating) AS rating_sum,
+           ARRAY[
+               COUNT(*) FILTER (WHERE rating_star(rating) = 1),
+               COUNT(*) FILTER (WHERE rating_star(rating) = 2),
+               COUNT(*) FILTER (WHERE rating_star(rating) = 3),
+               COUNT(*) FILTER (WHERE rating_star(rating) = 4),
+               COUNT(*) FILTER (WHERE rating_star(rating) = 5)
+           ]::integer[] AS rating_distribution
+    FROM bookreviews
+    WHERE rating IS NOT NULL AND book_id IS NOT NULL
+    GROUP BY book_id
+) r
+WHERE books.id = r.book_id;
+
+UPDATE books
+SET average_rating = CASE WHEN rating_count > 0 THEN ROUND(CAST(rating_sum / rating_count AS NUMERIC), 2) ELSE 0 END,
+    weighted_rating = bayesian_rating(rating_sum, rating_count);
+
+-- Function to add a rating to (delta = 1) or take it off (delta = -1) the
+-- statistics of a book and the totals
+CREATE OR REPLACE FUNCTION apply_book_rating(rated_book bigint, rated float8, delta integer)
+RETURNS void AS $$
+BEGIN
+    UPDATE rating_totals
+    SET rating_count = rating_count + delta,
+        rating_sum = rating_sum + delta * rated;
+
+    UPDATE books
+    SET rating_count = rating_count + delta,
+        rating_sum = rating_sum + delta * rated,
+        rating_distribution[rating_star(rated)] = rating_distribution[rating_star(rated)] + delta
+    WHERE id = rated_book;
+
+    UPDATE books
+    SET average_rating = CASE WHEN rating_count > 0 THEN ROUND(CAST(rating_sum / rating_count AS NUMERIC), 2) ELSE 0 END,
+        weighted_rating = bayesian_rating(rating_sum, rating_count)
+    WHERE id = rated_book;
+END;
+$$ LANGUAGE plpgsql;
+
+-- The trigger keeps calling this function. It used to read NEW on DELETE,
+-- where only OLD is set, so deleted reviews stayed in the average
+CREATE OR REPLACE FUNCTION automatic_average_rating()
+RETURNS TRIGGER AS $$
+BEGIN
+    -- edits that leave the rating alone do not change the statistics
+    IF TG_OP = 'UPDATE' AND OLD.rating IS NOT DISTINCT FROM NEW.rating
+       AND OLD.book_id IS NOT DISTINCT FROM NEW.book_id THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.rating IS NOT NULL AND OLD.book_id IS NOT NULL THEN
+        PERFORM apply_book_rating(OLD.book_id, OLD.rating, -1);
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.rating IS NOT NULL AND NEW.book_id IS NOT NULL THEN
+        PERFORM apply_book_rating(NEW.book_id, NEW.rating, 1);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,57 @@
+-- Whatever is still in the trash goes for good, while the functions below
+-- still keep it out of the rating statistics
+DELETE FROM bookreviews WHERE deleted_at IS NOT NULL;
+DELETE FROM readinglists WHERE deleted_at IS NOT NULL;
+DELETE FROM books WHERE deleted_at IS NOT NULL;
+
+-- Put back the trigger functions from 000016 and 000009
+CREATE OR REPLACE FUNCTION automatic_average_rating()
+RETURNS TRIGGER AS $$
+BEGIN
+    -- edits that leave the rating alone do not change the statistics
+    IF TG_OP = 'UPDATE' AND OLD.rating IS NOT DISTINCT FROM NEW.rating
+       AND OLD.book_id IS NOT DISTINCT FROM NEW.book_id THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.rating IS NOT NULL AND OLD.book_id IS NOT NULL THEN
+        PERFORM apply_book_rating(OLD.book_id, OLD.rating, -1);
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.rating IS NOT NULL AND NEW.book_id IS NOT NULL THEN
+        PERFORM apply_book_rating(NEW.book_id, NEW.rating, 1);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION automatic_work_rating()
+RETURNS TRIGGER AS $$
+DECLARE
+    reviewed_book bigint;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        reviewed_book := OLD.book_id;
+    ELSE
+        reviewed_book := NEW.book_id;
+    END IF;
+
+    UPDATE works
+    SET average_rating = COALESCE((
+        SELECT ROUND(CAST(AVG(r.rating) AS NUMERIC), 2)
+        FROM bookreviews r
+        INNER JOIN books b ON b.id = r.book_id
+        WHERE b.work_id = works.id
+    ), 0)
+    WHERE id = (SELECT work_id FROM books WHERE id = reviewed_book);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS bookreviews_deleted_at_idx;
+DROP INDEX IF EXISTS readinglists_deleted_at_idx;
+DROP INDEX IF EXISTS books_deleted_at_idx;
+ALTER TABLE bookreviews DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE readinglists DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE books DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,60 @@
+-- Books, reading lists and reviews are moved to the trash by setting
+-- deleted_at. They can be restored until the purge job removes them for good
+-- once the retention period is over
+ALTER TABLE books ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) WITH TIME ZONE; -- When the book was moved to the trash
+ALTER TABLE readinglists ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) WITH TIME ZONE; -- When the list was moved to the trash
+ALTER TABLE bookreviews ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) WITH TIME ZONE; -- When the review was moved to the trash
+
+-- Only the trash is ever looked up by deleted_at
+CREATE INDEX IF NOT EXISTS books_deleted_at_idx ON books (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS readinglists_deleted_at_idx ON readinglists (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS bookreviews_deleted_at_idx ON bookreviews (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Reviews in the trash do not count towards the rating statistics, so moving
+-- one in or out of the trash takes its rating off or puts it back
+CREATE OR REPLACE FUNCTION automatic_average_rating()
+RETURNS TRIGGER AS $$
+BEGIN
+    -- edits that leave the rating alone do not change the statistics
+    IF TG_OP = 'UPDATE' AND OLD.rating IS NOT DISTINCT FROM NEW.rating
+       AND OLD.book_id IS NOT DISTINCT FROM NEW.book_id
+       AND (OLD.deleted_at IS NULL) = (NEW.deleted_at IS NULL) THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.rating IS NOT NULL AND OLD.book_id IS NOT NULL
+       AND OLD.deleted_at IS NULL THEN
+        PERFORM apply_book_rating(OLD.book_id, OLD.rating, -1);
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.rating IS NOT NULL AND NEW.book_id IS NOT NULL
+       AND NEW.deleted_at IS NULL THEN
+        PERFORM apply_book_rating(NEW.book_id, NEW.rating, 1);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION automatic_work_rating()
+RETURNS TRIGGER AS $$
+DECLARE
+    reviewed_book bigint;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        reviewed_book := OLD.book_id;
+    ELSE
+        reviewed_book := NEW.book_id;
+    END IF;
+
+    UPDATE works
+    SET average_rating = COALESCE((
+        SELECT ROUND(CAST(AVG(r.rating) AS NUMERIC), 2)
+        FROM bookreviews r
+        INNER JOIN books b ON b.id = r.book_id
+        WHERE b.work_id = works.id AND r.deleted_at IS NULL
+    ), 0)
+    WHERE id = (SELECT work_id FROM books WHERE id = reviewed_book);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE code = 'audit:read';
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS reject_audit_event_changes();
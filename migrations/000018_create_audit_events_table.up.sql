@@ -0,0 +1,27 @@
+-- Create the 'audit_events' table to record who changed what, one row per create, update or delete
+CREATE TABLE IF NOT EXISTS audit_events (
+    id bigserial PRIMARY KEY, -- Unique identifier for each event
+    actor_id bigint, -- User who made the change, NULL for anonymous requests; no foreign key so events outlive users
+    resource text NOT NULL, -- Kind of record that changed, e.g. 'books'
+    resource_id bigint NOT NULL, -- Id of the record that changed
+    action text NOT NULL, -- What happened to it, e.g. 'create', 'update' or 'delete'
+    changes jsonb NOT NULL DEFAULT '[]', -- The fields that changed, each with its value before and after
+    request_id text NOT NULL DEFAULT '', -- X-Request-ID of the request that made the change
+    ip text NOT NULL DEFAULT '', -- Address the request came from
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW() -- Timestamp of the change
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_resource_idx ON audit_events (resource, resource_id, id);
+
+-- The log is append-only: events can be added but never changed or removed
+CREATE OR REPLACE FUNCTION reject_audit_event_changes() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit events are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION reject_audit_event_changes();
+
+INSERT INTO permissions (code) VALUES ('audit:read') ON CONFLICT (code) DO NOTHING;
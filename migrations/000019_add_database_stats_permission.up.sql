@@ -0,0 +1 @@
+INSERT INTO permissions (code) VALUES ('database:stats') ON CONFLICT (code) DO NOTHING;
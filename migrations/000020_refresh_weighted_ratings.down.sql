@@ -0,0 +1,2 @@
+ALTER TABLE books ALTER COLUMN weighted_rating SET DEFAULT 0;
+DROP FUNCTION IF EXISTS refresh_weighted_ratings();
@@ -0,0 +1,24 @@
+-- The weighted rating stays stored on the books so sorting by it can use
+-- books_weighted_rating_idx, and the mean it starts from stays in the single
+-- rating_totals row apply_book_rating moves by every rating. A book's weighted
+-- rating is only recomputed when the book itself is rated, so it drifts as the
+-- mean moves with the ratings of other books. The API runs this function
+-- periodically to bring every book back to the current mean.
+CREATE OR REPLACE FUNCTION refresh_weighted_ratings()
+RETURNS bigint AS $$
+DECLARE
+    refreshed bigint;
+BEGIN
+    UPDATE books
+    SET weighted_rating = bayesian_rating(rating_sum, rating_count)
+    WHERE weighted_rating IS DISTINCT FROM bayesian_rating(rating_sum, rating_count);
+
+    GET DIAGNOSTICS refreshed = ROW_COUNT;
+    RETURN refreshed;
+END;
+$$ LANGUAGE plpgsql;
+
+-- A book that was never rated starts at the mean, not at 0
+ALTER TABLE books ALTER COLUMN weighted_rating SET DEFAULT bayesian_rating(0, 0);
+
+SELECT refresh_weighted_ratings();
@@ -0,0 +1,29 @@
+DROP TRIGGER IF EXISTS update_moved_edition_work_rating ON books;
+DROP FUNCTION IF EXISTS moved_edition_work_rating();
+
+-- Put back the trigger function from 000017
+CREATE OR REPLACE FUNCTION automatic_work_rating()
+RETURNS TRIGGER AS $$
+DECLARE
+    reviewed_book bigint;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        reviewed_book := OLD.book_id;
+    ELSE
+        reviewed_book := NEW.book_id;
+    END IF;
+
+    UPDATE works
+    SET average_rating = COALESCE((
+        SELECT ROUND(CAST(AVG(r.rating) AS NUMERIC), 2)
+        FROM bookreviews r
+        INNER JOIN books b ON b.id = r.book_id
+        WHERE b.work_id = works.id AND r.deleted_at IS NULL
+    ), 0)
+    WHERE id = (SELECT work_id FROM books WHERE id = reviewed_book);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP FUNCTION IF EXISTS refresh_work_rating(bigint);
@@ -0,0 +1,50 @@
+-- Average rating of a work over the reviews of all its editions
+CREATE OR REPLACE FUNCTION refresh_work_rating(rated_work bigint)
+RETURNS void AS $$
+    UPDATE works
+    SET average_rating = COALESCE((
+        SELECT ROUND(CAST(AVG(r.rating) AS NUMERIC), 2)
+        FROM bookreviews r
+        INNER JOIN books b ON b.id = r.book_id
+        WHERE b.work_id = works.id AND r.deleted_at IS NULL
+    ), 0)
+    WHERE id = rated_work;
+$$ LANGUAGE sql;
+
+CREATE OR REPLACE FUNCTION automatic_work_rating()
+RETURNS TRIGGER AS $$
+DECLARE
+    reviewed_book bigint;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        reviewed_book := OLD.book_id;
+    ELSE
+        reviewed_book := NEW.book_id;
+    END IF;
+
+    PERFORM refresh_work_rating((SELECT work_id FROM books WHERE id = reviewed_book));
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- An edition moved to another work takes its reviews along, so the work it
+-- left and the one it joined both get a new average, in the same statement
+CREATE OR REPLACE FUNCTION moved_edition_work_rating()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM refresh_work_rating(OLD.work_id);
+    PERFORM refresh_work_rating(NEW.work_id);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER update_moved_edition_work_rating
+AFTER UPDATE OF work_id ON books
+FOR EACH ROW
+WHEN (OLD.work_id IS DISTINCT FROM NEW.work_id)
+EXECUTE FUNCTION moved_edition_work_rating();
+
+-- Fix the averages editions moved so far left behind
+SELECT refresh_work_rating(id) FROM works;
@@ -0,0 +1,33 @@
+DROP TRIGGER IF EXISTS update_edition_work_rating ON books;
+DROP FUNCTION IF EXISTS edition_work_rating();
+
+-- Put back the functions and trigger from 000021
+CREATE OR REPLACE FUNCTION refresh_work_rating(rated_work bigint)
+RETURNS void AS $$
+    UPDATE works
+    SET average_rating = COALESCE((
+        SELECT ROUND(CAST(AVG(r.rating) AS NUMERIC), 2)
+        FROM bookreviews r
+        INNER JOIN books b ON b.id = r.book_id
+        WHERE b.work_id = works.id AND r.deleted_at IS NULL
+    ), 0)
+    WHERE id = rated_work;
+$$ LANGUAGE sql;
+
+CREATE OR REPLACE FUNCTION moved_edition_work_rating()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM refresh_work_rating(OLD.work_id);
+    PERFORM refresh_work_rating(NEW.work_id);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER update_moved_edition_work_rating
+AFTER UPDATE OF work_id ON books
+FOR EACH ROW
+WHEN (OLD.work_id IS DISTINCT FROM NEW.work_id)
+EXECUTE FUNCTION moved_edition_work_rating();
+
+SELECT refresh_work_rating(id) FROM works;
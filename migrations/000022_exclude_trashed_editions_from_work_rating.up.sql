@@ -0,0 +1,39 @@
+-- Editions in the trash no longer count towards the average of their work,
+-- the same way WorkModel.Get leaves them out of the review count
+CREATE OR REPLACE FUNCTION refresh_work_rating(rated_work bigint)
+RETURNS void AS $$
+    UPDATE works
+    SET average_rating = COALESCE((
+        SELECT ROUND(CAST(AVG(r.rating) AS NUMERIC), 2)
+        FROM bookreviews r
+        INNER JOIN books b ON b.id = r.book_id
+        WHERE b.work_id = works.id AND r.deleted_at IS NULL AND b.deleted_at IS NULL
+    ), 0)
+    WHERE id = rated_work;
+$$ LANGUAGE sql;
+
+-- so moving an edition in or out of the trash changes the average too, along
+-- with moving it to another work
+DROP TRIGGER IF EXISTS update_moved_edition_work_rating ON books;
+DROP FUNCTION IF EXISTS moved_edition_work_rating();
+
+CREATE OR REPLACE FUNCTION edition_work_rating()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.work_id IS DISTINCT FROM NEW.work_id THEN
+        PERFORM refresh_work_rating(OLD.work_id);
+    END IF;
+    PERFORM refresh_work_rating(NEW.work_id);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER update_edition_work_rating
+AFTER UPDATE OF work_id, deleted_at ON books
+FOR EACH ROW
+WHEN (OLD.work_id IS DISTINCT FROM NEW.work_id OR OLD.deleted_at IS DISTINCT FROM NEW.deleted_at)
+EXECUTE FUNCTION edition_work_rating();
+
+-- Fix the averages of works with editions in the trash now
+SELECT refresh_work_rating(id) FROM works;
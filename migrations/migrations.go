@@ -0,0 +1,13 @@
+// Filename: migrations/migrations.go
+
+// Package migrations holds the SQL migrations of the database schema. They
+// are embedded in the binary so cmd/api can bring an empty database up to date
+// by itself; the files stay usable with the migrate CLI.
+package migrations
+
+import "embed"
+
+// Files holds the NNNNNN_name.up.sql and NNNNNN_name.down.sql files.
+//
+//go:embed *.sql
+var Files embed.FS
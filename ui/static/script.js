@@ -10,6 +10,7 @@ async function fetchBooks() {
 
     bookTable.innerHTML = books.map(book => `
       <tr>
+          <td>${book.cover ? `<img class="cover" src="${book.cover.small}" alt="">` : ""}</td>
           <td>${book.title}</td>
           <td>${book.authors}</td>
           <td>${book.isbn}</td>
@@ -22,7 +23,7 @@ async function fetchBooks() {
     `).join("");
   } catch (error) {
     console.error("Error fetching books:", error);
-    bookTable.innerHTML = "<tr><td colspan='5'>Failed to load books.</td></tr>";
+    bookTable.innerHTML = "<tr><td colspan='6'>Failed to load books.</td></tr>";
   }
 }
 